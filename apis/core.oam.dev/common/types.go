@@ -18,6 +18,7 @@ package common
 
 import (
 	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
@@ -165,6 +166,10 @@ const (
 	ApplicationRendering ApplicationPhase = "rendering"
 	// ApplicationRunningWorkflow means the app is running workflow
 	ApplicationRunningWorkflow ApplicationPhase = "runningWorkflow"
+	// ApplicationWorkflowSuspending means the workflow of the app is suspended
+	ApplicationWorkflowSuspending ApplicationPhase = "workflowSuspending"
+	// ApplicationWorkflowTerminated means the workflow of the app is terminated
+	ApplicationWorkflowTerminated ApplicationPhase = "workflowTerminated"
 	// ApplicationRunning means the app finished rendering and applied result to the cluster
 	ApplicationRunning ApplicationPhase = "running"
 	// ApplicationHealthChecking means the app finished rendering and applied result to the cluster, but still unhealthy
@@ -180,6 +185,9 @@ type ApplicationComponentStatus struct {
 	Message            string                           `json:"message,omitempty"`
 	Traits             []ApplicationTraitStatus         `json:"traits,omitempty"`
 	Scopes             []runtimev1alpha1.TypedReference `json:"scopes,omitempty"`
+	// WaitingFor is the names of the components this component depends on which are not ready yet,
+	// the component is not dispatched until it's empty.
+	WaitingFor []string `json:"waitingFor,omitempty"`
 }
 
 // ApplicationTraitStatus records the trait health status
@@ -211,10 +219,83 @@ type WorkflowStepStatus struct {
 	Type        string                         `json:"type,omitempty"`
 	Phase       WorkflowStepPhase              `json:"phase,omitempty"`
 	ResourceRef runtimev1alpha1.TypedReference `json:"resourceRef,omitempty"`
+
+	// A human readable message indicating details about why the step is in this phase.
+	Message string `json:"message,omitempty"`
+
+	// Reason is a brief CamelCase string that describes why the step is in this phase, e.g. `Timeout`.
+	Reason string `json:"reason,omitempty"`
+
+	// StartTime is the time when the current attempt of the step is started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// NextRetryTime is the time when the next attempt of a failed step waiting to be retried will be started.
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// EndTime is the time when the step is finished.
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+
+	// Attempts is the number of times the step has been executed, including the retries.
+	Attempts int `json:"attempts,omitempty"`
+
+	// Approval records who approved or rejected a suspend step and when.
+	Approval *WorkflowStepApproval `json:"approval,omitempty"`
+}
+
+// WorkflowStepApproval records the decision made on a suspend step
+type WorkflowStepApproval struct {
+	// Approved is true if the step is approved, or false if it's rejected.
+	Approved bool `json:"approved"`
+
+	// Approver is the identity of who made the decision.
+	Approver string `json:"approver,omitempty"`
+
+	// Time is when the decision is made.
+	Time metav1.Time `json:"time,omitempty"`
+
+	// Comment is the reason of the decision given by the approver.
+	Comment string `json:"comment,omitempty"`
 }
 
 // WorkflowStatus record the status of workflow
 type WorkflowStatus struct {
+	// AppRevision is the app revision which the workflow is executed for,
+	// the workflow status will be reset once a new app revision is generated.
+	AppRevision string `json:"appRevision,omitempty"`
+
+	// RestartGeneration is the value of the workflow restart annotation of the application when the workflow is started,
+	// the workflow is executed from scratch once the annotation is changed.
+	RestartGeneration int64 `json:"restartGeneration,omitempty"`
+
+	// Suspend indicates the workflow is suspended by the workflow suspend annotation of the application,
+	// no step will be executed until the annotation is removed.
+	Suspend bool `json:"suspend"`
+
+	// Terminated indicates the workflow is terminated by the workflow terminate annotation of the application,
+	// no step will be executed until it's restarted.
+	Terminated bool `json:"terminated"`
+
+	// StartTime is the time when the workflow is started for the app revision.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// EndTime is the time when the workflow is finished or terminated.
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+
+	Steps []WorkflowStepStatus `json:"steps,omitempty"`
+}
+
+// WorkflowRun records a finished or terminated run of the application workflow
+type WorkflowRun struct {
+	// StartTime is the time when the run is started.
+	StartTime metav1.Time `json:"startTime"`
+
+	// EndTime is the time when the run is finished or terminated.
+	EndTime metav1.Time `json:"endTime"`
+
+	// Terminated indicates the run is terminated before all steps are finished.
+	Terminated bool `json:"terminated,omitempty"`
+
+	// Steps records the status of each step when the run ends.
 	Steps []WorkflowStepStatus `json:"steps,omitempty"`
 }
 
@@ -257,6 +338,23 @@ const (
 	WorkflowStepPhaseStopped WorkflowStepPhase = "stopped"
 	// WorkflowStepPhaseRunning will make the controller continue the workflow.
 	WorkflowStepPhaseRunning WorkflowStepPhase = "running"
+	// WorkflowStepPhasePending means the step is blocked by the steps it depends on.
+	WorkflowStepPhasePending WorkflowStepPhase = "pending"
+	// WorkflowStepPhaseSkipped means the step is not executed since its `if` condition is false.
+	WorkflowStepPhaseSkipped WorkflowStepPhase = "skipped"
+)
+
+const (
+	// WorkflowStepReasonTimeout means the step is failed since it's not finished in its timeout.
+	WorkflowStepReasonTimeout = "Timeout"
+	// WorkflowStepReasonBackoff means the step is failed and waiting to be retried.
+	WorkflowStepReasonBackoff = "Backoff"
+	// WorkflowStepReasonRetryLimitExceeded means the step is still failed after it's retried for maxRetries times.
+	WorkflowStepReasonRetryLimitExceeded = "RetryLimitExceeded"
+	// WorkflowStepReasonWaitingForApproval means the suspend step is waiting for an approver to approve or reject it.
+	WorkflowStepReasonWaitingForApproval = "WaitingForApproval"
+	// WorkflowStepReasonRejected means the suspend step is failed since it's rejected by an approver.
+	WorkflowStepReasonRejected = "Rejected"
 )
 
 // DefinitionType describes the type of DefinitionRevision.
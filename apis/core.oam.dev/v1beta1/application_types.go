@@ -28,11 +28,30 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// DeletionPolicy decides whether the resources of a component or a trait are deleted
+// when they're removed from the application or the application is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the resources, it's the default.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the resources when they're removed from the application, e.g. the component is
+	// removed, or the application is deleted. They're still tracked by the application until it's deleted.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyOrphan keeps the resources when they're removed from the application or the application is
+	// deleted. They're released from the application and no longer tracked by it.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
 // ApplicationTrait defines the trait of application
 type ApplicationTrait struct {
 	Type string `json:"type"`
 	// +kubebuilder:pruning:PreserveUnknownFields
 	Properties runtime.RawExtension `json:"properties,omitempty"`
+
+	// DeletionPolicy is the deletion policy of the resources of the trait, it's the one of the component by default.
+	// +kubebuilder:validation:Enum=Delete;Orphan;Retain
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // ApplicationComponent describe the component of application
@@ -42,6 +61,22 @@ type ApplicationComponent struct {
 	// +kubebuilder:pruning:PreserveUnknownFields
 	Properties runtime.RawExtension `json:"properties,omitempty"`
 
+	// DependsOn is the names of the components this component depends on.
+	// The component will not be dispatched until all of them are dispatched and healthy.
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Inputs are the outputs of other components filled into the properties of this component.
+	// The component implicitly depends on the components producing its inputs,
+	// and it's not rendered until the values of its inputs exist.
+	Inputs []InputItem `json:"inputs,omitempty"`
+
+	// Outputs are the values exported from the resources of this component for other components.
+	Outputs []ComponentOutput `json:"outputs,omitempty"`
+
+	// DeletionPolicy is the deletion policy of the resources of this component and its traits, it's Delete by default.
+	// +kubebuilder:validation:Enum=Delete;Orphan;Retain
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
 	// Traits define the trait of one component, the type must be array to keep the order.
 	Traits []ApplicationTrait `json:"traits,omitempty"`
 
@@ -51,6 +86,16 @@ type ApplicationComponent struct {
 	Scopes map[string]string `json:"scopes,omitempty"`
 }
 
+// ComponentOutput exports a value from the resources of a component in the cluster
+type ComponentOutput struct {
+	// Name is the name of the output, it must be unique in the application.
+	Name string `json:"name"`
+	// ValueFrom is a CUE expression evaluated with the same `context` as the health policy of the component definition,
+	// e.g. `context.output.spec.clusterIP` or `context.outputs.service.spec.clusterIP`.
+	// For a Terraform component `context.output` is its Configuration, e.g. `context.output.status.outputs.host.value`.
+	ValueFrom string `json:"valueFrom"`
+}
+
 // AppPolicy defines a global policy for all components in the app.
 type AppPolicy struct {
 	// Name is the unique name of the policy.
@@ -68,15 +113,97 @@ type WorkflowStep struct {
 
 	Type string `json:"type"`
 
+	// DependsOn is the names of the workflow steps this step depends on.
+	// The step will not be executed until all of them are succeeded.
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// If is a CUE expression deciding whether the step is executed, the step is skipped if it's false.
+	// It can refer to `context` of the workflow, `app.metadata`, `parameter` of the step
+	// and `steps.<name>` for the phase, reason and message of the other steps, e.g. `steps.deploy.phase == "failed"`.
+	// A step with `if` waits for the steps it depends on to finish rather than to succeed.
+	// The steps it refers to are executed before it, so they can't depend on it.
+	If string `json:"if,omitempty"`
+
+	// Inputs are the outputs of previous steps filled into the properties of this step.
+	// The step implicitly depends on the steps producing its inputs.
+	Inputs StepInputs `json:"inputs,omitempty"`
+
+	// Outputs are the values exported by this step once it's succeeded.
+	Outputs StepOutputs `json:"outputs,omitempty"`
+
+	// Timeout is the duration a running step can take in one attempt, e.g. `10m`.
+	// The step is failed with reason `Timeout` once it's exceeded.
+	Timeout string `json:"timeout,omitempty"`
+
+	// MaxRetries is the number of times a failed step is retried.
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// Backoff is the duration to wait before retrying a failed step, e.g. `30s`.
+	// It doubles after every retry.
+	Backoff string `json:"backoff,omitempty"`
+
+	// RecreateOnRetry deletes the step object before the step is retried,
+	// so its controller can process it from scratch.
+	RecreateOnRetry bool `json:"recreateOnRetry,omitempty"`
+
+	// Ref is the name of a WorkflowTemplate which a `step-group` step expands into,
+	// the properties of the step are the parameters of the template.
+	Ref string `json:"ref,omitempty"`
+
+	// SubSteps are the steps which a `step-group` step expands into.
+	// The expanded steps are named `<group>-<step>`, the entry steps depend on what the group depends on,
+	// and the steps depending on the group wait for all of its steps.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	SubSteps []WorkflowStep `json:"subSteps,omitempty"`
+
 	// +kubebuilder:pruning:PreserveUnknownFields
 	Properties runtime.RawExtension `json:"properties,omitempty"`
 }
 
+// StepInputs defines the inputs of a workflow step
+type StepInputs []InputItem
+
+// InputItem fills an output of previous steps into the properties of a workflow step,
+// or an output of another component into the properties of a component.
+type InputItem struct {
+	// From is the name of the output of a previous step, or of another component for the inputs of a component.
+	From string `json:"from"`
+	// ParameterKey is the dot separated path of the properties the value is filled into, e.g. `env.dbHost`.
+	ParameterKey string `json:"parameterKey"`
+}
+
+// StepOutputs defines the outputs of a workflow step
+type StepOutputs []OutputItem
+
+// OutputItem exports a value of a workflow step
+type OutputItem struct {
+	// Name is the name of the output, it must be unique in the workflow.
+	Name string `json:"name"`
+	// ValueFrom is the dot separated CUE path of the value in the step template, e.g. `actions.read.result.data.host`.
+	// For the step object rendered in `output`, the path is looked up in the object applied, e.g. `output.status.endpoint`.
+	ValueFrom string `json:"valueFrom"`
+}
+
 // Workflow defines workflow steps and other attributes
 type Workflow struct {
+	// Ref is the name of a WorkflowTemplate providing the steps of the workflow,
+	// it's looked up in the namespace of the application and then in the namespace of KubeVela.
+	// Steps cannot be given together with Ref.
+	Ref string `json:"ref,omitempty"`
+
+	// Properties are the parameters of the WorkflowTemplate referenced by Ref.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Properties runtime.RawExtension `json:"properties,omitempty"`
+
 	Steps []WorkflowStep `json:"steps,omitempty"`
 }
 
+// HasSteps checks whether the workflow has any step, given in place or by a WorkflowTemplate.
+func (w *Workflow) HasSteps() bool {
+	return w != nil && (w.Ref != "" || len(w.Steps) > 0)
+}
+
 // ApplicationSpec is the spec of Application
 type ApplicationSpec struct {
 	Components []ApplicationComponent `json:"components"`
@@ -88,7 +215,9 @@ type ApplicationSpec struct {
 
 	// Workflow defines how to customize the control logic.
 	// If workflow is specified, Vela won't apply any resource, but provide rendered output in AppRevision.
-	// Workflow steps are executed in array order, and each step:
+	// Workflow steps are executed in array order unless any step declares dependsOn,
+	// then steps are executed as a DAG and all steps whose dependencies are succeeded
+	// are executed at the same time. Each step:
 	// - will have a context in annotation.
 	// - should mark "finish" phase in status.conditions.
 	Workflow *Workflow `json:"workflow,omitempty"`
@@ -77,6 +77,14 @@ var (
 	WorkflowStepDefinitionGroupVersionKind = SchemeGroupVersion.WithKind(WorkflowStepDefinitionKind)
 )
 
+// WorkflowTemplate type metadata.
+var (
+	WorkflowTemplateKind             = reflect.TypeOf(WorkflowTemplate{}).Name()
+	WorkflowTemplateGroupKind        = schema.GroupKind{Group: Group, Kind: WorkflowTemplateKind}.String()
+	WorkflowTemplateKindAPIVersion   = WorkflowTemplateKind + "." + SchemeGroupVersion.String()
+	WorkflowTemplateGroupVersionKind = SchemeGroupVersion.WithKind(WorkflowTemplateKind)
+)
+
 // DefinitionRevision type metadata.
 var (
 	DefinitionRevisionKind             = reflect.TypeOf(DefinitionRevision{}).Name()
@@ -155,6 +163,7 @@ func init() {
 	SchemeBuilder.Register(&TraitDefinition{}, &TraitDefinitionList{})
 	SchemeBuilder.Register(&PolicyDefinition{}, &PolicyDefinitionList{})
 	SchemeBuilder.Register(&WorkflowStepDefinition{}, &WorkflowStepDefinitionList{})
+	SchemeBuilder.Register(&WorkflowTemplate{}, &WorkflowTemplateList{})
 	SchemeBuilder.Register(&DefinitionRevision{}, &DefinitionRevisionList{})
 	SchemeBuilder.Register(&ScopeDefinition{}, &ScopeDefinitionList{})
 	SchemeBuilder.Register(&Application{}, &ApplicationList{})
@@ -0,0 +1,51 @@
+/*
+ Copyright 2021. The KubeVela Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkflowTemplateSpec defines the desired state of WorkflowTemplate
+type WorkflowTemplateSpec struct {
+	// Template is the CUE template rendering the workflow steps in `steps` from `parameter`, e.g.
+	//   parameter: env: *"staging" | string
+	//   steps: [{name: "deploy", type: "deploy", properties: env: parameter.env}]
+	Template string `json:"template"`
+}
+
+// +kubebuilder:object:root=true
+
+// WorkflowTemplate is a reusable workflow which can be referenced by the workflow of Applications
+// or expanded by a `step-group` step. It's looked up in the namespace of the application
+// and then in the namespace of KubeVela, so templates in the latter are shared by all namespaces.
+// +kubebuilder:resource:scope=Namespaced,categories={oam},shortName=wft
+type WorkflowTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WorkflowTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// WorkflowTemplateList contains a list of WorkflowTemplate
+type WorkflowTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WorkflowTemplate `json:"items"`
+}
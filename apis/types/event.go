@@ -25,6 +25,10 @@ const (
 	ReasonHealthCheck = "HealthChecked"
 	ReasonDeployed    = "Deployed"
 	ReasonRollout     = "Rollout"
+	ReasonDrifted     = "Drifted"
+	ReasonRepaired    = "DriftRepaired"
+	ReasonOrphaned    = "Orphaned"
+	ReasonRetained    = "Retained"
 
 	ReasonFailedParse       = "FailedParse"
 	ReasonFailedRender      = "FailedRender"
@@ -34,6 +38,7 @@ const (
 	ReasonFailedHealthCheck = "FailedHealthCheck"
 	ReasonFailedGC          = "FailedGC"
 	ReasonFailedRollout     = "FailedRollout"
+	ReasonFailedRepair      = "FailedRepairDrift"
 )
 
 // event message for Application
@@ -45,6 +50,8 @@ const (
 	MessageHealthCheck = "Health checked healthy"
 	MessageDeployed    = "Deployed successfully"
 	MessageRollout     = "Rollout successfully"
+	MessageOrphaned    = "Orphaned resources by their deletion policy: %s"
+	MessageRetained    = "Retained resources by their deletion policy: %s"
 
 	MessageFailedParse       = "fail to parse application, err: %v"
 	MessageFailedRender      = "fail to render application, err: %v"
@@ -17,7 +17,11 @@ limitations under the License.
 package types
 
 import (
+	"encoding/json"
+
 	corev1 "k8s.io/api/core/v1"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 )
 
 // WorkflowContext is the workflow context to pass into workflow objects.
@@ -27,3 +31,58 @@ type WorkflowContext struct {
 	WorkflowIndex     int                         `json:"workflowIndex"`
 	ResourceConfigMap corev1.LocalObjectReference `json:"resourceConfigMap,omitempty"`
 }
+
+const (
+	// WorkflowStepTypeSuspend is the built-in workflow step which suspends the workflow until it's approved or rejected
+	WorkflowStepTypeSuspend = "suspend"
+	// WorkflowStepTypeApproval is an alias of WorkflowStepTypeSuspend
+	WorkflowStepTypeApproval = "approval"
+	// WorkflowStepTypeNotification is the built-in workflow step which sends a message to webhooks or by email
+	WorkflowStepTypeNotification = "notification"
+	// WorkflowStepTypeStepGroup is the step expanded into a group of steps given in place or by a WorkflowTemplate
+	WorkflowStepTypeStepGroup = "step-group"
+)
+
+// IsSuspendStep checks whether the workflow step is the built-in suspend step.
+func IsSuspendStep(stepType string) bool {
+	return stepType == WorkflowStepTypeSuspend || stepType == WorkflowStepTypeApproval
+}
+
+// IsBuiltinWorkflowStep checks whether the workflow step is executed by the workflow itself, which has no WorkflowStepDefinition.
+func IsBuiltinWorkflowStep(stepType string) bool {
+	return IsSuspendStep(stepType) || stepType == WorkflowStepTypeNotification
+}
+
+// WorkflowApproval is the decision made on a suspend step for a run of the workflow
+type WorkflowApproval struct {
+	common.WorkflowStepApproval `json:",inline"`
+	// AppRevision and RestartGeneration identify the run of the workflow which the decision is made for,
+	// the decision is ignored by any other run.
+	AppRevision       string `json:"appRevision"`
+	RestartGeneration int64  `json:"restartGeneration,omitempty"`
+}
+
+// WorkflowApprovals are the decisions made on the suspend steps keyed by the step names,
+// they are kept in the workflow approvals annotation of the application.
+type WorkflowApprovals map[string]WorkflowApproval
+
+// ParseWorkflowApprovals parses the value of the workflow approvals annotation.
+func ParseWorkflowApprovals(value string) (WorkflowApprovals, error) {
+	approvals := WorkflowApprovals{}
+	if value == "" {
+		return approvals, nil
+	}
+	if err := json.Unmarshal([]byte(value), &approvals); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// Of returns the decision made on the suspend step for the run of the workflow, or nil if there is none.
+func (a WorkflowApprovals) Of(stepName string, wfStatus *common.WorkflowStatus) *common.WorkflowStepApproval {
+	approval, ok := a[stepName]
+	if !ok || approval.AppRevision != wfStatus.AppRevision || approval.RestartGeneration != wfStatus.RestartGeneration {
+		return nil
+	}
+	return approval.WorkflowStepApproval.DeepCopy()
+}
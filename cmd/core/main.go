@@ -31,11 +31,14 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
+	builtinhttp "github.com/oam-dev/kubevela/pkg/builtin/http"
+	"github.com/oam-dev/kubevela/pkg/builtin/kube"
 	standardcontroller "github.com/oam-dev/kubevela/pkg/controller"
 	commonconfig "github.com/oam-dev/kubevela/pkg/controller/common"
 	oamcontroller "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev"
 	oamv1alpha2 "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/pkg/controller/utils"
+	"github.com/oam-dev/kubevela/pkg/cue/definition"
 	"github.com/oam-dev/kubevela/pkg/cue/packages"
 	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
@@ -68,6 +71,8 @@ func main() {
 	var disableCaps string
 	var storageDriver string
 	var syncPeriod time.Duration
+	var kubeTaskReadableKinds string
+	var templateCacheSize int
 	var applyOnceOnly string
 
 	flag.BoolVar(&useWebhook, "use-webhook", false, "Enable Admission Webhook")
@@ -101,6 +106,14 @@ func main() {
 	flag.IntVar(&controllerArgs.ConcurrentReconciles, "concurrent-reconciles", 4, "concurrent-reconciles is the concurrent reconcile number of the controller. The default value is 4")
 	flag.DurationVar(&controllerArgs.DependCheckWait, "depend-check-wait", 30*time.Second, "depend-check-wait is the time to wait for ApplicationConfiguration's dependent-resource ready."+
 		"The default value is 30s, which means if dependent resources were not prepared, the ApplicationConfiguration would be reconciled after 30s.")
+	flag.StringVar(&kubeTaskReadableKinds, "kube-task-readable-kinds", strings.Join(kube.DefaultReadableKinds, ","),
+		"The kinds which the kube task in `processing` of templates can read from the namespace of the application, e.g. `ConfigMap,Deployment.apps`")
+	flag.DurationVar(&controllerArgs.PackageRefreshInterval, "kube-packages-refresh-interval", 10*time.Minute,
+		"The interval to refresh all CUE kube packages from the cluster, they're still refreshed when CRDs change if it's 0.")
+	flag.BoolVar(&controllerArgs.DriftDetection, "enable-drift-detection", false,
+		"Watch the resources dispatched by applications for the changes made out of KubeVela, which are reported or repaired by the "+oam.AnnotationDriftPolicy+" annotation of the application.")
+	flag.IntVar(&templateCacheSize, "definition-template-cache-size", definition.DefaultTemplateCacheSize,
+		"The number of compiled definition templates cached for rendering, the cache is disabled if it's 0")
 	flag.StringVar(&controllerArgs.OAMSpecVer, "oam-spec-ver", "v0.3", "oam-spec-ver is the oam spec version controller want to setup, available options: v0.2, v0.3, all")
 
 	flag.Parse()
@@ -180,7 +193,12 @@ func main() {
 		}
 	}
 	controllerArgs.PackageDiscover = pd
+	kube.Register(mgr.GetAPIReader(), strings.Split(kubeTaskReadableKinds, ","))
+	builtinhttp.Register(mgr.GetAPIReader())
+	definition.SetTemplateCacheSize(templateCacheSize)
 
+	// the approvers of workflow approvals are only trustworthy if they're stamped by the webhook
+	controllerArgs.WorkflowApprovalsStamped = useWebhook
 	if useWebhook {
 		klog.InfoS("Enable webhook", "server port", strconv.Itoa(webhookPort))
 		oamwebhook.Register(mgr, controllerArgs)
@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+
+# Fetch the OpenAPI documents of Kubernetes releases into the snapshots embedded by pkg/cue/packages.
+
+set -euo pipefail
+
+VERSIONS=${VERSIONS:-"v1.18 v1.19 v1.20 v1.21"}
+DIR=$(cd "$(dirname "${BASH_SOURCE[0]}")/../../pkg/cue/packages/openapi" && pwd)
+
+for version in ${VERSIONS}; do
+  echo "fetching the OpenAPI document of kubernetes ${version}"
+  curl -sSfL "https://raw.githubusercontent.com/kubernetes/kubernetes/release-${version#v}/api/openapi-spec/swagger.json" \
+    | gzip -9 > "${DIR}/${version}.json.gz"
+done
@@ -39,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
 	"github.com/oam-dev/kubevela/apis/types"
 	"github.com/oam-dev/kubevela/pkg/appfile/helm"
 	"github.com/oam-dev/kubevela/pkg/cue/definition"
@@ -68,6 +69,7 @@ type Workload struct {
 	Scopes             []Scope
 	FullTemplate       *Template
 	engine             definition.AbstractEngine
+	stepEngine         definition.WorkflowStepEngine
 	// OutputSecretName is the secret name which this workload will generate after it successfully generate a cloud resource
 	OutputSecretName string
 	// RequiredSecrets stores secret names which the workload needs from cloud resource component and its context
@@ -75,6 +77,13 @@ type Workload struct {
 	UserConfigs     []map[string]string
 	// ConfigNotReady indicates there's RequiredSecrets and UserConfigs but they're not ready yet.
 	ConfigNotReady bool
+	// Inputs are the outputs of other components filled into the params of the workload.
+	Inputs []v1beta1.InputItem
+	// Outputs are the values exported from the resources of the workload for other components.
+	Outputs []v1beta1.ComponentOutput
+	// InputsNotReady is why the inputs of the workload are not resolved yet, e.g. an output it takes has no value.
+	// The workload is not rendered until it's empty.
+	InputsNotReady string
 }
 
 // GetUserConfigName get user config from AppFile, it will contain config file in it.
@@ -95,7 +104,19 @@ func (wl *Workload) GetUserConfigName() string {
 
 // EvalContext eval workload template and set result to context
 func (wl *Workload) EvalContext(ctx process.Context) error {
-	return wl.engine.Complete(ctx, wl.FullTemplate.TemplateStr, wl.Params)
+	err := wl.engine.Complete(ctx, wl.FullTemplate.TemplateStr, wl.Params)
+	defName, defRevision := wl.FullTemplate.definitionRevision()
+	return definition.WithDefinition(err, defName, defRevision)
+}
+
+// EvalWorkflowStep eval the template of workflow step to a CUE instance which will be executed by workflow
+func (wl *Workload) EvalWorkflowStep(ctx process.Context) (*cue.Instance, error) {
+	if wl.stepEngine == nil {
+		return nil, errors.Errorf("%s is not a workflow step", wl.Name)
+	}
+	inst, err := wl.stepEngine.Evaluate(ctx, wl.FullTemplate.TemplateStr, wl.Params)
+	defName, defRevision := wl.FullTemplate.definitionRevision()
+	return inst, definition.WithDefinition(err, defName, defRevision)
 }
 
 // EvalStatus eval workload status
@@ -151,7 +172,9 @@ type Trait struct {
 
 // EvalContext eval trait template and set result to context
 func (trait *Trait) EvalContext(ctx process.Context) error {
-	return trait.engine.Complete(ctx, trait.Template, trait.Params)
+	err := trait.engine.Complete(ctx, trait.Template, trait.Params)
+	defName, defRevision := trait.FullTemplate.definitionRevision()
+	return definition.WithDefinition(err, defName, defRevision)
 }
 
 // EvalStatus eval trait status
@@ -181,23 +204,42 @@ type Appfile struct {
 	RevisionName string
 	Workloads    []*Workload
 
-	Policies      []*Workload
+	Policies []*Workload
+	// Workflow is the workflow of the application with WorkflowTemplates resolved and step groups expanded
+	Workflow      *v1beta1.Workflow
 	WorkflowSteps []*Workload
 }
 
 // GenerateWorkflowAndPolicy generates workflow steps and policies from an appFile
-func (af *Appfile) GenerateWorkflowAndPolicy() (policies, steps []*unstructured.Unstructured, err error) {
+func (af *Appfile) GenerateWorkflowAndPolicy() (policies []*unstructured.Unstructured, steps []*cue.Instance, err error) {
 	policies, err = af.generateUnstructureds(af.Policies)
 	if err != nil {
 		return
 	}
-	steps, err = af.generateUnstructureds(af.WorkflowSteps)
+	steps, err = af.generateWorkflowSteps()
 	if err != nil {
 		return
 	}
 	return
 }
 
+func (af *Appfile) generateWorkflowSteps() ([]*cue.Instance, error) {
+	steps := []*cue.Instance{}
+	for _, wl := range af.WorkflowSteps {
+		if types.IsBuiltinWorkflowStep(wl.Type) {
+			steps = append(steps, nil)
+			continue
+		}
+		pCtx := NewBasicContext(wl, af.Name, af.RevisionName, af.Namespace)
+		step, err := wl.EvalWorkflowStep(pCtx)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "evaluate workflow step %s", wl.Name)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
 func (af *Appfile) generateUnstructureds(workloads []*Workload) ([]*unstructured.Unstructured, error) {
 	uns := []*unstructured.Unstructured{}
 	for _, wl := range workloads {
@@ -233,7 +275,7 @@ func (af *Appfile) GenerateComponentManifests() ([]*types.ComponentManifest, err
 
 // GenerateComponentManifest generate only one ComponentManifest
 func (af *Appfile) GenerateComponentManifest(wl *Workload) (*types.ComponentManifest, error) {
-	if wl.ConfigNotReady {
+	if wl.ConfigNotReady || wl.InputsNotReady != "" {
 		return &types.ComponentManifest{
 			Name:                 wl.Name,
 			InsertConfigNotReady: true,
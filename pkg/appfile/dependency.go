@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+// ComponentDependencies returns the names of the components each component depends on,
+// which are the components in its dependsOn and the components producing its inputs.
+func ComponentDependencies(comps []v1beta1.ApplicationComponent) map[string][]string {
+	producers := make(map[string]string)
+	for _, comp := range comps {
+		for _, output := range comp.Outputs {
+			producers[output.Name] = comp.Name
+		}
+	}
+	deps := make(map[string][]string, len(comps))
+	for _, comp := range comps {
+		seen := make(map[string]bool)
+		for _, dep := range comp.DependsOn {
+			if !seen[dep] {
+				seen[dep] = true
+				deps[comp.Name] = append(deps[comp.Name], dep)
+			}
+		}
+		for _, input := range comp.Inputs {
+			// the inputs taking unknown outputs are rejected by the parser
+			if dep, ok := producers[input.From]; ok && !seen[dep] {
+				seen[dep] = true
+				deps[comp.Name] = append(deps[comp.Name], dep)
+			}
+		}
+	}
+	return deps
+}
+
+// SortComponents returns the names of the components in topological order of their dependencies, the array order
+// is kept among independent components. It returns nil if none of the components depends on others.
+// An error is returned if a component depends on a component which does not exist or the dependencies form a cycle.
+func SortComponents(comps []v1beta1.ApplicationComponent) ([]string, error) {
+	deps := ComponentDependencies(comps)
+	if len(deps) == 0 {
+		return nil, nil
+	}
+	index := make(map[string]int, len(comps))
+	for i, comp := range comps {
+		index[comp.Name] = i
+	}
+	for _, comp := range comps {
+		for _, dep := range deps[comp.Name] {
+			if _, ok := index[dep]; !ok {
+				return nil, errors.Errorf("component %q depends on component %q which does not exist", comp.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(comps))
+	order := make([]string, 0, len(comps))
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("components have cyclic dependencies: %s -> %s", strings.Join(path, " -> "), comps[i].Name)
+		}
+		state[i] = visiting
+		path = append(path, comps[i].Name)
+		for _, dep := range deps[comps[i].Name] {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		order = append(order, comps[i].Name)
+		return nil
+	}
+
+	for i := range comps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+func TestSortComponents(t *testing.T) {
+	comp := func(name string, deps ...string) v1beta1.ApplicationComponent {
+		return v1beta1.ApplicationComponent{Name: name, Type: "worker", DependsOn: deps}
+	}
+
+	// nil is returned if no component declares dependsOn
+	order, err := SortComponents([]v1beta1.ApplicationComponent{comp("a"), comp("b")})
+	assert.NoError(t, err)
+	assert.Nil(t, order)
+
+	// the components are sorted in topological order, and the array order of independent ones is kept
+	order, err = SortComponents([]v1beta1.ApplicationComponent{
+		comp("web", "migrate", "cache"), comp("migrate", "db"), comp("db"), comp("cache"), comp("worker"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "migrate", "cache", "web", "worker"}, order)
+
+	// the components depend on the components producing their inputs
+	web := comp("web")
+	web.Inputs = []v1beta1.InputItem{{From: "db-host", ParameterKey: "env.dbHost"}}
+	db := comp("db")
+	db.Outputs = []v1beta1.ComponentOutput{{Name: "db-host", ValueFrom: "context.output.spec.clusterIP"}}
+	order, err = SortComponents([]v1beta1.ApplicationComponent{web, db})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "web"}, order)
+	web.DependsOn = []string{"db"}
+	assert.Equal(t, map[string][]string{"web": {"db"}}, ComponentDependencies([]v1beta1.ApplicationComponent{web, db}))
+
+	_, err = SortComponents([]v1beta1.ApplicationComponent{comp("web", "db")})
+	assert.EqualError(t, err, `component "web" depends on component "db" which does not exist`)
+	_, err = SortComponents([]v1beta1.ApplicationComponent{comp("a", "b"), comp("b", "c"), comp("c", "a")})
+	assert.EqualError(t, err, "components have cyclic dependencies: a -> b -> c -> a")
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"context"
+	"fmt"
+
+	"cuelang.org/go/cue/parser"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/cue/definition"
+)
+
+// EvalOutput evaluates an output of the workload over its resources in the cluster.
+// `context.outputs` has the auxiliary resources of the workload and the resources of its traits,
+// and `context.output` of a Terraform workload is its Configuration.
+func (wl *Workload) EvalOutput(cli client.Client, appName, revision, ns string, output v1beta1.ComponentOutput) (interface{}, error) {
+	if wl.CapabilityCategory == types.TerraformCategory {
+		configuration := &unstructured.Unstructured{}
+		configuration.SetAPIVersion("terraform.core.oam.dev/v1beta1")
+		configuration.SetKind("Configuration")
+		if err := cli.Get(context.Background(), client.ObjectKey{Name: wl.Name, Namespace: ns}, configuration); err != nil {
+			return nil, errors.WithMessage(err, "get terraform configuration")
+		}
+		return definition.EvalOutput(map[string]interface{}{definition.OutputFieldName: configuration.Object}, output.ValueFrom)
+	}
+
+	pCtx, err := PrepareProcessContext(wl, appName, revision, ns)
+	if err != nil {
+		return nil, err
+	}
+	templateContext, err := wl.engine.GetTemplateContext(pCtx, cli, ns)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get template context")
+	}
+	outputs, _ := templateContext[definition.OutputsFieldName].(map[string]interface{})
+	if outputs == nil {
+		outputs = make(map[string]interface{})
+	}
+	for _, tr := range wl.Traits {
+		if err := tr.EvalContext(pCtx); err != nil {
+			return nil, errors.WithMessagef(err, "evaluate template trait=%s", tr.Name)
+		}
+		traitContext, err := tr.engine.GetTemplateContext(pCtx, cli, ns)
+		if err != nil {
+			// the resources of the trait may not be created yet, the outputs referring to them are not concrete then
+			klog.InfoS("Cannot get the resources of trait for component outputs", "component", wl.Name, "trait", tr.Name, "err", err)
+			continue
+		}
+		traitOutputs, _ := traitContext[definition.OutputsFieldName].(map[string]interface{})
+		for name, object := range traitOutputs {
+			outputs[name] = object
+		}
+	}
+	if len(outputs) > 0 {
+		templateContext[definition.OutputsFieldName] = outputs
+	}
+	return definition.EvalOutput(templateContext, output.ValueFrom)
+}
+
+// resolveComponentInputs fills the outputs of components into the params of the workloads taking them as inputs.
+// The outputs are evaluated over the resources of the producing components in the cluster, a workload is left
+// not rendered with the reason in InputsNotReady until all of its inputs have values.
+func (p *Parser) resolveComponentInputs(app *v1beta1.Application, wds []*Workload) error {
+	producers := make(map[string]*Workload)
+	outputs := make(map[string]v1beta1.ComponentOutput)
+	for _, wl := range wds {
+		for _, output := range wl.Outputs {
+			if producer, ok := producers[output.Name]; ok {
+				return errors.Errorf("output %q of component %q is already exported by component %q", output.Name, wl.Name, producer.Name)
+			}
+			if _, err := parser.ParseExpr("-", output.ValueFrom); err != nil {
+				return errors.WithMessagef(err, "invalid valueFrom of output %q of component %q", output.Name, wl.Name)
+			}
+			producers[output.Name] = wl
+			outputs[output.Name] = output
+		}
+	}
+
+	var revision string
+	if app.Status.LatestRevision != nil {
+		revision = app.Status.LatestRevision.Name
+	}
+	values := make(map[string]interface{})
+	reasons := make(map[string]string)
+	// the outputs are evaluated once no matter how many components take them
+	getOutput := func(name string) (interface{}, string) {
+		if value, ok := values[name]; ok {
+			return value, ""
+		}
+		if reason, ok := reasons[name]; ok {
+			return nil, reason
+		}
+		producer := producers[name]
+		var value interface{}
+		var err error
+		switch {
+		case p.client == nil:
+			err = errors.New("no cluster to get the resources from")
+		case producer.ConfigNotReady || producer.InputsNotReady != "":
+			err = errors.New("the component is not rendered yet")
+		default:
+			value, err = producer.EvalOutput(p.client, app.Name, revision, app.Namespace, outputs[name])
+		}
+		if err != nil {
+			reasons[name] = fmt.Sprintf("waiting for output %s of component %s: %s", name, producer.Name, err.Error())
+			return nil, reasons[name]
+		}
+		values[name] = value
+		return value, ""
+	}
+
+	for _, wl := range wds {
+		for _, input := range wl.Inputs {
+			producer, ok := producers[input.From]
+			if !ok {
+				return errors.Errorf("component %q takes output %q which does not exist", wl.Name, input.From)
+			}
+			if producer == wl {
+				return errors.Errorf("component %q takes its own output %q", wl.Name, input.From)
+			}
+		}
+	}
+	// the dependencies are checked as they're when dispatching the components, so a cycle is rejected by parsing
+	if _, err := SortComponents(app.Spec.Components); err != nil {
+		return err
+	}
+	// the outputs are evaluated by rendering the producers, so they're resolved before the workloads taking their outputs
+	resolved := make(map[*Workload]bool, len(wds))
+	var resolve func(wl *Workload) error
+	resolve = func(wl *Workload) error {
+		if resolved[wl] {
+			return nil
+		}
+		resolved[wl] = true
+		for _, input := range wl.Inputs {
+			if err := resolve(producers[input.From]); err != nil {
+				return err
+			}
+		}
+		for _, input := range wl.Inputs {
+			value, reason := getOutput(input.From)
+			if reason != "" {
+				klog.InfoS("Component inputs are not ready", "application", klog.KObj(app), "component", wl.Name, "reason", reason)
+				wl.InputsNotReady = reason
+				return nil
+			}
+			if wl.Params == nil {
+				wl.Params = make(map[string]interface{})
+			}
+			if err := fieldpath.Pave(wl.Params).SetValue(input.ParameterKey, value); err != nil {
+				return errors.WithMessagef(err, "fill output %s into %s of component %s", input.From, input.ParameterKey, wl.Name)
+			}
+		}
+		return nil
+	}
+	for _, wl := range wds {
+		if err := resolve(wl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/assert"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/cue/definition"
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+)
+
+func TestResolveComponentInputs(t *testing.T) {
+	const serviceTemplate = `
+output: {
+	apiVersion: "v1"
+	kind:       "Service"
+	metadata: name: context.name
+	spec: ports: [{port: parameter.port}]
+}
+parameter: port: int
+`
+	// clusterIPs are the services in the cluster by name
+	var clusterIPs map[string]string
+	// ports are the ports of the services as they're applied
+	ports := map[string]int64{"db": 3306, "proxy": 3306}
+	tclient := test.MockClient{
+		MockGet: func(ctx context.Context, key ktypes.NamespacedName, obj runtime.Object) error {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok || u.GetKind() != "Service" {
+				return nil
+			}
+			ip, ok := clusterIPs[key.Name]
+			if !ok {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "services"}, key.Name)
+			}
+			u.SetName(key.Name)
+			u.Object["spec"] = map[string]interface{}{
+				"clusterIP": ip,
+				"ports":     []interface{}{map[string]interface{}{"port": ports[key.Name]}},
+			}
+			return nil
+		},
+	}
+	p := &Parser{client: &tclient}
+	app := &v1beta1.Application{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+
+	workload := func(name string, params map[string]interface{}) *Workload {
+		return &Workload{
+			Name:         name,
+			Type:         "service",
+			Params:       params,
+			FullTemplate: &Template{TemplateStr: serviceTemplate},
+			engine:       definition.NewWorkloadAbstractEngine(name, &packages.PackageDiscover{}),
+		}
+	}
+	// db exports its clusterIP to proxy, which exports its own clusterIP to web
+	workloads := func() []*Workload {
+		web := workload("web", map[string]interface{}{"port": 80, "env": map[string]interface{}{"debug": true}})
+		web.Inputs = []v1beta1.InputItem{{From: "proxy-host", ParameterKey: "env.proxyHost"}}
+		proxy := workload("proxy", nil)
+		proxy.Inputs = []v1beta1.InputItem{{From: "db-port", ParameterKey: "port"}}
+		proxy.Outputs = []v1beta1.ComponentOutput{{Name: "proxy-host", ValueFrom: "context.output.spec.clusterIP"}}
+		db := workload("db", map[string]interface{}{"port": 3306})
+		db.Outputs = []v1beta1.ComponentOutput{
+			{Name: "db-port", ValueFrom: "context.output.spec.ports[0].port"},
+			{Name: "db-host", ValueFrom: "context.output.spec.clusterIP"},
+		}
+		return []*Workload{web, proxy, db}
+	}
+
+	clusterIPs = map[string]string{}
+	wds := workloads()
+	assert.NoError(t, p.resolveComponentInputs(app, wds))
+	assert.Contains(t, wds[1].InputsNotReady, `waiting for output db-port of component db: get template context`)
+	assert.Equal(t, "waiting for output proxy-host of component proxy: the component is not rendered yet", wds[0].InputsNotReady)
+	assert.Empty(t, wds[2].InputsNotReady)
+
+	clusterIPs = map[string]string{"db": "10.0.0.1"}
+	wds = workloads()
+	assert.NoError(t, p.resolveComponentInputs(app, wds))
+	assert.Empty(t, wds[1].InputsNotReady)
+	assert.Equal(t, int64(3306), wds[1].Params["port"])
+	assert.Contains(t, wds[0].InputsNotReady, "waiting for output proxy-host of component proxy: get template context")
+
+	clusterIPs = map[string]string{"db": "10.0.0.1", "proxy": "10.0.0.2"}
+	wds = workloads()
+	assert.NoError(t, p.resolveComponentInputs(app, wds))
+	assert.Empty(t, wds[0].InputsNotReady)
+	assert.Equal(t, map[string]interface{}{"debug": true, "proxyHost": "10.0.0.2"}, wds[0].Params["env"])
+
+	invalid := map[string]func(wds []*Workload){
+		`component "web" takes output "cache-host" which does not exist`: func(wds []*Workload) {
+			wds[0].Inputs = []v1beta1.InputItem{{From: "cache-host", ParameterKey: "env.cacheHost"}}
+		},
+		`component "db" takes its own output "db-host"`: func(wds []*Workload) {
+			wds[2].Inputs = []v1beta1.InputItem{{From: "db-host", ParameterKey: "host"}}
+		},
+		`output "db-host" of component "db" is already exported by component "proxy"`: func(wds []*Workload) {
+			wds[1].Outputs = []v1beta1.ComponentOutput{{Name: "db-host", ValueFrom: "context.output.spec.clusterIP"}}
+		},
+		`invalid valueFrom of output "db-host" of component "db"`: func(wds []*Workload) {
+			wds[2].Outputs[1].ValueFrom = "context.output.spec.clusterIP)"
+		},
+	}
+	// the components taking the outputs of each other are rejected as the cyclic dependencies in dispatching
+	cyclic := app.DeepCopy()
+	cyclic.Spec.Components = []v1beta1.ApplicationComponent{
+		{Name: "web", Type: "service", Inputs: []v1beta1.InputItem{{From: "db-host", ParameterKey: "env.dbHost"}},
+			Outputs: []v1beta1.ComponentOutput{{Name: "web-host", ValueFrom: "context.output.spec.clusterIP"}}},
+		{Name: "db", Type: "service", Inputs: []v1beta1.InputItem{{From: "web-host", ParameterKey: "env.webHost"}},
+			Outputs: []v1beta1.ComponentOutput{{Name: "db-host", ValueFrom: "context.output.spec.clusterIP"}}},
+	}
+	assert.EqualError(t, p.resolveComponentInputs(cyclic, workloads()), "components have cyclic dependencies: web -> db -> web")
+
+	for msg, mutate := range invalid {
+		wds = workloads()
+		mutate(wds)
+		err := p.resolveComponentInputs(app, wds)
+		assert.Error(t, err, msg)
+		if err != nil {
+			assert.Contains(t, err.Error(), msg)
+		}
+	}
+}
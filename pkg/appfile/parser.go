@@ -106,6 +106,9 @@ func (p *Parser) GenerateAppFile(ctx context.Context, app *v1beta1.Application)
 
 		wds = append(wds, wd)
 	}
+	if err := p.resolveComponentInputs(app, wds); err != nil {
+		return nil, err
+	}
 	appfile.Workloads = wds
 
 	var err error
@@ -115,7 +118,7 @@ func (p *Parser) GenerateAppFile(ctx context.Context, app *v1beta1.Application)
 		return nil, fmt.Errorf("failed to parsePolicies: %w", err)
 	}
 
-	appfile.WorkflowSteps, err = p.parseWorkflow(ctx, app.Spec.Workflow)
+	appfile.Workflow, appfile.WorkflowSteps, err = p.parseWorkflow(ctx, app.Spec.Workflow)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parseWorkflow: %w", err)
 	}
@@ -134,20 +137,32 @@ func (p *Parser) parsePolicies(ctx context.Context, policies []v1beta1.AppPolicy
 	return ws, nil
 }
 
-func (p *Parser) parseWorkflow(ctx context.Context, workflow *v1beta1.Workflow) ([]*Workload, error) {
+// parseWorkflow resolves the WorkflowTemplates and step groups of the workflow,
+// and returns the resolved workflow with a Workload for each of its steps.
+func (p *Parser) parseWorkflow(ctx context.Context, workflow *v1beta1.Workflow) (*v1beta1.Workflow, []*Workload, error) {
+	workflow, err := p.resolveWorkflow(ctx, workflow)
+	if err != nil {
+		return nil, nil, err
+	}
 	if workflow == nil {
-		return []*Workload{}, nil
+		return nil, []*Workload{}, nil
 	}
 	steps := workflow.Steps
 	ws := []*Workload{}
 	for _, step := range steps {
+		if types.IsBuiltinWorkflowStep(step.Type) {
+			// the built-in steps are executed by the workflow itself, they have no template
+			ws = append(ws, &Workload{Name: step.Name, Type: step.Type, Traits: []*Trait{}})
+			continue
+		}
 		w, err := p.makeWorkload(ctx, step.Name, step.Type, types.TypeWorkflowStep, step.Properties)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		w.stepEngine = definition.NewWorkflowStepEngine(step.Name, p.pd)
 		ws = append(ws, w)
 	}
-	return ws, nil
+	return workflow, ws, nil
 }
 
 func (p *Parser) makeWorkload(ctx context.Context, name, typ string, capType types.CapType, props runtime.RawExtension) (*Workload, error) {
@@ -183,6 +198,8 @@ func (p *Parser) parseWorkload(ctx context.Context, comp v1beta1.ApplicationComp
 	if err != nil {
 		return nil, err
 	}
+	workload.Inputs = comp.Inputs
+	workload.Outputs = comp.Outputs
 
 	for _, traitValue := range comp.Traits {
 		properties, err := util.RawExtension2Map(&traitValue.Properties)
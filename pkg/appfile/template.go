@@ -64,6 +64,31 @@ type Template struct {
 	WorkflowStepDefinition *v1beta1.WorkflowStepDefinition
 }
 
+// definitionRevision returns the name of the definition of the template and its latest revision,
+// the revision is empty if it's unknown.
+func (t *Template) definitionRevision() (string, string) {
+	var name string
+	var rev *common.Revision
+	switch {
+	case t == nil:
+		return "", ""
+	case t.ComponentDefinition != nil:
+		name, rev = t.ComponentDefinition.Name, t.ComponentDefinition.Status.LatestRevision
+	case t.TraitDefinition != nil:
+		name, rev = t.TraitDefinition.Name, t.TraitDefinition.Status.LatestRevision
+	case t.PolicyDefinition != nil:
+		name, rev = t.PolicyDefinition.Name, t.PolicyDefinition.Status.LatestRevision
+	case t.WorkflowStepDefinition != nil:
+		name, rev = t.WorkflowStepDefinition.Name, t.WorkflowStepDefinition.Status.LatestRevision
+	case t.WorkloadDefinition != nil:
+		name = t.WorkloadDefinition.Name
+	}
+	if rev == nil {
+		return name, ""
+	}
+	return name, rev.Name
+}
+
 // LoadTemplate gets the capability definition from cluster and resolve it.
 // It returns a helper struct, Template, which will be used for further
 // processing.
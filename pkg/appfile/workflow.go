@@ -0,0 +1,267 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/ast/astutil"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	velacue "github.com/oam-dev/kubevela/pkg/cue"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// WorkflowTemplateStepsField is the field of a WorkflowTemplate rendering the workflow steps
+const WorkflowTemplateStepsField = "steps"
+
+// resolveWorkflow renders the WorkflowTemplate referenced by the workflow and expands the step groups,
+// so the workflow executes a flat list of steps.
+func (p *Parser) resolveWorkflow(ctx context.Context, workflow *v1beta1.Workflow) (*v1beta1.Workflow, error) {
+	if workflow == nil || (workflow.Ref == "" && !hasStepGroup(workflow.Steps)) {
+		return workflow, nil
+	}
+	steps := workflow.Steps
+	var refs []string
+	if workflow.Ref != "" {
+		if len(workflow.Steps) > 0 {
+			return nil, errors.New("workflow cannot have both ref and steps")
+		}
+		var err error
+		if steps, err = p.renderWorkflowTemplate(ctx, workflow.Ref, workflow.Properties); err != nil {
+			return nil, err
+		}
+		refs = append(refs, workflow.Ref)
+	}
+	steps, err := p.expandSteps(ctx, steps, refs)
+	if err != nil {
+		return nil, err
+	}
+	return &v1beta1.Workflow{Steps: steps}, nil
+}
+
+// expandSteps replaces the step groups with their steps,
+// refs are the WorkflowTemplates being expanded so a template expanding itself is detected.
+func (p *Parser) expandSteps(ctx context.Context, steps []v1beta1.WorkflowStep, refs []string) ([]v1beta1.WorkflowStep, error) {
+	if !hasStepGroup(steps) {
+		return steps, nil
+	}
+	// the steps in groups may be executed as a DAG, so the order of sequential steps is kept by dependsOn
+	steps = chainSteps(steps)
+	exits := make(map[string][]string, len(steps))
+	var expanded []v1beta1.WorkflowStep
+	for _, step := range steps {
+		if step.Type != types.WorkflowStepTypeStepGroup {
+			exits[step.Name] = []string{step.Name}
+			expanded = append(expanded, step)
+			continue
+		}
+		subSteps, groupExits, err := p.expandStepGroup(ctx, step, refs)
+		if err != nil {
+			return nil, err
+		}
+		exits[step.Name] = groupExits
+		expanded = append(expanded, subSteps...)
+	}
+	// the steps depending on a group wait for the steps of the group which no other steps in it depend on
+	for i := range expanded {
+		var deps []string
+		for _, name := range expanded[i].DependsOn {
+			if names, ok := exits[name]; ok {
+				deps = append(deps, names...)
+			} else {
+				deps = append(deps, name)
+			}
+		}
+		expanded[i].DependsOn = deps
+	}
+	return expanded, nil
+}
+
+// expandStepGroup returns the steps of the group named `<group>-<step>` and the names of its exit steps.
+func (p *Parser) expandStepGroup(ctx context.Context, group v1beta1.WorkflowStep, refs []string) ([]v1beta1.WorkflowStep, []string, error) {
+	if group.If != "" || len(group.Inputs) > 0 || len(group.Outputs) > 0 || group.Timeout != "" ||
+		group.MaxRetries > 0 || group.Backoff != "" || group.RecreateOnRetry {
+		return nil, nil, errors.Errorf("step group %s only supports dependsOn, ref, properties and subSteps", group.Name)
+	}
+	subSteps := group.SubSteps
+	if group.Ref != "" {
+		if len(subSteps) > 0 {
+			return nil, nil, errors.Errorf("step group %s cannot have both ref and subSteps", group.Name)
+		}
+		for _, ref := range refs {
+			if ref == group.Ref {
+				return nil, nil, errors.Errorf("workflow template %s expands itself in step group %s", ref, group.Name)
+			}
+		}
+		var err error
+		if subSteps, err = p.renderWorkflowTemplate(ctx, group.Ref, group.Properties); err != nil {
+			return nil, nil, err
+		}
+		refs = append(refs[:len(refs):len(refs)], group.Ref)
+	}
+	if len(subSteps) == 0 {
+		return nil, nil, errors.Errorf("step group %s has no steps", group.Name)
+	}
+	subSteps, err := p.expandSteps(ctx, chainSteps(subSteps), refs)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "expand step group %s", group.Name)
+	}
+
+	renamed := make(map[string]string, len(subSteps))
+	for _, step := range subSteps {
+		renamed[step.Name] = group.Name + "-" + step.Name
+	}
+	dependedOn := make(map[string]bool, len(subSteps))
+	for i := range subSteps {
+		step := &subSteps[i]
+		var deps []string
+		for _, name := range step.DependsOn {
+			if newName, ok := renamed[name]; ok {
+				dependedOn[name] = true
+				name = newName
+			}
+			deps = append(deps, name)
+		}
+		if len(deps) == 0 {
+			deps = append(deps, group.DependsOn...)
+		}
+		step.DependsOn = deps
+		if step.If != "" {
+			if step.If, err = renameStepReferences(*step, renamed); err != nil {
+				return nil, nil, errors.WithMessagef(err, "expand step group %s", group.Name)
+			}
+		}
+	}
+	var exits []string
+	for i := range subSteps {
+		if !dependedOn[subSteps[i].Name] {
+			exits = append(exits, group.Name+"-"+subSteps[i].Name)
+		}
+		subSteps[i].Name = group.Name + "-" + subSteps[i].Name
+	}
+	return subSteps, exits, nil
+}
+
+// renameStepReferences rewrites the references to the renamed steps in the `if` expression of the step,
+// e.g. `steps.build` and `steps["build"]` are rewritten to `steps["group-build"]`.
+func renameStepReferences(step v1beta1.WorkflowStep, renamed map[string]string) (string, error) {
+	expr, err := parser.ParseExpr("if", step.If)
+	if err != nil {
+		return "", errors.WithMessagef(err, "invalid if expression of workflow step %s", step.Name)
+	}
+	node := astutil.Apply(expr, func(c astutil.Cursor) bool {
+		var x ast.Expr
+		var name string
+		switch v := c.Node().(type) {
+		case *ast.SelectorExpr:
+			x, name = v.X, v.Sel.Name
+		case *ast.IndexExpr:
+			lit, ok := v.Index.(*ast.BasicLit)
+			if !ok {
+				return true
+			}
+			unquoted, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			x, name = v.X, unquoted
+		default:
+			return true
+		}
+		newName, ok := renamed[name]
+		if ident, isIdent := x.(*ast.Ident); !ok || !isIdent || ident.Name != "steps" {
+			return true
+		}
+		c.Replace(&ast.IndexExpr{X: ast.NewIdent("steps"), Index: ast.NewString(newName)})
+		return false
+	}, nil)
+	b, err := format.Node(node)
+	if err != nil {
+		return "", errors.WithMessagef(err, "invalid if expression of workflow step %s", step.Name)
+	}
+	return string(b), nil
+}
+
+// renderWorkflowTemplate renders the steps of the WorkflowTemplate with the parameters in properties.
+func (p *Parser) renderWorkflowTemplate(ctx context.Context, name string, properties runtime.RawExtension) ([]v1beta1.WorkflowStep, error) {
+	if p.client == nil {
+		return nil, errors.Errorf("cannot fetch workflow template %s without a cluster", name)
+	}
+	tmpl := &v1beta1.WorkflowTemplate{}
+	if err := util.GetDefinition(ctx, p.client, tmpl, name); err != nil {
+		return nil, errors.WithMessagef(err, "fetch workflow template %s", name)
+	}
+	return renderWorkflowSteps(name, tmpl.Spec.Template, properties)
+}
+
+func renderWorkflowSteps(name, template string, properties runtime.RawExtension) ([]v1beta1.WorkflowStep, error) {
+	buf := bytes.NewBufferString(template)
+	if len(properties.Raw) > 0 {
+		fmt.Fprintf(buf, "\n%s: %s\n", velacue.ParameterTag, properties.Raw)
+	}
+	var r cue.Runtime
+	inst, err := r.Compile("-", buf.String())
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid workflow template %s", name)
+	}
+	b, err := inst.Lookup(WorkflowTemplateStepsField).MarshalJSON()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "render %s of workflow template %s", WorkflowTemplateStepsField, name)
+	}
+	var steps []v1beta1.WorkflowStep
+	if err := json.Unmarshal(b, &steps); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s of workflow template %s", WorkflowTemplateStepsField, name)
+	}
+	return steps, nil
+}
+
+func hasStepGroup(steps []v1beta1.WorkflowStep) bool {
+	for _, step := range steps {
+		if step.Type == types.WorkflowStepTypeStepGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// chainSteps copies the steps, and makes each step depend on its previous one if they are executed in array order.
+func chainSteps(steps []v1beta1.WorkflowStep) []v1beta1.WorkflowStep {
+	chained := make([]v1beta1.WorkflowStep, len(steps))
+	dag := false
+	for i := range steps {
+		steps[i].DeepCopyInto(&chained[i])
+		dag = dag || len(steps[i].DependsOn) > 0
+	}
+	if !dag {
+		for i := 1; i < len(chained); i++ {
+			chained[i].DependsOn = []string{chained[i-1].Name}
+		}
+	}
+	return chained
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/assert"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+func TestResolveWorkflow(t *testing.T) {
+	templates := map[string]string{
+		"canary": `
+parameter: {
+	replicas: *1 | int
+}
+steps: [{
+	name: "rollout"
+	type: "rollout"
+	properties: replicas: parameter.replicas
+}, {
+	name: "verify"
+	type: "verify"
+}]
+`,
+		"loop": `
+steps: [{
+	name: "again"
+	type: "step-group"
+	ref:  "loop"
+}]
+`,
+	}
+	tclient := test.MockClient{
+		MockGet: func(ctx context.Context, key ktypes.NamespacedName, obj runtime.Object) error {
+			o, ok := obj.(*v1beta1.WorkflowTemplate)
+			if !ok {
+				return nil
+			}
+			tmpl, ok := templates[key.Name]
+			if !ok {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "workflowtemplates"}, key.Name)
+			}
+			o.Name = key.Name
+			o.Spec.Template = tmpl
+			return nil
+		},
+	}
+	p := &Parser{client: &tclient}
+	ctx := oamutil.SetNamespaceInCtx(context.Background(), "default")
+
+	type step struct {
+		name      string
+		dependsOn []string
+	}
+	stepsOf := func(wf *v1beta1.Workflow) []step {
+		var steps []step
+		for _, s := range wf.Steps {
+			steps = append(steps, step{name: s.Name, dependsOn: s.DependsOn})
+		}
+		return steps
+	}
+
+	t.Run("without templates and groups", func(t *testing.T) {
+		wf := &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{{Name: "a", Type: "apply"}}}
+		resolved, err := p.resolveWorkflow(ctx, wf)
+		assert.NoError(t, err)
+		assert.Equal(t, wf, resolved)
+	})
+
+	t.Run("workflow template with parameters", func(t *testing.T) {
+		resolved, err := p.resolveWorkflow(ctx, &v1beta1.Workflow{
+			Ref:        "canary",
+			Properties: runtime.RawExtension{Raw: []byte(`{"replicas":3}`)},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []step{{name: "rollout"}, {name: "verify"}}, stepsOf(resolved))
+		assert.JSONEq(t, `{"replicas":3}`, string(resolved.Steps[0].Properties.Raw))
+		assert.Empty(t, resolved.Ref)
+	})
+
+	t.Run("sequential steps with a group", func(t *testing.T) {
+		wf := &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{
+			{Name: "a", Type: "apply"},
+			{Name: "g", Type: "step-group", SubSteps: []v1beta1.WorkflowStep{
+				{Name: "x", Type: "apply"},
+				{Name: "y", Type: "apply"},
+			}},
+			{Name: "b", Type: "apply"},
+		}}
+		origin := wf.DeepCopy()
+		resolved, err := p.resolveWorkflow(ctx, wf)
+		assert.NoError(t, err)
+		assert.Equal(t, []step{
+			{name: "a"},
+			{name: "g-x", dependsOn: []string{"a"}},
+			{name: "g-y", dependsOn: []string{"g-x"}},
+			{name: "b", dependsOn: []string{"g-y"}},
+		}, stepsOf(resolved))
+		assert.Equal(t, origin, wf)
+	})
+
+	t.Run("DAG with a group of template", func(t *testing.T) {
+		resolved, err := p.resolveWorkflow(ctx, &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{
+			{Name: "build", Type: "build"},
+			{Name: "deploy", Type: "step-group", Ref: "canary", DependsOn: []string{"build"},
+				Properties: runtime.RawExtension{Raw: []byte(`{"replicas":2}`)}},
+			{Name: "notify", Type: "notify", DependsOn: []string{"deploy"}},
+			{Name: "parallel", Type: "step-group", SubSteps: []v1beta1.WorkflowStep{
+				{Name: "x", Type: "apply"},
+				{Name: "y", Type: "apply", DependsOn: []string{"x"}},
+				{Name: "z", Type: "apply", DependsOn: []string{"x"}},
+			}},
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, []step{
+			{name: "build"},
+			{name: "deploy-rollout", dependsOn: []string{"build"}},
+			{name: "deploy-verify", dependsOn: []string{"deploy-rollout"}},
+			{name: "notify", dependsOn: []string{"deploy-verify"}},
+			{name: "parallel-x"},
+			{name: "parallel-y", dependsOn: []string{"parallel-x"}},
+			{name: "parallel-z", dependsOn: []string{"parallel-x"}},
+		}, stepsOf(resolved))
+		assert.JSONEq(t, `{"replicas":2}`, string(resolved.Steps[1].Properties.Raw))
+	})
+
+	t.Run("group with a step referring to its sibling", func(t *testing.T) {
+		resolved, err := p.resolveWorkflow(ctx, &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{
+			{Name: "build", Type: "build"},
+			{Name: "g", Type: "step-group", SubSteps: []v1beta1.WorkflowStep{
+				{Name: "deploy", Type: "apply"},
+				{Name: "rollback", Type: "apply", If: `steps.deploy.phase == "failed" && steps["build"].phase == "succeeded"`},
+			}},
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, []step{
+			{name: "build"},
+			{name: "g-deploy", dependsOn: []string{"build"}},
+			{name: "g-rollback", dependsOn: []string{"g-deploy"}},
+		}, stepsOf(resolved))
+		// the sibling is referred by its expanded name, and the steps out of the group are kept
+		assert.Equal(t, `steps["g-deploy"].phase == "failed" && steps["build"].phase == "succeeded"`, resolved.Steps[2].If)
+	})
+
+	errCases := map[string]struct {
+		workflow *v1beta1.Workflow
+		err      string
+	}{
+		"ref with steps": {
+			workflow: &v1beta1.Workflow{Ref: "canary", Steps: []v1beta1.WorkflowStep{{Name: "a", Type: "apply"}}},
+			err:      "workflow cannot have both ref and steps",
+		},
+		"template not found": {
+			workflow: &v1beta1.Workflow{Ref: "unknown"},
+			err:      "fetch workflow template unknown",
+		},
+		"template expands itself": {
+			workflow: &v1beta1.Workflow{Ref: "loop"},
+			err:      "workflow template loop expands itself in step group again",
+		},
+		"empty group": {
+			workflow: &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{{Name: "g", Type: "step-group"}}},
+			err:      "step group g has no steps",
+		},
+		"invalid if expression in group": {
+			workflow: &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{{Name: "g", Type: "step-group", SubSteps: []v1beta1.WorkflowStep{
+				{Name: "x", Type: "apply", If: "steps.y.phase =="},
+			}}}},
+			err: "invalid if expression of workflow step x",
+		},
+		"group with timeout": {
+			workflow: &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{{Name: "g", Type: "step-group", Ref: "canary", Timeout: "1m"}}},
+			err:      "step group g only supports dependsOn, ref, properties and subSteps",
+		},
+	}
+	for name, tc := range errCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.resolveWorkflow(ctx, tc.workflow)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
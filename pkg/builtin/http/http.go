@@ -17,44 +17,137 @@ limitations under the License.
 package http
 
 import (
+	"bytes"
 	"context"
-	"io"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/oam-dev/kubevela/pkg/builtin/kube"
 	"github.com/oam-dev/kubevela/pkg/builtin/registry"
 )
 
+const (
+	// TaskName is the key of the http task in the registry and in `processing`
+	TaskName = "http"
+	// DefaultTimeout is the timeout of a request if the task doesn't set `timeout`
+	DefaultTimeout = 30 * time.Second
+	// defaultRetryInterval is the interval between attempts if the task doesn't set `retry.interval`
+	defaultRetryInterval = time.Second
+	// maxErrorBodyLength is the max length of the response body put in the error of a failed request
+	maxErrorBodyLength = 512
+)
+
 func init() {
-	registry.RegisterRunner("http", newHTTPCmd)
+	registry.RegisterRunner(TaskName, newHTTPCmd)
+}
+
+// Register registers the http task reading the secrets of its credentials by the client.
+// Without it, the http task works but credentials can't be read from secrets.
+func Register(cli client.Reader) {
+	registry.RegisterRunner(TaskName, func(v cue.Value) (registry.Runner, error) {
+		return &HTTPCmd{Client: http.DefaultClient, secrets: cli}, nil
+	})
 }
 
 // HTTPCmd provides methods for http task
 type HTTPCmd struct {
 	*http.Client
+	secrets client.Reader
 }
 
 func newHTTPCmd(v cue.Value) (registry.Runner, error) {
 	client := http.DefaultClient
-	return &HTTPCmd{client}, nil
+	return &HTTPCmd{Client: client}, nil
+}
+
+// options are the fields of the http task configuring how the request is sent
+type options struct {
+	// Timeout is the timeout of each attempt, e.g. `10s`
+	Timeout string       `json:"timeout,omitempty"`
+	TLS     *tlsOptions  `json:"tls,omitempty"`
+	Auth    *authOption  `json:"auth,omitempty"`
+	Retry   *retryOption `json:"retry,omitempty"`
+	// AllowErrorStatus lets the template handle non-2xx responses by `statusCode`,
+	// otherwise they fail the task
+	AllowErrorStatus bool `json:"allowErrorStatus,omitempty"`
+}
+
+// tlsOptions configures the TLS connection, the certificates and keys are in PEM
+type tlsOptions struct {
+	CA                 *ValueSource `json:"ca,omitempty"`
+	Cert               *ValueSource `json:"cert,omitempty"`
+	Key                *ValueSource `json:"key,omitempty"`
+	InsecureSkipVerify bool         `json:"insecureSkipVerify,omitempty"`
+}
+
+// authOption is the credential sent in the Authorization header
+type authOption struct {
+	Basic  *basicAuth  `json:"basic,omitempty"`
+	Bearer *bearerAuth `json:"bearer,omitempty"`
+}
+
+type basicAuth struct {
+	Username ValueSource `json:"username"`
+	Password ValueSource `json:"password"`
+}
+
+type bearerAuth struct {
+	Token ValueSource `json:"token"`
+}
+
+// retryOption retries the request on connection errors, 429 and 5xx responses
+type retryOption struct {
+	// Attempts is the max number of attempts including the first one
+	Attempts int `json:"attempts"`
+	// Interval is the interval between attempts, e.g. `1s`
+	Interval string `json:"interval,omitempty"`
+}
+
+// ValueSource is a value given in place or read from a key of a Secret in the namespace of the application,
+// so the credentials don't need to be written in the application.
+type ValueSource struct {
+	Value     string        `json:"value,omitempty"`
+	SecretRef *SecretKeyRef `json:"secretRef,omitempty"`
+}
+
+// SecretKeyRef selects a key of a Secret
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
 // Run exec the actual http logic, and res represent the result of http task
 func (c *HTTPCmd) Run(meta *registry.Meta) (res interface{}, err error) {
+	ctx := meta.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	var header, trailer http.Header
 	var (
 		method = meta.String("method")
 		u      = meta.String("url")
 	)
-	var r io.Reader
+	var body []byte
 	if obj := meta.Obj.Lookup("request"); obj.Exists() {
 		if v := obj.Lookup("body"); v.Exists() {
-			r, err = v.Reader()
+			r, err := v.Reader()
 			if err != nil {
 				return nil, err
 			}
+			if body, err = ioutil.ReadAll(r); err != nil {
+				return nil, err
+			}
 		}
 		if header, err = parseHeaders(obj, "header"); err != nil {
 			return nil, err
@@ -64,32 +157,251 @@ func (c *HTTPCmd) Run(meta *registry.Meta) (res interface{}, err error) {
 		}
 	}
 	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Content-Type") == "" {
 		header.Set("Content-Type", "application/json")
 	}
 	if meta.Err != nil {
 		return nil, meta.Err
 	}
+	opts := &options{}
+	if err := decodeOptions(meta.Obj, opts); err != nil {
+		return nil, errors.WithMessage(err, "invalid options of http task")
+	}
+	cli, err := c.client(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setAuth(ctx, opts.Auth, header); err != nil {
+		return nil, err
+	}
+	attempts, interval, err := retryPolicy(opts.Retry)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequestWithContext(context.Background(), method, u, r)
+	var resp *http.Response
+	var b []byte
+	for i := 1; ; i++ {
+		resp, b, err = do(ctx, cli, method, u, body, header, trailer)
+		if i >= attempts || !shouldRetry(resp, err) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if !opts.AllowErrorStatus && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return nil, errors.Errorf("the server responded %d: %s", resp.StatusCode, truncate(string(b), maxErrorBodyLength))
+	}
+	// parse response body and headers
+	result := map[string]interface{}{
+		"statusCode": resp.StatusCode,
+		"body":       string(b),
+		"header":     resp.Header,
+		"trailer":    resp.Trailer,
+	}
+	// the body is also decoded in `json` if it's valid JSON, so templates can refer to its fields
+	var data interface{}
+	if json.Unmarshal(b, &data) == nil {
+		result["json"] = data
+	}
+	return result, nil
+}
+
+// do sends the request once and reads the whole response body.
+func do(ctx context.Context, cli *http.Client, method, u string, body []byte, header, trailer http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
 	req.Header = header
 	req.Trailer = trailer
 
-	resp, err := c.Client.Do(req)
+	resp, err := cli.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	//nolint:errcheck
 	defer resp.Body.Close()
 	b, err := ioutil.ReadAll(resp.Body)
-	// parse response body and headers
-	return map[string]interface{}{
-		"body":    string(b),
-		"header":  resp.Header,
-		"trailer": resp.Trailer,
-	}, err
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, b, nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+func retryPolicy(opt *retryOption) (int, time.Duration, error) {
+	if opt == nil || opt.Attempts < 1 {
+		return 1, 0, nil
+	}
+	interval := defaultRetryInterval
+	if opt.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(opt.Interval); err != nil {
+			return 0, 0, errors.Wrap(err, "invalid retry interval")
+		}
+	}
+	return opt.Attempts, interval, nil
+}
+
+// client returns a client with the timeout and the TLS config of the task.
+func (c *HTTPCmd) client(ctx context.Context, opts *options) (*http.Client, error) {
+	cli := *c.Client
+	cli.Timeout = DefaultTimeout
+	if opts.Timeout != "" {
+		timeout, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid timeout")
+		}
+		cli.Timeout = timeout
+	}
+	if opts.TLS == nil {
+		return &cli, nil
+	}
+	config := &tls.Config{
+		// nolint:gosec
+		InsecureSkipVerify: opts.TLS.InsecureSkipVerify,
+	}
+	if opts.TLS.CA != nil {
+		ca, err := c.resolveValue(ctx, *opts.TLS.CA)
+		if err != nil {
+			return nil, errors.WithMessage(err, "read ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ca)) {
+			return nil, errors.New("invalid ca, no certificate is found in PEM")
+		}
+		config.RootCAs = pool
+	}
+	if opts.TLS.Cert != nil || opts.TLS.Key != nil {
+		if opts.TLS.Cert == nil || opts.TLS.Key == nil {
+			return nil, errors.New("both cert and key are required for the client certificate")
+		}
+		cert, err := c.resolveValue(ctx, *opts.TLS.Cert)
+		if err != nil {
+			return nil, errors.WithMessage(err, "read cert")
+		}
+		key, err := c.resolveValue(ctx, *opts.TLS.Key)
+		if err != nil {
+			return nil, errors.WithMessage(err, "read key")
+		}
+		pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate")
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	cli.Transport = transport
+	return &cli, nil
+}
+
+// setAuth sets the Authorization header by the credential of the task.
+func (c *HTTPCmd) setAuth(ctx context.Context, auth *authOption, header http.Header) error {
+	if auth == nil {
+		return nil
+	}
+	switch {
+	case auth.Basic != nil:
+		username, err := c.resolveValue(ctx, auth.Basic.Username)
+		if err != nil {
+			return errors.WithMessage(err, "read username")
+		}
+		password, err := c.resolveValue(ctx, auth.Basic.Password)
+		if err != nil {
+			return errors.WithMessage(err, "read password")
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	case auth.Bearer != nil:
+		token, err := c.resolveValue(ctx, auth.Bearer.Token)
+		if err != nil {
+			return errors.WithMessage(err, "read token")
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// resolveValue returns the value in place, or reads it from the Secret in the namespace of the context.
+func (c *HTTPCmd) resolveValue(ctx context.Context, v ValueSource) (string, error) {
+	if v.SecretRef == nil {
+		return v.Value, nil
+	}
+	if c.secrets == nil {
+		return "", errors.New("secrets can't be read by the http task")
+	}
+	namespace := kube.NamespaceFrom(ctx)
+	if namespace == "" {
+		return "", errors.New("the namespace to read secrets from is not set")
+	}
+	return ResolveValue(ctx, c.secrets, namespace, v)
+}
+
+// ResolveValue returns the value in place, or reads it from the Secret in the namespace.
+func ResolveValue(ctx context.Context, secrets client.Reader, namespace string, v ValueSource) (string, error) {
+	if v.SecretRef == nil {
+		return v.Value, nil
+	}
+	secret := &corev1.Secret{}
+	if err := secrets.Get(ctx, client.ObjectKey{Namespace: namespace, Name: v.SecretRef.Name}, secret); err != nil {
+		return "", errors.WithMessagef(err, "get secret %s", v.SecretRef.Name)
+	}
+	value, ok := secret.Data[v.SecretRef.Key]
+	if !ok {
+		return "", errors.Errorf("secret %s has no key %s", v.SecretRef.Name, v.SecretRef.Key)
+	}
+	return string(value), nil
+}
+
+// decodeOptions decodes the option fields of the task, the other fields are left out
+// since they may be incomplete, e.g. the `result` of a task in `processing.tasks`.
+func decodeOptions(obj cue.Value, opts *options) error {
+	fields := []struct {
+		name   string
+		target interface{}
+	}{
+		{"timeout", &opts.Timeout},
+		{"tls", &opts.TLS},
+		{"auth", &opts.Auth},
+		{"retry", &opts.Retry},
+		{"allowErrorStatus", &opts.AllowErrorStatus},
+	}
+	for _, f := range fields {
+		v := obj.Lookup(f.name)
+		if !v.Exists() {
+			continue
+		}
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return errors.WithMessage(err, f.name)
+		}
+		if err := json.Unmarshal(b, f.target); err != nil {
+			return errors.Wrap(err, f.name)
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d more bytes)", s[:n], len(s)-n)
 }
 
 func parseHeaders(obj cue.Value, label string) (http.Header, error) {
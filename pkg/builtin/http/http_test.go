@@ -17,16 +17,26 @@ limitations under the License.
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"cuelang.org/go/cue"
 	"github.com/bmizerany/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/oam-dev/kubevela/pkg/builtin/kube"
 	"github.com/oam-dev/kubevela/pkg/builtin/registry"
 )
 
@@ -68,6 +78,133 @@ func TestHTTPCmdRun(t *testing.T) {
 
 }
 
+func TestHTTPCmdOptions(t *testing.T) {
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		case "/flaky":
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("ok"))
+		case "/auth":
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer s.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer tlsServer.Close()
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-token", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("abc")},
+	}
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	Register(fake.NewFakeClientWithScheme(scheme.Scheme, secret))
+	defer registry.RegisterRunner(TaskName, newHTTPCmd)
+
+	testCases := map[string]struct {
+		task   map[string]interface{}
+		result map[string]interface{}
+		err    string
+	}{
+		"error status fails the task": {
+			task: map[string]interface{}{"url": s.URL + "/missing"},
+			err:  "the server responded 404: not found",
+		},
+		"error status is handled by the template": {
+			task:   map[string]interface{}{"url": s.URL + "/missing", "allowErrorStatus": true},
+			result: map[string]interface{}{"statusCode": 404, "body": "not found"},
+		},
+		"retry until success": {
+			task: map[string]interface{}{
+				"url":   s.URL + "/flaky",
+				"retry": map[string]interface{}{"attempts": 3, "interval": "1ms"},
+			},
+			result: map[string]interface{}{"statusCode": 200, "body": "ok"},
+		},
+		"timeout": {
+			task: map[string]interface{}{"url": s.URL + "/slow", "timeout": "10ms"},
+			err:  "Client.Timeout exceeded",
+		},
+		"bearer token from secret": {
+			task: map[string]interface{}{
+				"url": s.URL + "/auth",
+				"auth": map[string]interface{}{"bearer": map[string]interface{}{
+					"token": map[string]interface{}{"secretRef": map[string]interface{}{"name": "api-token", "key": "token"}},
+				}},
+			},
+			result: map[string]interface{}{"statusCode": 200, "body": "Bearer abc"},
+		},
+		"basic auth": {
+			task: map[string]interface{}{
+				"url": s.URL + "/auth",
+				"auth": map[string]interface{}{"basic": map[string]interface{}{
+					"username": map[string]interface{}{"value": "admin"},
+					"password": map[string]interface{}{"value": "pass"},
+				}},
+			},
+			result: map[string]interface{}{"statusCode": 200, "body": "Basic YWRtaW46cGFzcw=="},
+		},
+		"missing secret": {
+			task: map[string]interface{}{
+				"url": s.URL + "/auth",
+				"auth": map[string]interface{}{"bearer": map[string]interface{}{
+					"token": map[string]interface{}{"secretRef": map[string]interface{}{"name": "not-exist", "key": "token"}},
+				}},
+			},
+			err: "get secret not-exist",
+		},
+		"unknown ca": {
+			task: map[string]interface{}{"url": tlsServer.URL},
+			err:  "certificate signed by unknown authority",
+		},
+		"trusted ca": {
+			task: map[string]interface{}{
+				"url": tlsServer.URL,
+				"tls": map[string]interface{}{"ca": map[string]interface{}{"value": ca}},
+			},
+			result: map[string]interface{}{"statusCode": 200, "body": "secure"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.task["method"] = "GET"
+			b, _ := json.Marshal(tc.task)
+			r := cue.Runtime{}
+			inst, err := r.Compile("", string(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			runner, _ := registry.LookupRunner(TaskName)(cue.Value{})
+			got, err := runner.Run(&registry.Meta{Context: kube.WithNamespace(context.Background(), "default"), Obj: inst.Value()})
+			if tc.err != "" {
+				assert.NotEqual(t, nil, err)
+				assert.Equal(t, true, strings.Contains(err.Error(), tc.err), err.Error())
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := got.(map[string]interface{})
+			for k, v := range tc.result {
+				assert.Equal(t, v, resp[k])
+			}
+		})
+	}
+}
+
 // NewMock mock the http server
 func NewMock() *httptest.Server {
 	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
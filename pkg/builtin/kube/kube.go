@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/pkg/builtin/registry"
+)
+
+// TaskName is the key of the kube task in the registry and in `processing`
+const TaskName = "kube"
+
+// DefaultReadableKinds are the kinds which templates can read by default
+var DefaultReadableKinds = []string{"ConfigMap", "Secret", "Service"}
+
+type namespaceKey struct{}
+
+// WithNamespace returns a copy of ctx carrying the namespace which the kube task reads objects from,
+// the http task reads the secrets of its credentials from the namespace too.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFrom returns the namespace carried by ctx, it's empty if ctx has no namespace.
+func NamespaceFrom(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+// Register registers the kube task reading objects by the client, only the objects of readable kinds can be read.
+// A kind is given as `Kind.group`, e.g. `Deployment.apps`, the group of core kinds is omitted.
+func Register(cli client.Reader, readableKinds []string) {
+	readable := make(map[schema.GroupKind]bool, len(readableKinds))
+	for _, kind := range readableKinds {
+		readable[schema.ParseGroupKind(kind)] = true
+	}
+	registry.RegisterRunner(TaskName, func(v cue.Value) (registry.Runner, error) {
+		return &Cmd{cli: cli, readable: readable}, nil
+	})
+}
+
+// Cmd provides methods for kube task
+type Cmd struct {
+	cli      client.Reader
+	readable map[schema.GroupKind]bool
+}
+
+// query identifies the objects to read
+type query struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// Run gets the objects in `get` and lists the objects in `list`, the results are keyed by the same names.
+// The objects are read from the namespace in the context, and an object which does not exist is left out.
+func (c *Cmd) Run(meta *registry.Meta) (interface{}, error) {
+	ctx := meta.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	namespace := NamespaceFrom(ctx)
+	if namespace == "" {
+		return nil, errors.New("the namespace to read objects from is not set")
+	}
+	gets, err := decodeQueries(meta.Obj.Lookup("get"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid get")
+	}
+	lists, err := decodeQueries(meta.Obj.Lookup("list"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid list")
+	}
+
+	results := make(map[string]interface{}, len(gets)+len(lists))
+	for name, q := range gets {
+		if q.Name == "" {
+			return nil, errors.Errorf("get %s: name is required", name)
+		}
+		if err := c.checkReadable(q); err != nil {
+			return nil, errors.WithMessagef(err, "get %s", name)
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(q.APIVersion)
+		obj.SetKind(q.Kind)
+		if err := c.cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: q.Name}, obj); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.WithMessagef(err, "get %s", name)
+		}
+		results[name] = obj.Object
+	}
+	for name, q := range lists {
+		if err := c.checkReadable(q); err != nil {
+			return nil, errors.WithMessagef(err, "list %s", name)
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetAPIVersion(q.APIVersion)
+		list.SetKind(q.Kind + "List")
+		if err := c.cli.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels(q.Labels)); err != nil {
+			return nil, errors.WithMessagef(err, "list %s", name)
+		}
+		items := make([]interface{}, len(list.Items))
+		for i := range list.Items {
+			items[i] = list.Items[i].Object
+		}
+		results[name] = items
+	}
+	return results, nil
+}
+
+func (c *Cmd) checkReadable(q query) error {
+	gv, err := schema.ParseGroupVersion(q.APIVersion)
+	if err != nil {
+		return err
+	}
+	gk := schema.GroupKind{Group: gv.Group, Kind: q.Kind}
+	if q.Kind == "" || !c.readable[gk] {
+		return errors.Errorf("kind %q is not allowed to be read by templates", gk.String())
+	}
+	return nil
+}
+
+func decodeQueries(v cue.Value) (map[string]query, error) {
+	if !v.Exists() {
+		return nil, nil
+	}
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	queries := map[string]query{}
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/pkg/builtin/registry"
+)
+
+func TestKubeCmdRun(t *testing.T) {
+	service := func(name, namespace string, labels map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.1"},
+		}
+	}
+	cli := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"},
+		},
+		service("web", "default", map[string]string{"app": "web"}),
+		service("api", "default", map[string]string{"app": "api"}),
+		service("web", "other", map[string]string{"app": "web"}),
+	)
+	Register(cli, []string{"Secret", "Service"})
+	newRunner := registry.LookupRunner(TaskName)
+	assert.NotNil(t, newRunner)
+
+	run := func(ctx context.Context, src string) (map[string]interface{}, error) {
+		var r cue.Runtime
+		inst, err := r.Compile("-", src)
+		assert.NoError(t, err)
+		runner, err := newRunner(cue.Value{})
+		assert.NoError(t, err)
+		got, err := runner.Run(&registry.Meta{Context: ctx, Obj: inst.Value()})
+		if err != nil {
+			return nil, err
+		}
+		return got.(map[string]interface{}), nil
+	}
+	ctx := WithNamespace(context.Background(), "default")
+
+	got, err := run(ctx, `
+get: {
+	db: {apiVersion: "v1", kind: "Secret", name: "db"}
+	missing: {apiVersion: "v1", kind: "Secret", name: "missing"}
+	other: {apiVersion: "v1", kind: "Secret", name: "other"}
+}
+list: web: {apiVersion: "v1", kind: "Service", labels: app: "web"}
+`)
+	assert.NoError(t, err)
+	db := got["db"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"password": "c2VjcmV0"}, db["data"])
+	assert.NotContains(t, got, "missing")
+	assert.NotContains(t, got, "other")
+	web := got["web"].([]interface{})
+	assert.Len(t, web, 1)
+	assert.Equal(t, "10.0.0.1", web[0].(map[string]interface{})["spec"].(map[string]interface{})["clusterIP"])
+
+	_, err = run(ctx, `get: config: {apiVersion: "v1", kind: "ConfigMap", name: "config"}`)
+	assert.EqualError(t, err, `get config: kind "ConfigMap" is not allowed to be read by templates`)
+
+	_, err = run(ctx, `list: deploys: {apiVersion: "apps/v1", kind: "Deployment"}`)
+	assert.EqualError(t, err, `list deploys: kind "Deployment.apps" is not allowed to be read by templates`)
+
+	_, err = run(context.Background(), `get: db: {apiVersion: "v1", kind: "Secret", name: "db"}`)
+	assert.EqualError(t, err, "the namespace to read objects from is not set")
+}
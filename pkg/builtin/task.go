@@ -17,7 +17,7 @@ limitations under the License.
 package builtin
 
 import (
-	"errors"
+	"fmt"
 
 	"cuelang.org/go/cue"
 
@@ -39,7 +39,7 @@ func RunBuildInTasks(spec map[string]interface{}, io cmdutil.IOStreams) (map[str
 func RunTaskByKey(key string, v cue.Value, meta *registry.Meta) (interface{}, error) {
 	task := registry.LookupRunner(key)
 	if task == nil {
-		return nil, errors.New("there is no http task in task registry")
+		return nil, fmt.Errorf("there is no %s task in task registry", key)
 	}
 	runner, err := task(v)
 	if err != nil {
@@ -69,6 +69,19 @@ type Args struct {
 	// PackageDiscover used for CRD discovery in CUE packages, a K8s client is contained in it.
 	PackageDiscover *packages.PackageDiscover
 
+	// PackageRefreshInterval is the interval to refresh all CUE kube packages from the cluster,
+	// the packages are only refreshed when CRDs change if it's 0.
+	PackageRefreshInterval time.Duration
+
+	// DriftDetection indicates whether to watch the resources dispatched by applications for the changes made out of
+	// KubeVela, the drift is reported or repaired by the drift policy of the application.
+	DriftDetection bool
+
+	// WorkflowApprovalsStamped indicates whether the approvers in the workflow approvals of applications are stamped
+	// by the mutating webhook, the approvals are refused without it since anyone updating the application could write
+	// any approver.
+	WorkflowApprovalsStamped bool
+
 	// ConcurrentReconciles is the concurrent reconcile number of the controller
 	ConcurrentReconciles int
 
@@ -19,6 +19,7 @@ package application
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
@@ -75,6 +76,7 @@ type Reconciler struct {
 	applicator           apply.Applicator
 	appRevisionLimit     int
 	concurrentReconciles int
+	approvalsStamped     bool
 }
 
 // +kubebuilder:rbac:groups=core.oam.dev,resources=applications,verbs=get;list;watch;create;update;patch;delete
@@ -162,6 +164,11 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	r.Recorder.Event(app, event.Normal(velatypes.ReasonRendered, velatypes.MessageRendered))
 	klog.Info("Successfully render application resources", "application", klog.KObj(app))
 
+	if err := handler.checkComponentDependencies(ctx, appFile); err != nil {
+		klog.ErrorS(err, "Failed to check component dependencies", "application", klog.KObj(app))
+		r.Recorder.Event(app, event.Warning(velatypes.ReasonFailedApply, err))
+		return r.endWithNegativeCondition(ctx, app, errorCondition("Applied", err))
+	}
 	if err := handler.applyAppManifests(ctx, comps, policies); err != nil {
 		klog.ErrorS(err, "Failed to apply application manifests",
 			"application", klog.KObj(app))
@@ -176,14 +183,26 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	r.Recorder.Event(app, event.Normal(velatypes.ReasonApplied, velatypes.MessageApplied))
 	klog.Info("Successfully apply application manifests", "application", klog.KObj(app))
 
-	done, err := workflow.NewWorkflow(app, handler.r.applicator).ExecuteSteps(ctx, handler.currentAppRev.Name, wfSteps)
+	wfState, err := workflow.NewWorkflow(app, appFile.Workflow, r.Client, r.applicator, handler.applyComponentFunc(comps),
+		workflow.WithApprovalsStamped(r.approvalsStamped)).ExecuteSteps(ctx, handler.currentAppRev.Name, wfSteps)
 	if err != nil {
 		klog.Error(err, "[handle workflow]")
 		r.Recorder.Event(app, event.Warning(velatypes.ReasonFailedWorkflow, err))
 		return r.endWithNegativeCondition(ctx, app, errorCondition("Workflow", err))
 	}
-	if !done {
+	if wfState == workflow.StateFinished || wfState == workflow.StateTerminated {
+		if err := handler.recordWorkflowRun(ctx); err != nil {
+			klog.ErrorS(err, "Failed to record workflow run", "application", klog.KObj(app))
+			r.Recorder.Event(app, event.Warning(velatypes.ReasonFailedWorkflow, err))
+			return r.endWithNegativeCondition(ctx, app, errorCondition("Workflow", err))
+		}
+	}
+	switch wfState {
+	case workflow.StateExecuting:
 		return reconcile.Result{RequeueAfter: WorkflowReconcileWaitTime}, r.patchStatus(ctx, app)
+	case workflow.StateSuspended, workflow.StateTerminated:
+		// the workflow will be continued once it's resumed or restarted
+		return ctrl.Result{}, r.patchStatus(ctx, app)
 	}
 
 	// if inplace is false and rolloutPlan is nil, it means the user will use an outer AppRollout object to rollout the application
@@ -224,6 +243,16 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		if err := r.patchStatus(ctx, app); err != nil {
 			return r.endWithNegativeCondition(ctx, app, v1alpha1.ReconcileError(err))
 		}
+		if len(handler.waitingComponents) != 0 {
+			waiting := make([]string, 0, len(handler.waitingComponents))
+			for _, name := range handler.componentOrder {
+				if handler.waitingComponents[name] != nil {
+					waiting = append(waiting, name)
+				}
+			}
+			return r.endWithNegativeCondition(ctx, app, errorCondition("HealthCheck",
+				errors.Errorf("components %s are waiting for their dependencies", strings.Join(waiting, ", "))))
+		}
 		return r.endWithNegativeCondition(ctx, app, errorCondition("HealthCheck", errors.New("not healthy")))
 	}
 	app.Status.SetConditions(readyCondition("HealthCheck"))
@@ -268,6 +297,9 @@ func (r *Reconciler) handleFinalizers(ctx context.Context, app *v1beta1.Applicat
 			// this is for backward compatibility
 			rt := &v1beta1.ResourceTracker{}
 			rt.SetName(fmt.Sprintf("%s-%s", app.Namespace, app.Name))
+			if err := r.releaseResources(ctx, app, rt.Name); err != nil {
+				return true, errors.WithMessage(err, "cannot remove finalizer")
+			}
 			if err := r.Client.Delete(ctx, rt); err != nil && !kerrors.IsNotFound(err) {
 				klog.ErrorS(err, "Failed to delete legacy resource tracker", "name", rt.Name)
 				return true, errors.WithMessage(err, "cannot remove finalizer")
@@ -279,6 +311,9 @@ func (r *Reconciler) handleFinalizers(ctx context.Context, app *v1beta1.Applicat
 			if app.Status.LatestRevision != nil && len(app.Status.LatestRevision.Name) != 0 {
 				latestTracker := &v1beta1.ResourceTracker{}
 				latestTracker.SetName(dispatch.ConstructResourceTrackerName(app.Status.LatestRevision.Name, app.Namespace))
+				if err := r.releaseResources(ctx, app, latestTracker.Name); err != nil {
+					return true, errors.WithMessage(err, "cannot remove finalizer")
+				}
 				if err := r.Client.Delete(ctx, latestTracker); err != nil && !kerrors.IsNotFound(err) {
 					klog.ErrorS(err, "Failed to delete latest resource tracker", "name", latestTracker.Name)
 					return true, errors.WithMessage(err, "cannot remove finalizer")
@@ -299,6 +334,9 @@ func (r *Reconciler) handleFinalizers(ctx context.Context, app *v1beta1.Applicat
 				return true, errors.WithMessage(err, "cannot remove finalizer")
 			}
 			for _, rt := range rtList.Items {
+				if err := r.releaseResources(ctx, app, rt.Name); err != nil {
+					return true, errors.WithMessage(err, "cannot remove finalizer")
+				}
 				if err := r.Client.Delete(ctx, rt.DeepCopy()); err != nil && !kerrors.IsNotFound(err) {
 					klog.ErrorS(err, "Failed to delete resource tracker", "name", rt.Name)
 					return true, errors.WithMessage(err, "cannot remove finalizer")
@@ -311,6 +349,25 @@ func (r *Reconciler) handleFinalizers(ctx context.Context, app *v1beta1.Applicat
 	return false, nil
 }
 
+// releaseResources releases the resources whose deletion policy is Orphan or Retain from the resource tracker before
+// it's deleted, so that they're kept after the application is deleted.
+func (r *Reconciler) releaseResources(ctx context.Context, app *v1beta1.Application, rtName string) error {
+	orphaned, retained, err := dispatch.ReleaseResources(ctx, r.Client, rtName, app.GetAnnotations()[oam.AnnotationDeletionPolicy])
+	if err != nil {
+		klog.ErrorS(err, "Failed to release resources of resource tracker", "name", rtName)
+		return err
+	}
+	if len(orphaned) != 0 {
+		r.Recorder.Event(app, event.Normal(velatypes.ReasonOrphaned,
+			fmt.Sprintf(velatypes.MessageOrphaned, formatReferences(orphaned))))
+	}
+	if len(retained) != 0 {
+		r.Recorder.Event(app, event.Normal(velatypes.ReasonRetained,
+			fmt.Sprintf(velatypes.MessageRetained, formatReferences(retained))))
+	}
+	return nil
+}
+
 func (r *Reconciler) endWithNegativeCondition(ctx context.Context, app *v1beta1.Application, condition v1alpha1.Condition) (ctrl.Result, error) {
 	app.SetConditions(condition)
 	if err := r.patchStatus(ctx, app); err != nil {
@@ -371,6 +428,7 @@ func Setup(mgr ctrl.Manager, args core.Args) error {
 		applicator:           apply.NewAPIApplicator(mgr.GetClient()),
 		appRevisionLimit:     args.AppRevisionLimit,
 		concurrentReconciles: args.ConcurrentReconciles,
+		approvalsStamped:     args.WorkflowApprovalsStamped,
 	}
 	return reconciler.SetupWithManager(mgr)
 }
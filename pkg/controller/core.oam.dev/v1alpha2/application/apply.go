@@ -18,8 +18,11 @@ package application
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
 
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -41,6 +44,7 @@ import (
 	"github.com/oam-dev/kubevela/pkg/cue/process"
 	"github.com/oam-dev/kubevela/pkg/oam"
 	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+	"github.com/oam-dev/kubevela/pkg/workflow"
 )
 
 type appHandler struct {
@@ -50,11 +54,26 @@ type appHandler struct {
 	latestAppRev   *v1beta1.ApplicationRevision
 	isNewRevision  bool
 	currentRevHash string
+
+	// componentOrder is the names of the components in topological order of their dependencies,
+	// it's nil if none of the components declares dependsOn
+	componentOrder []string
+	// waitingComponents are the components not dispatched since the components they depend on are not ready
+	waitingComponents map[string]*waitingComponent
+}
+
+// dispatchesManifests checks whether the application controller dispatches the manifests of the application,
+// they're dispatched by workflow steps or rollout otherwise.
+func (h *appHandler) dispatchesManifests() bool {
+	if h.app.Spec.Workflow.HasSteps() {
+		return false
+	}
+	return h.app.Annotations[oam.AnnotationAppRevisionOnly] != "true" && !appWillRollout(h.app)
 }
 
 func (h *appHandler) applyAppManifests(ctx context.Context, comps []*types.ComponentManifest, policies []*unstructured.Unstructured) error {
 	appRev := h.currentAppRev
-	if (h.app.Spec.Workflow != nil && len(h.app.Spec.Workflow.Steps) > 0) || h.app.Annotations[oam.AnnotationAppRevisionOnly] == "true" {
+	if h.app.Spec.Workflow.HasSteps() || h.app.Annotations[oam.AnnotationAppRevisionOnly] == "true" {
 		return h.createResourcesConfigMap(ctx, appRev, comps, policies)
 	}
 	if appWillRollout(h.app) {
@@ -68,9 +87,12 @@ func (h *appHandler) applyAppManifests(ctx context.Context, comps []*types.Compo
 	}
 	// only do GC when ALL resources are dispatched successfully
 	// so skip GC while dispatching addon resources
-	d := dispatch.NewAppManifestsDispatcher(h.r.Client, appRev).StartAndSkipGC(latestTracker)
+	d := h.newDispatcher(appRev).StartAndSkipGC(latestTracker)
 	// dispatch packaged workload resources before dispatching assembled manifests
 	for _, comp := range comps {
+		if h.waitingComponents[comp.Name] != nil {
+			continue
+		}
 		if len(comp.PackagedWorkloadResources) != 0 {
 			if _, err := d.Dispatch(ctx, comp.PackagedWorkloadResources); err != nil {
 				return errors.WithMessage(err, "cannot dispatch packaged workload resources")
@@ -81,6 +103,13 @@ func (h *appHandler) applyAppManifests(ctx context.Context, comps []*types.Compo
 		}
 	}
 	a := assemble.NewAppManifests(appRev).WithWorkloadOption(assemble.DiscoveryHelmBasedWorkload(ctx, h.r.Client))
+	if h.componentOrder != nil {
+		if err := h.dispatchInOrder(ctx, d.EndAndGC(latestTracker), a); err != nil {
+			return err
+		}
+		h.recordKeptResources(d.KeptResources())
+		return nil
+	}
 	manifests, err := a.AssembledManifests()
 	if err != nil {
 		return errors.WithMessage(err, "cannot assemble application manifests")
@@ -88,108 +117,251 @@ func (h *appHandler) applyAppManifests(ctx context.Context, comps []*types.Compo
 	if _, err := d.EndAndGC(latestTracker).Dispatch(ctx, manifests); err != nil {
 		return errors.WithMessage(err, "cannot dispatch application manifests")
 	}
+	h.recordKeptResources(d.KeptResources())
 	return nil
 }
 
+// recordKeptResources records the resources kept from GC by their deletion policies in the events of the application
+func (h *appHandler) recordKeptResources(orphaned, retained []v1beta1.TypedReference) {
+	if len(orphaned) != 0 {
+		h.r.Recorder.Event(h.app, event.Normal(types.ReasonOrphaned,
+			fmt.Sprintf(types.MessageOrphaned, formatReferences(orphaned))))
+	}
+	if len(retained) != 0 {
+		h.r.Recorder.Event(h.app, event.Normal(types.ReasonRetained,
+			fmt.Sprintf(types.MessageRetained, formatReferences(retained))))
+	}
+}
+
+// formatReferences formats the references of resources as `Kind namespace/name` separated by comma
+func formatReferences(refs []v1beta1.TypedReference) string {
+	s := make([]string, len(refs))
+	for i, ref := range refs {
+		s[i] = fmt.Sprintf("%s %s", ref.Kind, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name})
+	}
+	return strings.Join(s, ", ")
+}
+
+// dispatchInOrder dispatches the manifests of the components in topological order of their dependencies.
+// The existing resources of the components waiting for their dependencies are adopted as they are rather than
+// dispatched, so that they're neither updated nor deleted by GC until the components are dispatched.
+// So are the resources of the components not rendered, e.g. the components whose inputs are not ready.
+func (h *appHandler) dispatchInOrder(ctx context.Context, d *dispatch.AppManifestsDispatcher, a *assemble.AppManifests) error {
+	workloads, traits, _, err := a.GroupAssembledManifests()
+	if err != nil {
+		return errors.WithMessage(err, "cannot assemble application manifests")
+	}
+	var manifests, waiting []*unstructured.Unstructured
+	var notRendered []string
+	for _, name := range h.componentOrder {
+		wl, ok := workloads[name]
+		if !ok {
+			notRendered = append(notRendered, name)
+			continue
+		}
+		if h.waitingComponents[name] != nil {
+			waiting = append(append(waiting, wl), traits[name]...)
+			continue
+		}
+		manifests = append(append(manifests, wl), traits[name]...)
+	}
+	if len(waiting) != 0 {
+		if err := d.Adopt(ctx, waiting); err != nil {
+			return errors.WithMessage(err, "cannot adopt resources of waiting components")
+		}
+	}
+	if len(notRendered) != 0 {
+		if err := d.AdoptComponents(ctx, notRendered); err != nil {
+			return errors.WithMessage(err, "cannot adopt resources of components not rendered")
+		}
+	}
+	if _, err := d.Dispatch(ctx, manifests); err != nil {
+		return errors.WithMessage(err, "cannot dispatch application manifests")
+	}
+	return nil
+}
+
+// ApplyModeServerSide is the value of the apply-mode annotation to apply resources by server-side apply
+const ApplyModeServerSide = "server-side"
+
+// newDispatcher returns the dispatcher of the app revision applying resources in the apply mode of the application,
+// its GC takes the deletion policy set on the application rather than the one recorded in the app revision.
+func (h *appHandler) newDispatcher(appRev *v1beta1.ApplicationRevision) *dispatch.AppManifestsDispatcher {
+	d := dispatch.NewAppManifestsDispatcher(h.r.Client, appRev).
+		WithDeletionPolicy(h.app.GetAnnotations()[oam.AnnotationDeletionPolicy])
+	if h.app.GetAnnotations()[oam.AnnotationApplyMode] == ApplyModeServerSide {
+		d = d.WithServerSideApply(h.app.GetAnnotations()[oam.AnnotationApplyForceConflicts] == "true")
+	}
+	return d
+}
+
+// applyComponentFunc returns the function used by workflow steps to apply the workload and traits of a component
+func (h *appHandler) applyComponentFunc(comps []*types.ComponentManifest) workflow.ComponentApplier {
+	return func(ctx context.Context, compName string) (*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+		var comp *types.ComponentManifest
+		for _, c := range comps {
+			if c.Name == compName {
+				comp = c
+				break
+			}
+		}
+		if comp == nil {
+			return nil, nil, errors.Errorf("component %s is not found in application %s", compName, h.app.Name)
+		}
+		if comp.InsertConfigNotReady {
+			return nil, nil, errors.Errorf("secrets or configs of component %s are not ready", compName)
+		}
+		// resources applied by workflow steps are recorded in the resource tracker of current revision,
+		// skip GC since the other components may not be applied by the workflow yet
+		d := h.newDispatcher(h.currentAppRev).StartAndSkipGC(nil)
+		if len(comp.PackagedWorkloadResources) != 0 {
+			if _, err := d.Dispatch(ctx, comp.PackagedWorkloadResources); err != nil {
+				return nil, nil, errors.WithMessage(err, "cannot dispatch packaged workload resources")
+			}
+		}
+		a := assemble.NewAppManifests(h.currentAppRev).WithWorkloadOption(assemble.DiscoveryHelmBasedWorkload(ctx, h.r.Client))
+		workloads, traits, _, err := a.GroupAssembledManifests()
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "cannot assemble application manifests")
+		}
+		workload, ok := workloads[compName]
+		if !ok {
+			return nil, nil, errors.Errorf("component %s is not found in application revision %s", compName, h.currentAppRev.Name)
+		}
+		manifests := append([]*unstructured.Unstructured{workload}, traits[compName]...)
+		if _, err := d.Dispatch(ctx, manifests); err != nil {
+			return nil, nil, errors.WithMessagef(err, "cannot dispatch manifests of component %s", compName)
+		}
+		return workload, traits[compName], nil
+	}
+}
+
 func (h *appHandler) aggregateHealthStatus(appFile *appfile.Appfile) ([]common.ApplicationComponentStatus, bool, error) {
 	var appStatus []common.ApplicationComponentStatus
 	var healthy = true
 	for _, wl := range appFile.Workloads {
-		var status = common.ApplicationComponentStatus{
-			Name:               wl.Name,
-			WorkloadDefinition: wl.FullTemplate.Reference.Definition,
-			Healthy:            true,
-		}
-
-		var (
-			outputSecretName string
-			err              error
-			pCtx             process.Context
-		)
-
-		// this can help detect the componentManifest not ready and reconcile again
-		if wl.ConfigNotReady {
-			status.Healthy = false
-			status.Message = "secrets or configs not ready"
-			appStatus = append(appStatus, status)
+		if waiting := h.waitingComponents[wl.Name]; waiting != nil {
+			appStatus = append(appStatus, common.ApplicationComponentStatus{
+				Name:               wl.Name,
+				WorkloadDefinition: wl.FullTemplate.Reference.Definition,
+				Healthy:            false,
+				Message:            waiting.message(),
+				WaitingFor:         waiting.waitingFor,
+			})
 			healthy = false
 			continue
 		}
-		if wl.IsSecretProducer() {
-			outputSecretName, err = appfile.GetOutputSecretNames(wl)
-			if err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, setting outputSecretName error", appFile.Name, wl.Name)
-			}
-			pCtx.InsertSecrets(outputSecretName, wl.RequiredSecrets)
+		status, wlHealthy, err := h.collectComponentStatus(appFile, wl)
+		if err != nil {
+			return nil, false, err
 		}
+		if !wlHealthy {
+			healthy = false
+		}
+		appStatus = append(appStatus, status)
+	}
+	return appStatus, healthy, nil
+}
 
-		switch wl.CapabilityCategory {
-		case types.TerraformCategory:
-			pCtx = appfile.NewBasicContext(wl, appFile.Name, appFile.RevisionName, appFile.Namespace)
-			ctx := context.Background()
-			var configuration terraformapi.Configuration
-			if err := h.r.Client.Get(ctx, client.ObjectKey{Name: wl.Name, Namespace: h.app.Namespace}, &configuration); err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, check health error", appFile.Name, wl.Name)
-			}
-			if configuration.Status.State != terraformtypes.Available {
-				healthy = false
-				status.Healthy = false
-			} else {
-				status.Healthy = true
-			}
-			status.Message = configuration.Status.Message
-		default:
-			pCtx = process.NewContext(h.app.Namespace, wl.Name, appFile.Name, appFile.RevisionName)
-			if err := wl.EvalContext(pCtx); err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, evaluate context error", appFile.Name, wl.Name)
-			}
-			workloadHealth, err := wl.EvalHealth(pCtx, h.r, h.app.Namespace)
-			if err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, check health error", appFile.Name, wl.Name)
-			}
-			if !workloadHealth {
-				// TODO(wonderflow): we should add a custom way to let the template say why it's unhealthy, only a bool flag is not enough
-				status.Healthy = false
-				healthy = false
-			}
+// collectComponentStatus checks the health of the workload and the traits of a component
+func (h *appHandler) collectComponentStatus(appFile *appfile.Appfile, wl *appfile.Workload) (common.ApplicationComponentStatus, bool, error) {
+	var status = common.ApplicationComponentStatus{
+		Name:               wl.Name,
+		WorkloadDefinition: wl.FullTemplate.Reference.Definition,
+		Healthy:            true,
+	}
+	var healthy = true
 
-			status.Message, err = wl.EvalStatus(pCtx, h.r, h.app.Namespace)
-			if err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, evaluate workload status message error", appFile.Name, wl.Name)
-			}
+	var (
+		outputSecretName string
+		err              error
+		pCtx             process.Context
+	)
+
+	if wl.InputsNotReady != "" {
+		status.Healthy = false
+		status.Message = wl.InputsNotReady
+		return status, false, nil
+	}
+	// this can help detect the componentManifest not ready and reconcile again
+	if wl.ConfigNotReady {
+		status.Healthy = false
+		status.Message = "secrets or configs not ready"
+		return status, false, nil
+	}
+	if wl.IsSecretProducer() {
+		outputSecretName, err = appfile.GetOutputSecretNames(wl)
+		if err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, setting outputSecretName error", appFile.Name, wl.Name)
+		}
+		pCtx.InsertSecrets(outputSecretName, wl.RequiredSecrets)
+	}
+
+	switch wl.CapabilityCategory {
+	case types.TerraformCategory:
+		pCtx = appfile.NewBasicContext(wl, appFile.Name, appFile.RevisionName, appFile.Namespace)
+		ctx := context.Background()
+		var configuration terraformapi.Configuration
+		if err := h.r.Client.Get(ctx, client.ObjectKey{Name: wl.Name, Namespace: h.app.Namespace}, &configuration); err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, check health error", appFile.Name, wl.Name)
+		}
+		if configuration.Status.State != terraformtypes.Available {
+			healthy = false
+			status.Healthy = false
+		} else {
+			status.Healthy = true
+		}
+		status.Message = configuration.Status.Message
+	default:
+		pCtx = process.NewContext(h.app.Namespace, wl.Name, appFile.Name, appFile.RevisionName)
+		if err := wl.EvalContext(pCtx); err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, evaluate context error", appFile.Name, wl.Name)
+		}
+		workloadHealth, err := wl.EvalHealth(pCtx, h.r, h.app.Namespace)
+		if err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, check health error", appFile.Name, wl.Name)
+		}
+		if !workloadHealth {
+			// TODO(wonderflow): we should add a custom way to let the template say why it's unhealthy, only a bool flag is not enough
+			status.Healthy = false
+			healthy = false
 		}
 
-		var traitStatusList []common.ApplicationTraitStatus
-		for _, tr := range wl.Traits {
-			if err := tr.EvalContext(pCtx); err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate context error", appFile.Name, wl.Name, tr.Name)
-			}
+		status.Message, err = wl.EvalStatus(pCtx, h.r, h.app.Namespace)
+		if err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, evaluate workload status message error", appFile.Name, wl.Name)
+		}
+	}
 
-			var traitStatus = common.ApplicationTraitStatus{
-				Type:    tr.Name,
-				Healthy: true,
-			}
-			traitHealth, err := tr.EvalHealth(pCtx, h.r, h.app.Namespace)
-			if err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, check health error", appFile.Name, wl.Name, tr.Name)
-			}
-			if !traitHealth {
-				// TODO(wonderflow): we should add a custom way to let the template say why it's unhealthy, only a bool flag is not enough
-				traitStatus.Healthy = false
-				healthy = false
-			}
-			traitStatus.Message, err = tr.EvalStatus(pCtx, h.r, h.app.Namespace)
-			if err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate status message error", appFile.Name, wl.Name, tr.Name)
-			}
-			traitStatusList = append(traitStatusList, traitStatus)
+	var traitStatusList []common.ApplicationTraitStatus
+	for _, tr := range wl.Traits {
+		if err := tr.EvalContext(pCtx); err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate context error", appFile.Name, wl.Name, tr.Name)
 		}
 
-		status.Traits = traitStatusList
-		status.Scopes = generateScopeReference(wl.Scopes)
-		appStatus = append(appStatus, status)
+		var traitStatus = common.ApplicationTraitStatus{
+			Type:    tr.Name,
+			Healthy: true,
+		}
+		traitHealth, err := tr.EvalHealth(pCtx, h.r, h.app.Namespace)
+		if err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, check health error", appFile.Name, wl.Name, tr.Name)
+		}
+		if !traitHealth {
+			// TODO(wonderflow): we should add a custom way to let the template say why it's unhealthy, only a bool flag is not enough
+			traitStatus.Healthy = false
+			healthy = false
+		}
+		traitStatus.Message, err = tr.EvalStatus(pCtx, h.r, h.app.Namespace)
+		if err != nil {
+			return status, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate status message error", appFile.Name, wl.Name, tr.Name)
+		}
+		traitStatusList = append(traitStatusList, traitStatus)
 	}
-	return appStatus, healthy, nil
+
+	status.Traits = traitStatusList
+	status.Scopes = generateScopeReference(wl.Scopes)
+	return status, healthy, nil
 }
 
 func generateScopeReference(scopes []appfile.Scope) []runtimev1alpha1.TypedReference {
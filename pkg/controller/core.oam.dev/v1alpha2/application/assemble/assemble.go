@@ -40,6 +40,10 @@ var DefaultFilterAnnots = []string{
 	oam.AnnotationInplaceUpgrade,
 	oam.AnnotationFilterLabelKeys,
 	oam.AnnotationFilterAnnotationKeys,
+	oam.AnnotationWorkflowSuspend,
+	oam.AnnotationWorkflowTerminate,
+	oam.AnnotationWorkflowRestart,
+	oam.AnnotationWorkflowApprovals,
 }
 
 // NewAppManifests create a AppManifests
@@ -195,6 +199,7 @@ func (am *AppManifests) assemble() {
 			am.finalizeAssemble(err)
 			return
 		}
+		am.setDeletionPolicy(wl, compName, "")
 		am.assembledWorkloads[compName] = wl
 		workloadRef := corev1.ObjectReference{
 			APIVersion: wl.GetAPIVersion(),
@@ -204,6 +209,7 @@ func (am *AppManifests) assemble() {
 		am.assembledTraits[compName] = make([]*unstructured.Unstructured, len(comp.Traits))
 		for i, trait := range comp.Traits {
 			trait := am.assembleTrait(trait, compName, commonLabels)
+			am.setDeletionPolicy(trait, compName, trait.GetLabels()[oam.TraitTypeLabel])
 			if err := am.setWorkloadRefToTrait(workloadRef, trait); err != nil {
 				am.finalizeAssemble(errors.WithMessagef(err, "cannot set workload reference to trait %q", trait.GetName()))
 				return
@@ -299,6 +305,33 @@ func (am *AppManifests) filterAndSetAnnotations(obj *unstructured.Unstructured)
 	util.RemoveAnnotations(obj, allFilterAnnotation)
 }
 
+// setDeletionPolicy records the deletion policy of a resource of the component in its annotation, which is read when
+// the resource is garbage collected. The policy set on the application overrides the ones of components and traits,
+// and the policy of a trait overrides the one of its component.
+func (am *AppManifests) setDeletionPolicy(obj *unstructured.Unstructured, compName, traitType string) {
+	policy := am.appAnnotations[oam.AnnotationDeletionPolicy]
+	for _, comp := range am.AppRevision.Spec.Application.Spec.Components {
+		if policy != "" || comp.Name != compName {
+			continue
+		}
+		for _, tr := range comp.Traits {
+			name, err := util.ConvertDefinitionRevName(tr.Type)
+			if err != nil {
+				name = tr.Type
+			}
+			if traitType != "" && name == traitType {
+				policy = string(tr.DeletionPolicy)
+			}
+		}
+		if policy == "" {
+			policy = string(comp.DeletionPolicy)
+		}
+	}
+	if policy != "" {
+		util.AddAnnotations(obj, map[string]string{oam.AnnotationDeletionPolicy: policy})
+	}
+}
+
 func (am *AppManifests) setNamespace(obj *unstructured.Unstructured) {
 	// only set app's namespace when namespace is unspecified
 	// it's by design to set arbitrary namespace in render phase
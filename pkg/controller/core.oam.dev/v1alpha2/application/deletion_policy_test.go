@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	velatypes "github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+var _ = Describe("Test deletion policy of components", func() {
+	ctx := context.Background()
+	namespace := "test-deletion-policy"
+
+	comp := func(name string, policy oamcore.DeletionPolicy) oamcore.ApplicationComponent {
+		return oamcore.ApplicationComponent{
+			Name:           name,
+			Type:           "dep-worker",
+			DeletionPolicy: policy,
+			Properties:     runtime.RawExtension{Raw: []byte(`{"image":"nginx"}`)},
+		}
+	}
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-with-deletion-policy",
+			Namespace: namespace,
+		},
+		Spec: oamcore.ApplicationSpec{
+			Components: []oamcore.ApplicationComponent{
+				comp("web", ""),
+				comp("orphan", oamcore.DeletionPolicyOrphan),
+				comp("retain", oamcore.DeletionPolicyRetain),
+				comp("delete", oamcore.DeletionPolicyDelete),
+			},
+		},
+	}
+	appKey := client.ObjectKey{Name: app.Name, Namespace: app.Namespace}
+
+	// trackerOwners returns the names of the resource trackers owning the deployment of the component
+	trackerOwners := func(name string) []string {
+		d := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, d)).Should(BeNil())
+		var owners []string
+		for _, owner := range d.GetOwnerReferences() {
+			if owner.Kind == oamcore.ResourceTrackerKind {
+				owners = append(owners, owner.Name)
+			}
+		}
+		return owners
+	}
+
+	hasEventOf := func(appName, reason string) bool {
+		events, err := recorder.GetEventsWithName(appName)
+		Expect(err).Should(BeNil())
+		for _, e := range events {
+			if e.Reason == reason {
+				return true
+			}
+		}
+		return false
+	}
+	hasEvent := func(reason string) bool {
+		return hasEventOf(app.Name, reason)
+	}
+
+	BeforeEach(func() {
+		setupNamespace(ctx, namespace)
+		setupTestDefinitions(ctx, []string{depWorkerDefYaml}, namespace)
+	})
+
+	It("should keep the resources removed from the application or deleted with it by their deletion policies", func() {
+		Expect(k8sClient.Create(ctx, app.DeepCopy())).Should(BeNil())
+		reconcileOnceAfterFinalizer(reconciler, reconcile.Request{NamespacedName: appKey})
+		v1Tracker := getTrackerKey(namespace, app.Name, "v1").Name
+		for _, name := range []string{"web", "orphan", "retain", "delete"} {
+			Expect(trackerOwners(name)).Should(Equal([]string{v1Tracker}))
+		}
+		d := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "orphan", Namespace: namespace}, d)).Should(BeNil())
+		Expect(d.GetAnnotations()).Should(HaveKeyWithValue(oam.AnnotationDeletionPolicy, "Orphan"))
+
+		By("remove the components from the application")
+		checkApp := &oamcore.Application{}
+		Expect(k8sClient.Get(ctx, appKey, checkApp)).Should(BeNil())
+		checkApp.Spec.Components = checkApp.Spec.Components[:1]
+		Expect(k8sClient.Update(ctx, checkApp)).Should(BeNil())
+		reconcileOnceAfterFinalizer(reconciler, reconcile.Request{NamespacedName: appKey})
+
+		v2TrackerKey := getTrackerKey(namespace, app.Name, "v2")
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "delete", Namespace: namespace}, &appsv1.Deployment{})).
+			Should(util.NotFoundMatcher{})
+		Expect(trackerOwners("orphan")).Should(BeEmpty())
+		Expect(trackerOwners("retain")).Should(Equal([]string{v2TrackerKey.Name}))
+		rt := &oamcore.ResourceTracker{}
+		Expect(k8sClient.Get(ctx, v2TrackerKey, rt)).Should(BeNil())
+		var tracked []string
+		for _, ref := range rt.Status.TrackedResources {
+			tracked = append(tracked, ref.Name)
+		}
+		Expect(tracked).Should(ConsistOf("web", "retain"))
+		Expect(hasEvent(velatypes.ReasonOrphaned)).Should(BeTrue())
+		Expect(hasEvent(velatypes.ReasonRetained)).Should(BeTrue())
+
+		By("delete the application with the deletion policy overridden by its annotation")
+		Expect(k8sClient.Get(ctx, appKey, checkApp)).Should(BeNil())
+		checkApp.SetAnnotations(map[string]string{oam.AnnotationDeletionPolicy: string(oamcore.DeletionPolicyOrphan)})
+		Expect(k8sClient.Update(ctx, checkApp)).Should(BeNil())
+		Expect(k8sClient.Delete(ctx, checkApp)).Should(BeNil())
+		reconcileOnce(reconciler, reconcile.Request{NamespacedName: appKey})
+		Expect(k8sClient.Get(ctx, appKey, checkApp)).Should(util.NotFoundMatcher{})
+		Expect(k8sClient.Get(ctx, v2TrackerKey, rt)).Should(util.NotFoundMatcher{})
+		Expect(trackerOwners("web")).Should(BeEmpty())
+		Expect(trackerOwners("retain")).Should(BeEmpty())
+	})
+
+	It("should keep the retained and orphaned resources when the application is deleted", func() {
+		deletedApp := app.DeepCopy()
+		deletedApp.SetName("app-deleted-with-deletion-policy")
+		for i := range deletedApp.Spec.Components {
+			deletedApp.Spec.Components[i].Name = "deleted-" + deletedApp.Spec.Components[i].Name
+		}
+		deletedAppKey := client.ObjectKey{Name: deletedApp.Name, Namespace: namespace}
+		Expect(k8sClient.Create(ctx, deletedApp)).Should(BeNil())
+		reconcileOnceAfterFinalizer(reconciler, reconcile.Request{NamespacedName: deletedAppKey})
+		v1TrackerKey := getTrackerKey(namespace, deletedApp.Name, "v1")
+		for _, name := range []string{"deleted-web", "deleted-orphan", "deleted-retain", "deleted-delete"} {
+			Expect(trackerOwners(name)).Should(Equal([]string{v1TrackerKey.Name}))
+		}
+
+		By("delete the application without overriding the deletion policy")
+		checkApp := &oamcore.Application{}
+		Expect(k8sClient.Get(ctx, deletedAppKey, checkApp)).Should(BeNil())
+		Expect(k8sClient.Delete(ctx, checkApp)).Should(BeNil())
+		reconcileOnce(reconciler, reconcile.Request{NamespacedName: deletedAppKey})
+		Expect(k8sClient.Get(ctx, deletedAppKey, checkApp)).Should(util.NotFoundMatcher{})
+		Expect(k8sClient.Get(ctx, v1TrackerKey, &oamcore.ResourceTracker{})).Should(util.NotFoundMatcher{})
+		// the released resources aren't deleted by the garbage collection of the resource tracker
+		Expect(trackerOwners("deleted-retain")).Should(BeEmpty())
+		Expect(trackerOwners("deleted-orphan")).Should(BeEmpty())
+		Expect(trackerOwners("deleted-web")).Should(Equal([]string{v1TrackerKey.Name}))
+		Expect(trackerOwners("deleted-delete")).Should(Equal([]string{v1TrackerKey.Name}))
+		Expect(hasEventOf(deletedApp.Name, velatypes.ReasonRetained)).Should(BeTrue())
+		Expect(hasEventOf(deletedApp.Name, velatypes.ReasonOrphaned)).Should(BeTrue())
+	})
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/pkg/appfile"
+	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/application/assemble"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// waitingComponent is a component not dispatched since the components it depends on are not ready
+type waitingComponent struct {
+	// waitingFor is the names of the components it depends on which are not ready
+	waitingFor []string
+	// reasons are why each of them is not ready
+	reasons []string
+}
+
+func (w *waitingComponent) message() string {
+	return "waiting for dependencies: " + strings.Join(w.reasons, ", ")
+}
+
+// checkComponentDependencies decides the components waiting for the components they depend on.
+// A component is ready to be depended on once its workload is dispatched for the current revision and it's healthy
+// by the health policy of its definition, so that the components depending on it are dispatched after it's updated.
+func (h *appHandler) checkComponentDependencies(ctx context.Context, appFile *appfile.Appfile) error {
+	h.componentOrder, h.waitingComponents = nil, nil
+	if !h.dispatchesManifests() {
+		return nil
+	}
+	order, err := appfile.SortComponents(h.app.Spec.Components)
+	if err != nil || order == nil {
+		return err
+	}
+	h.componentOrder = order
+	h.waitingComponents = make(map[string]*waitingComponent)
+
+	deps := appfile.ComponentDependencies(h.app.Spec.Components)
+	depended := make(map[string]bool)
+	for _, names := range deps {
+		for _, dep := range names {
+			depended[dep] = true
+		}
+	}
+	dispatched, err := h.dispatchedComponents(ctx, depended)
+	if err != nil {
+		return err
+	}
+	workloads := make(map[string]*appfile.Workload, len(appFile.Workloads))
+	for _, wl := range appFile.Workloads {
+		workloads[wl.Name] = wl
+	}
+
+	for _, name := range order {
+		waiting := &waitingComponent{}
+		for _, dep := range deps[name] {
+			var reason string
+			switch {
+			case h.waitingComponents[dep] != nil:
+				reason = fmt.Sprintf("%s is waiting for its dependencies", dep)
+			case !dispatched[dep]:
+				reason = fmt.Sprintf("%s is not dispatched yet", dep)
+			default:
+				// the health policy may fail to evaluate before the status of the workload is reported
+				_, healthy, err := h.collectComponentStatus(appFile, workloads[dep])
+				if err != nil {
+					klog.InfoS("Cannot check the health of the depended component", "application", klog.KObj(h.app),
+						"component", dep, "err", err)
+				}
+				if err != nil || !healthy {
+					reason = fmt.Sprintf("%s is not healthy", dep)
+				}
+			}
+			if reason != "" {
+				waiting.waitingFor = append(waiting.waitingFor, dep)
+				waiting.reasons = append(waiting.reasons, reason)
+			}
+		}
+		if len(waiting.waitingFor) > 0 {
+			klog.InfoS("Component is waiting for its dependencies", "application", klog.KObj(h.app),
+				"component", name, "waitingFor", waiting.waitingFor)
+			h.waitingComponents[name] = waiting
+		}
+	}
+	return nil
+}
+
+// dispatchedComponents returns which of the components have their workloads dispatched for the current revision.
+// The workloads of the components waiting for their dependencies are kept at the previous revision.
+func (h *appHandler) dispatchedComponents(ctx context.Context, names map[string]bool) (map[string]bool, error) {
+	a := assemble.NewAppManifests(h.currentAppRev).WithWorkloadOption(assemble.DiscoveryHelmBasedWorkload(ctx, h.r.Client))
+	workloads, _, _, err := a.GroupAssembledManifests()
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot assemble application manifests")
+	}
+	dispatched := make(map[string]bool, len(names))
+	for name := range names {
+		wl, ok := workloads[name]
+		if !ok {
+			continue
+		}
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(wl.GroupVersionKind())
+		if err := h.r.Get(ctx, client.ObjectKey{Name: wl.GetName(), Namespace: wl.GetNamespace()}, existing); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "cannot get workload of component %s", name)
+		}
+		dispatched[name] = existing.GetLabels()[oam.LabelAppRevision] == wl.GetLabels()[oam.LabelAppRevision]
+	}
+	return dispatched, nil
+}
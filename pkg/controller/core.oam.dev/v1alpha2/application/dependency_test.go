@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+var _ = Describe("Test dispatch components with dependsOn", func() {
+	ctx := context.Background()
+	namespace := "test-depends-on"
+
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-with-depends-on",
+			Namespace: namespace,
+		},
+		Spec: oamcore.ApplicationSpec{
+			Components: []oamcore.ApplicationComponent{{
+				Name:       "web",
+				Type:       "dep-worker",
+				DependsOn:  []string{"db"},
+				Properties: runtime.RawExtension{Raw: []byte(`{"image":"nginx"}`)},
+			}, {
+				Name:       "db",
+				Type:       "dep-worker",
+				Properties: runtime.RawExtension{Raw: []byte(`{"image":"mysql"}`)},
+			}},
+		},
+	}
+	appKey := client.ObjectKey{Name: app.Name, Namespace: app.Namespace}
+
+	reconcileApp := func() {
+		// the application is not healthy until all components are dispatched, so the error is ignored
+		_, _ = reconciler.Reconcile(reconcile.Request{NamespacedName: appKey})
+	}
+
+	BeforeEach(func() {
+		setupNamespace(ctx, namespace)
+		setupTestDefinitions(ctx, []string{depWorkerDefYaml}, namespace)
+	})
+
+	It("should dispatch the component after the components it depends on are healthy", func() {
+		Expect(k8sClient.Create(ctx, app.DeepCopy())).Should(BeNil())
+		// add the finalizer, dispatch db and check its health
+		reconcileApp()
+		reconcileApp()
+		reconcileApp()
+
+		By("only the component without dependencies is dispatched")
+		db := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "db", Namespace: namespace}, db)).Should(BeNil())
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: "web", Namespace: namespace}, &appsv1.Deployment{})
+		Expect(kerrors.IsNotFound(err)).Should(BeTrue())
+
+		checkApp := &oamcore.Application{}
+		Expect(k8sClient.Get(ctx, appKey, checkApp)).Should(BeNil())
+		var web *common.ApplicationComponentStatus
+		for i, svc := range checkApp.Status.Services {
+			if svc.Name == "web" {
+				web = &checkApp.Status.Services[i]
+			}
+		}
+		Expect(web).ShouldNot(BeNil())
+		Expect(web.Healthy).Should(BeFalse())
+		Expect(web.WaitingFor).Should(Equal([]string{"db"}))
+		Expect(web.Message).Should(Equal("waiting for dependencies: db is not healthy"))
+		Expect(checkApp.Status.GetCondition("HealthCheck").Message).Should(Equal("components web are waiting for their dependencies"))
+
+		By("the component is dispatched once the components it depends on are healthy")
+		db.Status.Replicas = 1
+		db.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, db)).Should(BeNil())
+		Eventually(func() error {
+			reconcileApp()
+			return k8sClient.Get(ctx, client.ObjectKey{Name: "web", Namespace: namespace}, &appsv1.Deployment{})
+		}, 10*time.Second, time.Second).Should(BeNil())
+
+		Expect(k8sClient.Get(ctx, appKey, checkApp)).Should(BeNil())
+		for _, svc := range checkApp.Status.Services {
+			Expect(svc.WaitingFor).Should(BeEmpty())
+		}
+	})
+})
+
+const depWorkerDefYaml = `apiVersion: core.oam.dev/v1beta1
+kind: ComponentDefinition
+metadata:
+  name: dep-worker
+spec:
+  workload:
+    definition:
+      apiVersion: apps/v1
+      kind: Deployment
+  status:
+    healthPolicy: |
+      ready: {readyReplicas: *0 | int} & context.output.status
+      isHealth: ready.readyReplicas > 0
+  schematic:
+    cue:
+      template: |
+        output: {
+          apiVersion: "apps/v1"
+          kind:       "Deployment"
+          spec: {
+            selector: matchLabels: "app.oam.dev/component": context.name
+            template: {
+              metadata: labels: "app.oam.dev/component": context.name
+              spec: containers: [{
+                name:  context.name
+                image: parameter.image
+              }]
+            }
+          }
+        }
+        parameter: {
+          image: string
+        }
+`
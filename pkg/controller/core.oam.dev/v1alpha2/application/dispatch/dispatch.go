@@ -86,6 +86,20 @@ func (a *AppManifestsDispatcher) StartAndSkipGC(rt *v1beta1.ResourceTracker) *Ap
 	return a
 }
 
+// WithServerSideApply return an AppManifestsDispatcher that applies resources by server-side apply,
+// the fields owned by other field managers are taken over if forceConflicts is true.
+func (a *AppManifestsDispatcher) WithServerSideApply(forceConflicts bool) *AppManifestsDispatcher {
+	a.applicator = apply.NewAPIApplicator(a.c).WithServerSideApply(forceConflicts)
+	return a
+}
+
+// WithDeletionPolicy return an AppManifestsDispatcher whose GC overrides the deletion policies of the resources by
+// the given one, e.g. the one set on the annotation of the application.
+func (a *AppManifestsDispatcher) WithDeletionPolicy(policy string) *AppManifestsDispatcher {
+	a.gcHandler = NewGCHandler(a.c, a.appRev.Namespace).WithDeletionPolicy(policy)
+	return a
+}
+
 // Dispatch apply manifests into k8s and return a resource tracker recording applied manifests' references.
 // If GC is enabled, it will do GC after applying.
 // If 'UpgradeAndSkipGC' is enabled, it will:
@@ -110,6 +124,61 @@ func (a *AppManifestsDispatcher) Dispatch(ctx context.Context, manifests []*unst
 	return a.currentRT.DeepCopy(), nil
 }
 
+// Adopt makes the existing resources of the manifests controlled by the resource tracker of the current revision
+// without applying them, so that the resources of the previous revision are kept as they are by GC until they're
+// dispatched, e.g. the resources of a component waiting for the components it depends on.
+// The resources not existing or not controlled by the previous resource tracker are skipped.
+func (a *AppManifestsDispatcher) Adopt(ctx context.Context, manifests []*unstructured.Unstructured) error {
+	if err := a.validateAndComplete(ctx); err != nil {
+		return err
+	}
+	if err := a.createOrGetResourceTracker(ctx); err != nil {
+		return err
+	}
+	return a.adoptManifests(ctx, manifests)
+}
+
+// KeptResources returns the resources kept from GC by their deletion policies in the last dispatch
+func (a *AppManifestsDispatcher) KeptResources() (orphaned, retained []v1beta1.TypedReference) {
+	return a.gcHandler.KeptResources()
+}
+
+// AdoptComponents adopts the existing resources of the components controlled by the previous resource tracker like
+// Adopt, it's used for the components whose manifests are not rendered, e.g. the components whose inputs are not ready.
+// The resources of a component are told by the component label.
+func (a *AppManifestsDispatcher) AdoptComponents(ctx context.Context, components []string) error {
+	if err := a.validateAndComplete(ctx); err != nil {
+		return err
+	}
+	if err := a.createOrGetResourceTracker(ctx); err != nil {
+		return err
+	}
+	if a.previousRT == nil || a.previousRT.Name == a.currentRTName {
+		return nil
+	}
+	names := make(map[string]bool, len(components))
+	for _, name := range components {
+		names[name] = true
+	}
+	var manifests []*unstructured.Unstructured
+	for _, ref := range a.previousRT.Status.TrackedResources {
+		existing := &unstructured.Unstructured{}
+		existing.SetAPIVersion(ref.APIVersion)
+		existing.SetKind(ref.Kind)
+		if err := a.c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, existing); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "cannot get resource, name: %q apiVersion: %q kind: %q",
+				ref.Name, ref.APIVersion, ref.Kind)
+		}
+		if names[existing.GetLabels()[oam.LabelAppComponent]] {
+			manifests = append(manifests, existing)
+		}
+	}
+	return a.adoptManifests(ctx, manifests)
+}
+
 // ReferenceScopes add workload reference to scopes' workloadRefPath
 func (a *AppManifestsDispatcher) ReferenceScopes(ctx context.Context, wlRef *v1beta1.TypedReference, scopes []*v1beta1.TypedReference) error {
 	// TODO handle scopes
@@ -185,14 +254,7 @@ func (a *AppManifestsDispatcher) applyAndRecordManifests(ctx context.Context, ma
 		ctrlUIDs = append(ctrlUIDs, a.previousRT.UID)
 	}
 	applyOpts := []apply.ApplyOption{apply.MustBeControllableByAny(ctrlUIDs)}
-	ownerRef := metav1.OwnerReference{
-		APIVersion:         v1beta1.SchemeGroupVersion.String(),
-		Kind:               reflect.TypeOf(v1beta1.ResourceTracker{}).Name(),
-		Name:               a.currentRT.Name,
-		UID:                a.currentRT.UID,
-		Controller:         pointer.BoolPtr(true),
-		BlockOwnerDeletion: pointer.BoolPtr(true),
-	}
+	ownerRef := a.currentRTOwnerRef()
 	for _, rsc := range manifests {
 		// each resource applied by dispatcher MUST be controlled by resource tracker
 		setOrOverrideControllerOwner(rsc, ownerRef)
@@ -208,6 +270,54 @@ func (a *AppManifestsDispatcher) applyAndRecordManifests(ctx context.Context, ma
 	return a.updateResourceTrackerStatus(ctx, manifests)
 }
 
+func (a *AppManifestsDispatcher) currentRTOwnerRef() metav1.OwnerReference {
+	return resourceTrackerOwnerRef(a.currentRT)
+}
+
+func resourceTrackerOwnerRef(rt *v1beta1.ResourceTracker) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion:         v1beta1.SchemeGroupVersion.String(),
+		Kind:               reflect.TypeOf(v1beta1.ResourceTracker{}).Name(),
+		Name:               rt.Name,
+		UID:                rt.UID,
+		Controller:         pointer.BoolPtr(true),
+		BlockOwnerDeletion: pointer.BoolPtr(true),
+	}
+}
+
+func (a *AppManifestsDispatcher) adoptManifests(ctx context.Context, manifests []*unstructured.Unstructured) error {
+	ownerRef := a.currentRTOwnerRef()
+	var adopted []*unstructured.Unstructured
+	for _, rsc := range manifests {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(rsc.GroupVersionKind())
+		if err := a.c.Get(ctx, client.ObjectKey{Name: rsc.GetName(), Namespace: rsc.GetNamespace()}, existing); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "cannot get resource, name: %q apiVersion: %q kind: %q",
+				rsc.GetName(), rsc.GetAPIVersion(), rsc.GetKind())
+		}
+		owner := metav1.GetControllerOf(existing)
+		switch {
+		case owner != nil && owner.UID == a.currentRT.UID:
+		case owner != nil && a.previousRT != nil && owner.UID == a.previousRT.UID:
+			patch := client.MergeFrom(existing.DeepCopy())
+			setOrOverrideControllerOwner(existing, ownerRef)
+			if err := a.c.Patch(ctx, existing, patch); err != nil {
+				return errors.Wrapf(err, "cannot adopt resource, name: %q apiVersion: %q kind: %q",
+					rsc.GetName(), rsc.GetAPIVersion(), rsc.GetKind())
+			}
+			klog.InfoS("Successfully adopt a resource", "object",
+				klog.KObj(rsc), "apiVersion", rsc.GetAPIVersion(), "kind", rsc.GetKind())
+		default:
+			continue
+		}
+		adopted = append(adopted, rsc)
+	}
+	return a.updateResourceTrackerStatus(ctx, adopted)
+}
+
 func (a *AppManifestsDispatcher) updateResourceTrackerStatus(ctx context.Context, appliedManifests []*unstructured.Unstructured) error {
 	// merge applied resources and already tracked ones
 	if a.currentRT.Status.TrackedResources == nil {
@@ -22,36 +22,56 @@ import (
 
 	"github.com/pkg/errors"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/oam"
 )
 
 // GarbageCollector do GC according two resource trackers
 type GarbageCollector interface {
 	GarbageCollect(ctx context.Context, oldRT, newRT *v1beta1.ResourceTracker) error
+	// KeptResources returns the resources kept by their deletion policies in the last GC
+	KeptResources() (orphaned, retained []v1beta1.TypedReference)
 }
 
 // NewGCHandler create a GCHandler
 func NewGCHandler(c client.Client, ns string) *GCHandler {
-	return &GCHandler{c, ns, nil, nil}
+	return &GCHandler{c: c, namespace: ns}
 }
 
 // GCHandler implement GarbageCollector interface
 type GCHandler struct {
 	c         client.Client
 	namespace string
+	// deletionPolicy overrides the deletion policies of the resources if it's set
+	deletionPolicy string
 
 	oldRT *v1beta1.ResourceTracker
 	newRT *v1beta1.ResourceTracker
+
+	orphaned []v1beta1.TypedReference
+	retained []v1beta1.TypedReference
+}
+
+// WithDeletionPolicy returns a GCHandler whose deletion policy overrides the ones of the resources,
+// e.g. the one set on the application.
+func (h *GCHandler) WithDeletionPolicy(policy string) *GCHandler {
+	h.deletionPolicy = policy
+	return h
 }
 
-// GarbageCollect delete the old resources that are no longer in the new resource tracker
+// GarbageCollect delete the old resources that are no longer in the new resource tracker.
+// The resources are kept by their deletion policies: the orphaned ones are released from the old resource tracker,
+// and the retained ones are moved to the new resource tracker.
 func (h *GCHandler) GarbageCollect(ctx context.Context, oldRT, newRT *v1beta1.ResourceTracker) error {
 	h.oldRT = oldRT
 	h.newRT = newRT
+	h.orphaned, h.retained = nil, nil
 	if err := h.validate(); err != nil {
 		return err
 	}
@@ -65,18 +85,44 @@ func (h *GCHandler) GarbageCollect(ctx context.Context, oldRT, newRT *v1beta1.Re
 				break
 			}
 		}
-		if isRemoved {
-			toBeDeleted := &unstructured.Unstructured{}
-			toBeDeleted.SetAPIVersion(oldRsc.APIVersion)
-			toBeDeleted.SetKind(oldRsc.Kind)
-			toBeDeleted.SetNamespace(oldRsc.Namespace)
-			toBeDeleted.SetName(oldRsc.Name)
-			if err := h.c.Delete(ctx, toBeDeleted); err != nil && !kerrors.IsNotFound(err) {
-				klog.ErrorS(err, "Failed to delete a resource", "name", oldRsc.Name, "apiVersion", oldRsc.APIVersion, "kind", oldRsc.Kind)
-				return errors.Wrapf(err, "cannot delete resource %q", oldRsc)
+		if !isRemoved {
+			continue
+		}
+		toBeDeleted := &unstructured.Unstructured{}
+		toBeDeleted.SetAPIVersion(oldRsc.APIVersion)
+		toBeDeleted.SetKind(oldRsc.Kind)
+		if err := h.c.Get(ctx, client.ObjectKey{Namespace: oldRsc.Namespace, Name: oldRsc.Name}, toBeDeleted); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "cannot get resource %q", oldRsc)
+		}
+		switch GetDeletionPolicy(toBeDeleted, h.deletionPolicy) {
+		case v1beta1.DeletionPolicyOrphan:
+			if err := releaseResource(ctx, h.c, toBeDeleted, h.oldRT); err != nil {
+				return err
 			}
-			klog.InfoS("Successfully GC a resource", "name", oldRsc.Name, "apiVersion", oldRsc.APIVersion, "kind", oldRsc.Kind)
+			klog.InfoS("Orphan a resource by its deletion policy", "name", oldRsc.Name, "apiVersion", oldRsc.APIVersion, "kind", oldRsc.Kind)
+			h.orphaned = append(h.orphaned, oldRsc)
+			continue
+		case v1beta1.DeletionPolicyRetain:
+			patch := client.MergeFrom(toBeDeleted.DeepCopy())
+			setOrOverrideControllerOwner(toBeDeleted, resourceTrackerOwnerRef(h.newRT))
+			if err := h.c.Patch(ctx, toBeDeleted, patch); err != nil {
+				return errors.Wrapf(err, "cannot retain resource %q", oldRsc)
+			}
+			klog.InfoS("Retain a resource by its deletion policy", "name", oldRsc.Name, "apiVersion", oldRsc.APIVersion, "kind", oldRsc.Kind)
+			h.retained = append(h.retained, oldRsc)
+			continue
+		}
+		if err := h.c.Delete(ctx, toBeDeleted); err != nil && !kerrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to delete a resource", "name", oldRsc.Name, "apiVersion", oldRsc.APIVersion, "kind", oldRsc.Kind)
+			return errors.Wrapf(err, "cannot delete resource %q", oldRsc)
 		}
+		klog.InfoS("Successfully GC a resource", "name", oldRsc.Name, "apiVersion", oldRsc.APIVersion, "kind", oldRsc.Kind)
+	}
+	if err := h.trackRetainedResources(ctx); err != nil {
+		return err
 	}
 	// delete the old resource tracker
 	if err := h.c.Delete(ctx, h.oldRT); err != nil && !kerrors.IsNotFound(err) {
@@ -88,6 +134,118 @@ func (h *GCHandler) GarbageCollect(ctx context.Context, oldRT, newRT *v1beta1.Re
 	return nil
 }
 
+// KeptResources returns the resources kept by their deletion policies in the last GC
+func (h *GCHandler) KeptResources() (orphaned, retained []v1beta1.TypedReference) {
+	return h.orphaned, h.retained
+}
+
+// trackRetainedResources records the retained resources in the new resource tracker,
+// so that they're still tracked by the application and released when it's deleted.
+func (h *GCHandler) trackRetainedResources(ctx context.Context) error {
+	if len(h.retained) == 0 {
+		return nil
+	}
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		rt := &v1beta1.ResourceTracker{}
+		if err := h.c.Get(ctx, client.ObjectKey{Name: h.newRT.Name}, rt); err != nil {
+			return err
+		}
+		for _, ref := range h.retained {
+			if !isTracked(rt, ref) {
+				rt.Status.TrackedResources = append(rt.Status.TrackedResources, ref)
+			}
+		}
+		if err := h.c.Status().Update(ctx, rt); err != nil {
+			return err
+		}
+		h.newRT.Status = rt.Status
+		return nil
+	}); err != nil {
+		klog.ErrorS(err, "Failed to track retained resources", "resourceTracker", h.newRT.Name)
+		return errors.Wrap(err, "cannot track retained resources")
+	}
+	return nil
+}
+
+// GetDeletionPolicy returns the deletion policy of a dispatched resource. The given policy overrides the one of the
+// resource if it's valid, e.g. the policy set on the application. It's Delete by default.
+func GetDeletionPolicy(obj *unstructured.Unstructured, override string) v1beta1.DeletionPolicy {
+	for _, policy := range []string{override, obj.GetAnnotations()[oam.AnnotationDeletionPolicy]} {
+		switch p := v1beta1.DeletionPolicy(policy); p {
+		case v1beta1.DeletionPolicyDelete, v1beta1.DeletionPolicyOrphan, v1beta1.DeletionPolicyRetain:
+			return p
+		}
+	}
+	return v1beta1.DeletionPolicyDelete
+}
+
+// ReleaseResources releases the resources tracked by the resource tracker whose deletion policy is Orphan or Retain
+// from it, so that they're not deleted with the resource tracker, e.g. when the application is deleted. The given
+// policy overrides the ones of the resources if it's valid. It returns the references of the released resources.
+func ReleaseResources(ctx context.Context, c client.Client, rtName string, override string) (orphaned, retained []v1beta1.TypedReference, err error) {
+	rt := &v1beta1.ResourceTracker{}
+	if err := c.Get(ctx, client.ObjectKey{Name: rtName}, rt); err != nil {
+		return nil, nil, client.IgnoreNotFound(err)
+	}
+	for _, ref := range rt.Status.TrackedResources {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		if err := c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, errors.Wrapf(err, "cannot get resource %q", ref)
+		}
+		policy := GetDeletionPolicy(obj, override)
+		if policy == v1beta1.DeletionPolicyDelete {
+			continue
+		}
+		if err := releaseResource(ctx, c, obj, rt); err != nil {
+			return nil, nil, err
+		}
+		klog.InfoS("Release a resource by its deletion policy", "resourceTracker", rt.Name, "name", ref.Name,
+			"apiVersion", ref.APIVersion, "kind", ref.Kind, "policy", policy)
+		if policy == v1beta1.DeletionPolicyOrphan {
+			orphaned = append(orphaned, ref)
+		} else {
+			retained = append(retained, ref)
+		}
+	}
+	return orphaned, retained, nil
+}
+
+// releaseResource removes the owner reference to the resource tracker from the resource,
+// so that it's not deleted with the resource tracker.
+func releaseResource(ctx context.Context, c client.Client, obj *unstructured.Unstructured, rt *v1beta1.ResourceTracker) error {
+	var owners []metav1.OwnerReference
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.UID != rt.UID {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == len(obj.GetOwnerReferences()) {
+		return nil
+	}
+	patch := client.MergeFrom(obj.DeepCopy())
+	obj.SetOwnerReferences(owners)
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return errors.Wrapf(err, "cannot release resource, name: %q apiVersion: %q kind: %q",
+			obj.GetName(), obj.GetAPIVersion(), obj.GetKind())
+	}
+	return nil
+}
+
+func isTracked(rt *v1beta1.ResourceTracker, ref v1beta1.TypedReference) bool {
+	for _, tracked := range rt.Status.TrackedResources {
+		if tracked.APIVersion == ref.APIVersion && tracked.Kind == ref.Kind &&
+			tracked.Name == ref.Name && tracked.Namespace == ref.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
 // validate two resource trackers come from the same application
 func (h *GCHandler) validate() error {
 	oldRTName := h.oldRT.Name
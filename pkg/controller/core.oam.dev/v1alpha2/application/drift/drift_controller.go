@@ -0,0 +1,278 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	velatypes "github.com/oam-dev/kubevela/apis/types"
+	core "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev"
+	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/application/dispatch"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/apply"
+)
+
+// Policy is the policy of an application on the drift of its dispatched resources,
+// i.e. the changes made to them out of KubeVela, e.g. by `kubectl edit`
+type Policy string
+
+const (
+	// PolicyReport records an event on the application with the drifted fields
+	PolicyReport Policy = "report"
+	// PolicyRepair applies the last-applied configuration to the drifted resources
+	PolicyRepair Policy = "repair"
+	// PolicyIgnore doesn't detect the drift
+	PolicyIgnore Policy = "ignore"
+)
+
+// GetPolicy returns the drift policy set by the annotation of the application, it's report by default.
+func GetPolicy(app *v1beta1.Application) Policy {
+	switch p := Policy(app.GetAnnotations()[oam.AnnotationDriftPolicy]); p {
+	case PolicyRepair, PolicyIgnore:
+		return p
+	default:
+		return PolicyReport
+	}
+}
+
+// Reconciler detects the drift of the resources tracked by the resource tracker of the latest app revision,
+// it watches the kinds of the tracked resources so that the drift is detected once it happens.
+type Reconciler struct {
+	client.Client
+	// reader reads the tracked resources from the cache of the watches
+	reader     client.Reader
+	applicator apply.Applicator
+	record     event.Recorder
+	controller controller.Controller
+
+	mu      sync.Mutex
+	watched map[schema.GroupVersionKind]bool
+	// reported is the drifted fields of the resources reported by the name of their resource tracker,
+	// the same drift is reported once, they're forgotten when the resource tracker is deleted or outdated
+	reported map[string]map[string]string
+}
+
+// Reconcile detects the drift of the resources tracked by a resource tracker
+func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	rt := &v1beta1.ResourceTracker{}
+	if err := r.Get(ctx, req.NamespacedName, rt); err != nil {
+		if kerrors.IsNotFound(err) {
+			r.forgetTracker(req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if rt.DeletionTimestamp != nil {
+		r.forgetTracker(rt.Name)
+		return ctrl.Result{}, nil
+	}
+	if err := r.watchTrackedKinds(rt); err != nil {
+		return ctrl.Result{}, err
+	}
+	app, err := r.getApplication(ctx, rt)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var policy Policy
+	if app != nil {
+		policy = GetPolicy(app)
+	}
+	if app == nil || policy == PolicyIgnore {
+		r.forgetTracker(rt.Name)
+		return ctrl.Result{}, nil
+	}
+
+	for _, ref := range rt.Status.TrackedResources {
+		key := fmt.Sprintf("%s/%s/%s/%s", ref.APIVersion, ref.Kind, ref.Namespace, ref.Name)
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		if err := r.reader.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+			if kerrors.IsNotFound(err) {
+				// the deleted resources are created again when the application is reconciled
+				continue
+			}
+			return ctrl.Result{}, errors.Wrapf(err, "cannot get tracked resource %s", key)
+		}
+		paths, err := apply.DetectDrift(obj)
+		if err != nil {
+			return ctrl.Result{}, errors.WithMessagef(err, "cannot detect drift of %s", key)
+		}
+		if !r.report(rt.Name, key, paths) {
+			continue
+		}
+		msg := fmt.Sprintf("%s %s drifted from the last-applied configuration: %s",
+			ref.Kind, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, strings.Join(paths, ", "))
+		klog.InfoS("Detected drift of a tracked resource", "resourceTracker", rt.Name, "object", klog.KObj(obj),
+			"apiVersion", ref.APIVersion, "kind", ref.Kind, "fields", paths, "policy", policy)
+		if policy != PolicyRepair {
+			r.record.Event(app, event.Warning(velatypes.ReasonDrifted, errors.New(msg)))
+			continue
+		}
+		desired, err := apply.LastAppliedObject(obj)
+		if err == nil {
+			err = r.applicator.Apply(ctx, desired, apply.MustBeControllableBy(rt.UID))
+		}
+		if err != nil {
+			r.forget(rt.Name, key)
+			r.record.Event(app, event.Warning(velatypes.ReasonFailedRepair, errors.WithMessage(err, msg)))
+			return ctrl.Result{}, errors.WithMessagef(err, "cannot repair drift of %s", key)
+		}
+		r.record.Event(app, event.Normal(velatypes.ReasonRepaired, msg))
+	}
+	return ctrl.Result{}, nil
+}
+
+// getApplication returns the application if the resource tracker belongs to its latest revision,
+// the drift is neither detected when the application is deleted nor when it's released by rollout.
+func (r *Reconciler) getApplication(ctx context.Context, rt *v1beta1.ResourceTracker) (*v1beta1.Application, error) {
+	name, namespace := rt.GetLabels()[oam.LabelAppName], rt.GetLabels()[oam.LabelAppNamespace]
+	if name == "" || namespace == "" {
+		return nil, nil
+	}
+	app := &v1beta1.Application{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, app); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if app.DeletionTimestamp != nil || app.Status.LatestRevision == nil ||
+		dispatch.ConstructResourceTrackerName(app.Status.LatestRevision.Name, namespace) != rt.Name {
+		return nil, nil
+	}
+	if len(app.GetAnnotations()[oam.AnnotationAppRollout]) != 0 || app.Spec.RolloutPlan != nil {
+		return nil, nil
+	}
+	return app, nil
+}
+
+// report returns true if the drift of the resource tracked by the resource tracker isn't reported yet
+func (r *Reconciler) report(tracker, key string, paths []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reported := r.reported[tracker]
+	if len(paths) == 0 {
+		delete(reported, key)
+		if len(reported) == 0 {
+			delete(r.reported, tracker)
+		}
+		return false
+	}
+	drift := strings.Join(paths, ",")
+	if reported[key] == drift {
+		return false
+	}
+	if reported == nil {
+		reported = make(map[string]string)
+		r.reported[tracker] = reported
+	}
+	reported[key] = drift
+	return true
+}
+
+func (r *Reconciler) forget(tracker, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.reported[tracker], key)
+}
+
+// forgetTracker forgets the drift reported of the resources tracked by the resource tracker
+func (r *Reconciler) forgetTracker(tracker string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.reported, tracker)
+}
+
+// watchTrackedKinds watches the kinds of the tracked resources, their changes trigger the reconciliation
+// of the resource tracker controlling them.
+func (r *Reconciler) watchTrackedKinds(rt *v1beta1.ResourceTracker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ref := range rt.Status.TrackedResources {
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		if r.watched[gvk] {
+			continue
+		}
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		if err := r.controller.Watch(&source.Kind{Type: u},
+			&handler.EnqueueRequestForOwner{OwnerType: &v1beta1.ResourceTracker{}, IsController: true},
+			desiredStateChanged); err != nil {
+			return errors.Wrapf(err, "cannot watch %s", gvk.String())
+		}
+		klog.InfoS("Watching tracked resources for drift", "apiVersion", ref.APIVersion, "kind", ref.Kind)
+		r.watched[gvk] = true
+	}
+	return nil
+}
+
+// desiredStateChanged filters the updates of the tracked resources which may change their desired state,
+// the updates of resources having no generation, e.g. ConfigMaps, are always passed.
+var desiredStateChanged = predicate.Funcs{
+	CreateFunc: func(ctrlevent.CreateEvent) bool { return false },
+	UpdateFunc: func(e ctrlevent.UpdateEvent) bool {
+		if e.MetaNew.GetGeneration() == 0 || e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() {
+			return true
+		}
+		return !reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) ||
+			!reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations())
+	},
+	DeleteFunc:  func(ctrlevent.DeleteEvent) bool { return false },
+	GenericFunc: func(ctrlevent.GenericEvent) bool { return false },
+}
+
+// Setup adds a controller detecting the drift of the resources dispatched by applications.
+func Setup(mgr ctrl.Manager, args core.Args) error {
+	if !args.DriftDetection {
+		return nil
+	}
+	r := &Reconciler{
+		Client:     mgr.GetClient(),
+		reader:     mgr.GetCache(),
+		applicator: apply.NewAPIApplicator(mgr.GetClient()),
+		record: event.NewAPIRecorder(mgr.GetEventRecorderFor("Drift")).
+			WithAnnotations("controller", "Drift"),
+		watched:  make(map[schema.GroupVersionKind]bool),
+		reported: make(map[string]map[string]string),
+	}
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		Named("drift").
+		For(&v1beta1.ResourceTracker{}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	return nil
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+func TestReportedDriftForgottenWithResourceTracker(t *testing.T) {
+	s := runtime.NewScheme()
+	assert.NoError(t, v1beta1.SchemeBuilder.AddToScheme(s))
+	r := &Reconciler{
+		Client:   fake.NewFakeClientWithScheme(s),
+		reported: make(map[string]map[string]string),
+	}
+
+	assert.True(t, r.report("app-v1-default", "apps/v1/Deployment/default/web", []string{"spec.replicas"}))
+	assert.False(t, r.report("app-v1-default", "apps/v1/Deployment/default/web", []string{"spec.replicas"}))
+	assert.True(t, r.report("app-v1-default", "v1/Service/default/web", []string{"spec.type"}))
+	assert.True(t, r.report("app-v2-default", "apps/v1/Deployment/default/web", []string{"spec.replicas"}))
+
+	// the drift repaired is forgotten, and the resource tracker without any drift too
+	assert.False(t, r.report("app-v2-default", "apps/v1/Deployment/default/web", nil))
+	assert.NotContains(t, r.reported, "app-v2-default")
+
+	// the drift of the resources tracked by a deleted resource tracker is forgotten
+	_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "app-v1-default"}})
+	assert.NoError(t, err)
+	assert.Empty(t, r.reported)
+}
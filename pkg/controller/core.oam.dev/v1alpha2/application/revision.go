@@ -50,6 +50,9 @@ import (
 const (
 	// ConfigMapKeyResources is the key in ConfigMap Data field for containing data of resources
 	ConfigMapKeyResources = "resources"
+
+	// maxWorkflowRuns is the maximum number of workflow runs kept in the status of an app revision
+	maxWorkflowRuns = 10
 )
 
 func (h *appHandler) createResourcesConfigMap(ctx context.Context,
@@ -138,6 +141,8 @@ func (h *appHandler) gatherRevisionSpec(af *appfile.Appfile) (*v1beta1.Applicati
 	copiedApp.Status = common.AppStatus{}
 	// AppRevision shouldn't contain RolloutPlan
 	copiedApp.Spec.RolloutPlan = nil
+	// the workflow is recorded with its template resolved, so the revision changes with the template
+	copiedApp.Spec.Workflow = af.Workflow
 	appRev := &v1beta1.ApplicationRevision{
 		Spec: v1beta1.ApplicationRevisionSpec{
 			Application:          *copiedApp,
@@ -559,6 +564,50 @@ func (h *appHandler) updateAppLatestRevisionStatus(ctx context.Context) error {
 	return nil
 }
 
+// recordWorkflowRun records the finished or terminated workflow run in the status of the app revision it's executed for.
+// A run is identified by its start time, so recording it again updates the record rather than adding a new one.
+func (h *appHandler) recordWorkflowRun(ctx context.Context) error {
+	wfStatus := h.app.Status.Workflow
+	if wfStatus == nil || wfStatus.StartTime == nil || wfStatus.EndTime == nil {
+		return nil
+	}
+	run := common.WorkflowRun{
+		StartTime:  *wfStatus.StartTime.DeepCopy(),
+		EndTime:    *wfStatus.EndTime.DeepCopy(),
+		Terminated: wfStatus.Terminated,
+	}
+	for _, step := range wfStatus.Steps {
+		run.Steps = append(run.Steps, *step.DeepCopy())
+	}
+
+	appRev := &v1beta1.ApplicationRevision{}
+	if err := h.r.Get(ctx, client.ObjectKey{Namespace: h.app.Namespace, Name: wfStatus.AppRevision}, appRev); err != nil {
+		return errors.WithMessagef(err, "get app revision %s", wfStatus.AppRevision)
+	}
+	origin := appRev.DeepCopy()
+	runs := appRev.Status.WorkflowRuns
+	recorded := false
+	for i := range runs {
+		// the time in status is kept in seconds
+		if runs[i].StartTime.Unix() == run.StartTime.Unix() {
+			runs[i] = run
+			recorded = true
+		}
+	}
+	if !recorded {
+		runs = append(runs, run)
+	}
+	if len(runs) > maxWorkflowRuns {
+		runs = runs[len(runs)-maxWorkflowRuns:]
+	}
+	appRev.Status.WorkflowRuns = runs
+	if apiequality.Semantic.DeepEqual(origin.Status, appRev.Status) {
+		return nil
+	}
+	return errors.WithMessagef(h.r.Status().Patch(ctx, appRev, client.MergeFrom(origin)),
+		"record workflow run in app revision %s", appRev.Name)
+}
+
 // cleanUpApplicationRevision check all appRevisions of the application, remove them if the number of them exceed the limit
 func cleanUpApplicationRevision(ctx context.Context, h *appHandler) error {
 	listOpts := []client.ListOption{
@@ -218,6 +218,21 @@ var _ = Describe("test generate revision ", func() {
 		verifyNotEqual()
 	})
 
+	It("Test app revision records the resolved workflow without changing the application", func() {
+		app.Spec.Workflow = &v1beta1.Workflow{Ref: "deploy-template"}
+		resolved := &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{{Name: "deploy", Type: "apply-component"}}}
+		appRev, hash, err := handler.gatherRevisionSpec(&appfile.Appfile{Workflow: resolved})
+		Expect(err).Should(BeNil())
+		Expect(appRev.Spec.Application.Spec.Workflow).Should(Equal(resolved))
+		Expect(app.Spec.Workflow).Should(Equal(&v1beta1.Workflow{Ref: "deploy-template"}))
+
+		By("the revision changes with the steps resolved from the template")
+		resolved = &v1beta1.Workflow{Steps: []v1beta1.WorkflowStep{{Name: "deploy", Type: "apply-application"}}}
+		_, newHash, err := handler.gatherRevisionSpec(&appfile.Appfile{Workflow: resolved})
+		Expect(err).Should(BeNil())
+		Expect(newHash).ShouldNot(Equal(hash))
+	})
+
 	It("Test apply success for none rollout case", func() {
 		By("Apply the application")
 		appParser := appfile.NewApplicationParser(reconciler.Client, reconciler.dm, reconciler.pd)
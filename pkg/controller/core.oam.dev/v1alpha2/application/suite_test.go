@@ -140,6 +140,7 @@ var _ = BeforeSuite(func(done Done) {
 		Recorder:         event.NewAPIRecorder(recorder),
 		appRevisionLimit: appRevisionLimit,
 		applicator:       apply.NewAPIApplicator(k8sClient),
+		approvalsStamped: true,
 	}
 	// setup the controller manager since we need the component handler to run in the background
 	ctlManager, err = ctrl.NewManager(cfg, ctrl.Options{
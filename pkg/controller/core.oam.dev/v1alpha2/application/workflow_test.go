@@ -36,7 +36,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	velatypes "github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
 	"github.com/oam-dev/kubevela/pkg/workflow"
 )
@@ -156,6 +159,73 @@ var _ = Describe("Test Workflow", func() {
 			Name:      "test-wf2",
 			Namespace: appWithWorkflow.Namespace,
 		}, step2obj)).Should(BeNil())
+
+		// mark step 2 succeeded, the finished run is recorded in the app revision
+		markWorkflowSucceeded(step2obj)
+		Expect(k8sClient.Update(ctx, step2obj)).Should(BeNil())
+		_, _ = reconciler.Reconcile(reconcile.Request{NamespacedName: client.ObjectKey{
+			Name:      appWithWorkflow.Name,
+			Namespace: appWithWorkflow.Namespace,
+		}})
+
+		appRev := &oamcore.ApplicationRevision{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{
+			Name:      appWithWorkflow.Name + "-v1",
+			Namespace: namespace,
+		}, appRev)).Should(BeNil())
+		Expect(appRev.Status.WorkflowRuns).Should(HaveLen(1))
+		run := appRev.Status.WorkflowRuns[0]
+		Expect(run.Terminated).Should(BeFalse())
+		Expect(run.EndTime.Before(&run.StartTime)).Should(BeFalse())
+		Expect(run.Steps).Should(HaveLen(2))
+		for _, step := range run.Steps {
+			Expect(step.Phase).Should(Equal(common.WorkflowStepPhaseSucceeded))
+			Expect(step.EndTime).ShouldNot(BeNil())
+			Expect(step.ResourceRef.Name).Should(Equal(step.Name))
+		}
+	})
+
+	It("should keep the approval made while the application is being reconciled", func() {
+		appWithApproval := appWithWorkflow.DeepCopy()
+		appWithApproval.Name = "test-wf-approval"
+		appWithApproval.Spec.Workflow.Steps[0] = oamcore.WorkflowStep{
+			Name: "approve",
+			Type: "suspend",
+		}
+		appKey := client.ObjectKey{Name: appWithApproval.Name, Namespace: namespace}
+		Expect(k8sClient.Create(ctx, appWithApproval)).Should(BeNil())
+		tryReconcile(reconciler, appWithApproval.Name, namespace)
+		tryReconcile(reconciler, appWithApproval.Name, namespace)
+
+		// a reconcile starts from the application before it's approved
+		stale := &oamcore.Application{}
+		Expect(k8sClient.Get(ctx, appKey, stale)).Should(BeNil())
+		Expect(stale.Status.Workflow).ShouldNot(BeNil())
+		Expect(stale.Status.Workflow.Steps[0].Reason).Should(Equal(common.WorkflowStepReasonWaitingForApproval))
+
+		approved := stale.DeepCopy()
+		approvals, err := json.Marshal(velatypes.WorkflowApprovals{"approve": {
+			WorkflowStepApproval: common.WorkflowStepApproval{Approved: true, Approver: "alice"},
+			AppRevision:          stale.Status.Workflow.AppRevision,
+		}})
+		Expect(err).Should(BeNil())
+		approved.SetAnnotations(map[string]string{oam.AnnotationWorkflowApprovals: string(approvals)})
+		Expect(k8sClient.Update(ctx, approved)).Should(BeNil())
+
+		// the reconcile writes the status computed from the stale application, it may be rejected
+		// for the stale resource version, either way the approval is kept in the annotation
+		stale.Status.Workflow.Steps[0].Message = "still waiting for approval"
+		_ = reconciler.patchStatus(ctx, stale)
+
+		tryReconcile(reconciler, appWithApproval.Name, namespace)
+		checkApp := &oamcore.Application{}
+		Expect(k8sClient.Get(ctx, appKey, checkApp)).Should(BeNil())
+		Expect(checkApp.GetAnnotations()).Should(HaveKey(oam.AnnotationWorkflowApprovals))
+		Expect(checkApp.Status.Workflow.Steps[0].Phase).Should(Equal(common.WorkflowStepPhaseSucceeded))
+		Expect(checkApp.Status.Workflow.Steps[0].Approval.Approver).Should(Equal("alice"))
+		step2obj := &unstructured.Unstructured{}
+		step2obj.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Kind: "Foo", Version: "v1"})
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "test-wf2", Namespace: namespace}, step2obj)).Should(BeNil())
 	})
 })
 
@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubepackages
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/prometheus/client_golang/prometheus"
+	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	oamctrl "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev"
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+)
+
+const (
+	// TriggerCRD labels the refreshes of the packages triggered by the changes of CRDs
+	TriggerCRD = "crd"
+	// TriggerPeriodic labels the periodic refreshes of all packages
+	TriggerPeriodic = "periodic"
+
+	// DefaultRefreshWindow is how long the refresh waits for no more CRDs to change,
+	// so the packages of the CRDs changed in a burst, e.g. installed by a chart, are refreshed once.
+	DefaultRefreshWindow = 2 * time.Second
+)
+
+var packageParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kubevela_cue_package_parse_errors_total",
+	Help: "Number of refreshes of CUE kube packages failed to parse the OpenAPI schema of the cluster",
+}, []string{"trigger"})
+
+func init() {
+	metrics.Registry.MustRegister(packageParseErrors)
+}
+
+// observedCRD is the generation and the served kinds of a CRD whose packages are refreshed
+type observedCRD struct {
+	generation int64
+	gvks       []metav1.GroupVersionKind
+}
+
+// Reconciler refreshes the CUE kube packages of the group versions of a CRD when it changes,
+// so that definitions can import the kinds of the CRDs installed after the controller starts.
+type Reconciler struct {
+	client.Client
+	pd     *packages.PackageDiscover
+	record event.Recorder
+
+	mu       sync.Mutex
+	observed map[string]observedCRD
+	batch    *refreshBatch
+}
+
+// refreshBatch collects the kinds of the CRDs changed in a burst to refresh their packages at once,
+// after no CRD changes for the window.
+type refreshBatch struct {
+	mu        sync.Mutex
+	window    time.Duration
+	last      time.Time
+	pending   map[string][]metav1.GroupVersionKind
+	refreshed map[string]time.Time
+}
+
+func newRefreshBatch(window time.Duration) *refreshBatch {
+	return &refreshBatch{
+		window:    window,
+		pending:   make(map[string][]metav1.GroupVersionKind),
+		refreshed: make(map[string]time.Time),
+	}
+}
+
+// add adds the kinds of the CRD to the batch. It returns how long to wait for the burst of changes to end,
+// and the names and the kinds of the CRDs in the batch to refresh once the burst ends.
+// Nothing is returned if the packages of the CRD are just refreshed with the others, the CRD is requeued
+// to the end of the burst, so it's refreshed again if it comes later than the window after the refresh.
+func (b *refreshBatch) add(name string, gvks []metav1.GroupVersionKind, now time.Time) (time.Duration, []string, []metav1.GroupVersionKind) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if refreshed, ok := b.refreshed[name]; ok {
+		delete(b.refreshed, name)
+		if now.Sub(refreshed) <= b.window {
+			return 0, nil, nil
+		}
+	}
+	if _, ok := b.pending[name]; !ok {
+		b.last = now
+	}
+	b.pending[name] = gvks
+	if wait := b.last.Add(b.window).Sub(now); wait > 0 {
+		return wait, nil, nil
+	}
+	var names []string
+	var all []metav1.GroupVersionKind
+	for n, kinds := range b.pending {
+		names = append(names, n)
+		all = append(all, kinds...)
+	}
+	b.pending = make(map[string][]metav1.GroupVersionKind)
+	return 0, names, all
+}
+
+// done marks the packages of the CRDs refreshed, except the one refreshing them.
+func (b *refreshBatch) done(names []string, by string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, name := range names {
+		if name != by {
+			b.refreshed[name] = now
+		}
+	}
+}
+
+// refresh refreshes the packages of the kinds with the ones of the other CRDs changed in the same burst.
+// It returns the time to requeue the CRD after if the burst isn't over yet.
+func (r *Reconciler) refresh(name string, gvks []metav1.GroupVersionKind) (time.Duration, error) {
+	wait, names, all := r.batch.add(name, gvks, time.Now())
+	if wait > 0 || len(names) == 0 {
+		return wait, nil
+	}
+	if err := r.pd.RefreshKubePackagesOf(all); err != nil {
+		return 0, err
+	}
+	r.batch.done(names, name, time.Now())
+	return 0, nil
+}
+
+// Reconcile is the main logic of refreshing the packages of a CRD
+func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	r.mu.Lock()
+	last, seen := r.observed[req.Name]
+	r.mu.Unlock()
+
+	crd := &crdv1.CustomResourceDefinition{}
+	if err := r.Get(ctx, req.NamespacedName, crd); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		crd = nil
+	}
+	if crd == nil || crd.DeletionTimestamp != nil {
+		if !seen {
+			return ctrl.Result{}, nil
+		}
+		wait, err := r.refresh(req.Name, last.gvks)
+		if err != nil {
+			r.forget(req.Name)
+			return r.handleRefreshError(nil, err)
+		}
+		if wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		klog.InfoS("Refreshed CUE kube packages of removed CRD", "name", req.Name)
+		for _, gvk := range last.gvks {
+			// the apiserver publishes the OpenAPI schema asynchronously
+			if r.pd.Exist(gvk) {
+				return ctrl.Result{}, fmt.Errorf("kind %s of removed CRD %s is still published", gvk.String(), req.Name)
+			}
+		}
+		r.forget(req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	gvks := servedKinds(crd)
+	if seen && last.generation == crd.Generation {
+		return ctrl.Result{}, nil
+	}
+	// the packages of the CRDs existing before the controller starts are loaded with all packages
+	if seen || !r.existAll(gvks) {
+		wait, err := r.refresh(crd.Name, append(gvks, last.gvks...))
+		if err != nil {
+			return r.handleRefreshError(crd, err)
+		}
+		if wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		klog.InfoS("Refreshed CUE kube packages of CRD", "name", crd.Name, "generation", crd.Generation)
+		if !r.existAll(gvks) {
+			return ctrl.Result{}, fmt.Errorf("the OpenAPI schema of CRD %s isn't published yet", crd.Name)
+		}
+	}
+	r.observe(crd.Name, observedCRD{generation: crd.Generation, gvks: gvks})
+	return ctrl.Result{}, nil
+}
+
+// handleRefreshError records the failure of parsing the OpenAPI schema, which won't be fixed by retrying,
+// the packages are refreshed again when CRDs change or periodically.
+func (r *Reconciler) handleRefreshError(crd *crdv1.CustomResourceDefinition, err error) (ctrl.Result, error) {
+	if !packages.IsCUEParseErr(err) {
+		return ctrl.Result{}, err
+	}
+	packageParseErrors.WithLabelValues(TriggerCRD).Inc()
+	klog.ErrorS(err, "Failed to parse the OpenAPI schema for CUE kube packages")
+	if crd != nil {
+		r.record.Event(crd, event.Warning("cannot refresh CUE kube packages", err))
+		r.observe(crd.Name, observedCRD{generation: crd.Generation, gvks: servedKinds(crd)})
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *Reconciler) existAll(gvks []metav1.GroupVersionKind) bool {
+	for _, gvk := range gvks {
+		if !r.pd.Exist(gvk) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Reconciler) observe(name string, o observedCRD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observed[name] = o
+}
+
+func (r *Reconciler) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.observed, name)
+}
+
+func servedKinds(crd *crdv1.CustomResourceDefinition) []metav1.GroupVersionKind {
+	var gvks []metav1.GroupVersionKind
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			gvks = append(gvks, metav1.GroupVersionKind{Group: crd.Spec.Group, Version: v.Name, Kind: crd.Spec.Names.Kind})
+		}
+	}
+	return gvks
+}
+
+// SetupWithManager will setup with event recorder
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.record = event.NewAPIRecorder(mgr.GetEventRecorderFor("KubePackages")).
+		WithAnnotations("controller", "KubePackages")
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("kubepackages").
+		For(&crdv1.CustomResourceDefinition{}).
+		Complete(r)
+}
+
+// periodicRefresher refreshes all packages periodically, e.g. to pick up the changes the CRD events are missed for.
+// It runs on every replica since the packages are used by webhooks too.
+type periodicRefresher struct {
+	pd       *packages.PackageDiscover
+	interval time.Duration
+}
+
+// Start refreshes the packages every interval until stop is closed
+func (p *periodicRefresher) Start(stop <-chan struct{}) error {
+	// the packages are just loaded when the controller starts
+	select {
+	case <-time.After(p.interval):
+	case <-stop:
+		return nil
+	}
+	wait.Until(func() {
+		err := p.pd.RefreshKubePackagesFromCluster()
+		if err == nil {
+			return
+		}
+		if packages.IsCUEParseErr(err) {
+			packageParseErrors.WithLabelValues(TriggerPeriodic).Inc()
+		}
+		klog.ErrorS(err, "Failed to refresh CUE kube packages")
+	}, p.interval, stop)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (p *periodicRefresher) NeedLeaderElection() bool {
+	return false
+}
+
+// Setup adds a controller refreshing the CUE kube packages when CRDs change, and refreshing all of them periodically.
+func Setup(mgr ctrl.Manager, args oamctrl.Args) error {
+	if args.PackageDiscover == nil {
+		return nil
+	}
+	r := &Reconciler{
+		Client:   mgr.GetClient(),
+		pd:       args.PackageDiscover,
+		observed: make(map[string]observedCRD),
+		batch:    newRefreshBatch(DefaultRefreshWindow),
+	}
+	if err := r.SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if args.PackageRefreshInterval <= 0 {
+		return nil
+	}
+	return mgr.Add(&periodicRefresher{pd: args.PackageDiscover, interval: args.PackageRefreshInterval})
+}
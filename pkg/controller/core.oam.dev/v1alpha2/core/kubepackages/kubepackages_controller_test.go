@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubepackages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRefreshBatch(t *testing.T) {
+	bucket := metav1.GroupVersionKind{Group: "apps.test.io", Version: "v1", Kind: "Bucket"}
+	job := metav1.GroupVersionKind{Group: "batch.test.io", Version: "v1", Kind: "Job"}
+	b := newRefreshBatch(2 * time.Second)
+	start := time.Now()
+
+	// the refresh waits for the burst of changes to end
+	wait, names, _ := b.add("buckets.apps.test.io", []metav1.GroupVersionKind{bucket}, start)
+	assert.Equal(t, 2*time.Second, wait)
+	assert.Empty(t, names)
+	wait, names, _ = b.add("jobs.batch.test.io", []metav1.GroupVersionKind{job}, start.Add(time.Second))
+	assert.Equal(t, 2*time.Second, wait)
+	assert.Empty(t, names)
+	wait, names, _ = b.add("buckets.apps.test.io", []metav1.GroupVersionKind{bucket}, start.Add(2*time.Second))
+	assert.Equal(t, time.Second, wait)
+	assert.Empty(t, names)
+
+	// the packages of all CRDs changed in the burst are refreshed at once
+	wait, names, gvks := b.add("buckets.apps.test.io", []metav1.GroupVersionKind{bucket}, start.Add(3*time.Second))
+	assert.Equal(t, time.Duration(0), wait)
+	assert.ElementsMatch(t, []string{"buckets.apps.test.io", "jobs.batch.test.io"}, names)
+	assert.ElementsMatch(t, []metav1.GroupVersionKind{bucket, job}, gvks)
+	b.done(names, "buckets.apps.test.io", start.Add(3*time.Second))
+
+	wait, names, _ = b.add("jobs.batch.test.io", []metav1.GroupVersionKind{job}, start.Add(3*time.Second))
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Empty(t, names)
+
+	// the CRD changed again later starts a new batch
+	wait, names, _ = b.add("jobs.batch.test.io", []metav1.GroupVersionKind{job}, start.Add(time.Minute))
+	assert.Equal(t, 2*time.Second, wait)
+	assert.Empty(t, names)
+}
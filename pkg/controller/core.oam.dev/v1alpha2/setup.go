@@ -22,9 +22,11 @@ import (
 	controller "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/appdeployment"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/application"
+	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/application/drift"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/applicationconfiguration"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/applicationrollout"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/core/components/componentdefinition"
+	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/core/kubepackages"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/core/policies/policydefinition"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/core/traits/traitdefinition"
 	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/core/workflow/workflowstepdefinition"
@@ -33,9 +35,12 @@ import (
 
 // Setup workload controllers.
 func Setup(mgr ctrl.Manager, args controller.Args) error {
+	if err := kubepackages.Setup(mgr, args); err != nil {
+		return err
+	}
 	if args.OAMSpecVer == "v0.3" || args.OAMSpecVer == "all" {
 		for _, setup := range []func(ctrl.Manager, controller.Args) error{
-			application.Setup, applicationrollout.Setup, appdeployment.Setup,
+			application.Setup, drift.Setup, applicationrollout.Setup, appdeployment.Setup,
 			traitdefinition.Setup, componentdefinition.Setup, policydefinition.Setup, workflowstepdefinition.Setup,
 			initializer.Setup,
 		} {
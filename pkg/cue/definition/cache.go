@@ -0,0 +1,242 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	velacue "github.com/oam-dev/kubevela/pkg/cue"
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+)
+
+// DefaultTemplateCacheSize is the number of compiled templates kept by default
+const DefaultTemplateCacheSize = 256
+
+var (
+	templateCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kubevela_template_cache_hits_total",
+		Help: "Number of renderings using a cached compiled definition template",
+	})
+	templateCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kubevela_template_cache_misses_total",
+		Help: "Number of renderings compiling the definition template",
+	})
+	templateCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kubevela_template_cache_entries",
+		Help: "Number of compiled definition templates in the cache",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(templateCacheHits, templateCacheMisses, templateCacheEntries)
+}
+
+var (
+	templateCacheMu sync.RWMutex
+	templateCache   = NewTemplateCache(DefaultTemplateCacheSize)
+)
+
+// SetTemplateCacheSize replaces the cache of compiled templates with one keeping size templates,
+// the cache is disabled if size is 0.
+func SetTemplateCacheSize(size int) {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+	templateCache = NewTemplateCache(size)
+	templateCacheEntries.Set(0)
+}
+
+func getTemplateCache() *TemplateCache {
+	templateCacheMu.RLock()
+	defer templateCacheMu.RUnlock()
+	return templateCache
+}
+
+const (
+	// maxIdleInstances is the number of compiled instances of a template kept for the renderings to come,
+	// the renderings beyond it compile instances of their own.
+	maxIdleInstances = 4
+	// maxInstanceFills is the number of renderings an instance is filled for before it's dropped,
+	// since every filling is kept by the runtime of the instance.
+	maxInstanceFills = 64
+)
+
+// compiledTemplate is a definition template compiled with the packages it imports.
+// Values derived from an instance share the index of the runtime compiling it, which is not safe
+// for concurrent use, so the compiled instances are never shared: a rendering takes an instance,
+// fills the parameter and the context into it without any lock, and puts it back after use.
+type compiledTemplate struct {
+	key              string
+	pd               *packages.PackageDiscover
+	abstractTemplate string
+	// uncached is true if the template can't be compiled alone, e.g. it refers to secrets inserted
+	// into the context, or it has processing, whose references to the processing output must not be
+	// resolved before processing. Such template is always rendered without the cache.
+	uncached bool
+
+	// mu guards idle only, it's never held across compiling or filling an instance.
+	mu   sync.Mutex
+	idle []*compiledInstance
+}
+
+type compiledInstance struct {
+	inst  *cue.Instance
+	fills int
+}
+
+// take returns an idle instance of the template, or compiles a new one if all are in use.
+// It returns nil if the template can't be compiled alone.
+func (ct *compiledTemplate) take() *compiledInstance {
+	ct.mu.Lock()
+	if n := len(ct.idle); n > 0 {
+		ci := ct.idle[n-1]
+		ct.idle = ct.idle[:n-1]
+		ct.mu.Unlock()
+		return ci
+	}
+	ct.mu.Unlock()
+	inst := compileTemplate(ct.pd, ct.abstractTemplate)
+	if inst == nil {
+		return nil
+	}
+	return &compiledInstance{inst: inst}
+}
+
+// put gives back an instance taken by a rendering done with it.
+func (ct *compiledTemplate) put(ci *compiledInstance) {
+	ci.fills++
+	if ci.fills >= maxInstanceFills {
+		return
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if len(ct.idle) < maxIdleInstances {
+		ct.idle = append(ct.idle, ci)
+	}
+}
+
+// TemplateCache is an LRU cache of compiled definition templates. The templates are keyed by their hash,
+// which changes with the revision of the definition, and by the generation of the packages they're compiled with.
+type TemplateCache struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+// NewTemplateCache creates a cache keeping size compiled templates.
+func NewTemplateCache(size int) *TemplateCache {
+	return &TemplateCache{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// HitRate returns the rate of lookups finding a compiled template.
+func (c *TemplateCache) HitRate() float64 {
+	hits, misses := atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// Len returns the number of compiled templates in the cache.
+func (c *TemplateCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// get returns the compiled template, the template is compiled on a miss.
+// It returns nil if the cache is disabled.
+func (c *TemplateCache) get(pd *packages.PackageDiscover, abstractTemplate string) *compiledTemplate {
+	if c.size <= 0 {
+		return nil
+	}
+	key := templateKey(pd, abstractTemplate)
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(e)
+		c.mu.Unlock()
+		atomic.AddUint64(&c.hits, 1)
+		templateCacheHits.Inc()
+		return e.Value.(*compiledTemplate)
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(&c.misses, 1)
+	templateCacheMisses.Inc()
+
+	// compile out of the lock, a template compiled concurrently by others is replaced by the last one
+	ct := &compiledTemplate{key: key, pd: pd, abstractTemplate: abstractTemplate}
+	if inst := compileTemplate(pd, abstractTemplate); inst == nil || inst.Lookup("processing").Exists() {
+		ct.uncached = true
+	} else {
+		ct.idle = append(ct.idle, &compiledInstance{inst: inst})
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.ll.Remove(e)
+	}
+	c.entries[key] = c.ll.PushFront(ct)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*compiledTemplate).key)
+	}
+	templateCacheEntries.Set(float64(c.ll.Len()))
+	return ct
+}
+
+func templateKey(pd *packages.PackageDiscover, abstractTemplate string) string {
+	sum := sha256.Sum256([]byte(abstractTemplate))
+	return fmt.Sprintf("%d/%s", pd.Generation(), hex.EncodeToString(sum[:]))
+}
+
+// compileTemplate compiles the template with the top-level fields filled per rendering declared,
+// so the references to them are resolved after filling.
+func compileTemplate(pd *packages.PackageDiscover, abstractTemplate string) *cue.Instance {
+	bi := build.NewContext().NewInstance("", nil)
+	if err := bi.AddFile("-", abstractTemplate); err != nil {
+		return nil
+	}
+	if err := bi.AddFile("parameter", velacue.ParameterTag+": _"); err != nil {
+		return nil
+	}
+	if err := bi.AddFile("context", "context: _"); err != nil {
+		return nil
+	}
+	inst, err := pd.ImportPackagesAndBuildInstance(bi)
+	if err != nil {
+		return nil
+	}
+	return inst
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+	"github.com/oam-dev/kubevela/pkg/cue/process"
+)
+
+const cacheTestWorkloadTemplate = `
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: name: context.name
+	spec: {
+		replicas: parameter.replicas
+		template: spec: containers: [{
+			name:  context.name
+			image: parameter.image
+		}]
+	}
+}
+parameter: {
+	replicas: *1 | int
+	image:    string
+}
+`
+
+const cacheTestTraitTemplate = `
+patch: metadata: annotations: "replicas": "\(context.output.spec.replicas)"
+outputs: service: {
+	apiVersion: "v1"
+	kind:       "Service"
+	metadata: name: context.name
+	spec: ports: [{port: parameter.port}]
+}
+parameter: port: int
+`
+
+func renderForCacheTest(t testing.TB, pd *packages.PackageDiscover, name string, replicas int) map[string]interface{} {
+	result, err := renderWithCache(pd, name, replicas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func renderWithCache(pd *packages.PackageDiscover, name string, replicas int) (map[string]interface{}, error) {
+	ctx := process.NewContext("default", name, "myapp", "myapp-v1")
+	wd := NewWorkloadAbstractEngine(name, pd)
+	if err := wd.Complete(ctx, cacheTestWorkloadTemplate, map[string]interface{}{"replicas": replicas, "image": "nginx"}); err != nil {
+		return nil, err
+	}
+	td := NewTraitAbstractEngine("expose", pd)
+	if err := td.Complete(ctx, cacheTestTraitTemplate, map[string]interface{}{"port": 80}); err != nil {
+		return nil, err
+	}
+	base, auxiliaries := ctx.Output()
+	obj, err := base.Unstructured()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"workload": obj.Object}
+	for _, aux := range auxiliaries {
+		o, err := aux.Ins.Unstructured()
+		if err != nil {
+			return nil, err
+		}
+		result[aux.Name] = o.Object
+	}
+	return result, nil
+}
+
+func TestTemplateCache(t *testing.T) {
+	defer SetTemplateCacheSize(DefaultTemplateCacheSize)
+	pd := &packages.PackageDiscover{}
+
+	SetTemplateCacheSize(0)
+	expected := renderForCacheTest(t, pd, "web", 3)
+	assert.Equal(t, 0, getTemplateCache().Len())
+
+	SetTemplateCacheSize(DefaultTemplateCacheSize)
+	// the parameter and the context of each rendering don't leak into the others
+	assert.Equal(t, expected, renderForCacheTest(t, pd, "web", 3))
+	other := renderForCacheTest(t, pd, "api", 5)
+	assert.Equal(t, expected, renderForCacheTest(t, pd, "web", 3))
+	assert.Equal(t, int64(5), other["workload"].(map[string]interface{})["spec"].(map[string]interface{})["replicas"])
+	assert.Equal(t, "5", other["workload"].(map[string]interface{})["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})["replicas"])
+	assert.Equal(t, 2, getTemplateCache().Len())
+	assert.Equal(t, 4.0/6.0, getTemplateCache().HitRate())
+
+	// a template which can't be compiled alone is rendered without the cache
+	ctx := process.NewContext("default", "web", "myapp", "myapp-v1")
+	err := NewWorkloadAbstractEngine("web", pd).Complete(ctx, `output: metadata: name: none`, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "none")
+}
+
+func TestTemplateCacheConcurrentRender(t *testing.T) {
+	defer SetTemplateCacheSize(DefaultTemplateCacheSize)
+	pd := &packages.PackageDiscover{}
+
+	SetTemplateCacheSize(0)
+	expected := make([]map[string]interface{}, 10)
+	for i := range expected {
+		expected[i] = renderForCacheTest(t, pd, fmt.Sprintf("comp-%d", i), i)
+	}
+
+	SetTemplateCacheSize(DefaultTemplateCacheSize)
+	var wg sync.WaitGroup
+	results := make([][]map[string]interface{}, 8)
+	for g := range results {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := range expected {
+				result, err := renderWithCache(pd, fmt.Sprintf("comp-%d", i), i)
+				assert.NoError(t, err)
+				results[g] = append(results[g], result)
+			}
+		}(g)
+	}
+	wg.Wait()
+	for _, result := range results {
+		assert.Equal(t, expected, result)
+	}
+
+	// the instances are put back for the renderings to come, no more than the idle ones kept
+	ct := getTemplateCache().get(pd, cacheTestWorkloadTemplate)
+	assert.False(t, ct.uncached)
+	assert.True(t, len(ct.idle) > 0 && len(ct.idle) <= maxIdleInstances)
+
+	// a template with processing is merged with the files, its processing output isn't resolved before processing
+	ct = getTemplateCache().get(pd, `
+processing: output: replicas: *1 | int
+output: spec: replicas: processing.output.replicas
+`)
+	assert.True(t, ct.uncached)
+}
+
+func TestTemplateCacheEviction(t *testing.T) {
+	pd := &packages.PackageDiscover{}
+	c := NewTemplateCache(2)
+	for i := 0; i < 3; i++ {
+		ct := c.get(pd, fmt.Sprintf("a: %d", i))
+		assert.False(t, ct.uncached)
+	}
+	assert.Equal(t, 2, c.Len())
+	c.get(pd, "a: 2")
+	c.get(pd, "a: 1")
+	assert.Equal(t, 2.0/5.0, c.HitRate())
+	c.get(pd, "a: 0")
+	assert.Equal(t, 2.0/6.0, c.HitRate())
+
+	assert.Nil(t, NewTemplateCache(0).get(pd, "a: 0"))
+}
+
+func benchmarkRender(b *testing.B, cacheSize int) {
+	defer SetTemplateCacheSize(DefaultTemplateCacheSize)
+	SetTemplateCacheSize(cacheSize)
+	pd := &packages.PackageDiscover{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		renderForCacheTest(b, pd, fmt.Sprintf("comp-%d", i%10), i%5)
+	}
+	b.ReportMetric(getTemplateCache().HitRate(), "hit-rate")
+}
+
+func BenchmarkRenderWithTemplateCache(b *testing.B) {
+	benchmarkRender(b, DefaultTemplateCacheSize)
+}
+
+func BenchmarkRenderWithoutTemplateCache(b *testing.B) {
+	benchmarkRender(b, 0)
+}
+
+func BenchmarkRenderWithTemplateCacheParallel(b *testing.B) {
+	defer SetTemplateCacheSize(DefaultTemplateCacheSize)
+	SetTemplateCacheSize(DefaultTemplateCacheSize)
+	pd := &packages.PackageDiscover{}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := renderWithCache(pd, fmt.Sprintf("comp-%d", i%10), i%5); err != nil {
+				b.Error(err)
+			}
+			i++
+		}
+	})
+	b.ReportMetric(getTemplateCache().HitRate(), "hit-rate")
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"fmt"
+	"strings"
+
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TemplateFile is the file name of the template of the definition in render errors
+	TemplateFile = "template"
+	// ParameterFile is the file name of the parameter in render errors
+	ParameterFile = "parameter"
+	// ContextFile is the file name of the context in render errors
+	ContextFile = "context"
+)
+
+// Position is a location in the template, the parameter or the context
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String returns the position as file:line:column
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// RenderIssue is one of the CUE errors in rendering, e.g. the conflicting values of a field
+type RenderIssue struct {
+	// Path is the path of the field, e.g. output.spec.replicas
+	Path string
+	// Message describes the error, e.g. the conflicting values
+	Message string
+	// Positions are the locations of the values causing the error
+	Positions []Position
+}
+
+// String returns the issue as `path: message (file:line:column, ...)`
+func (i RenderIssue) String() string {
+	s := i.Message
+	if i.Path != "" {
+		s = i.Path + ": " + s
+	}
+	if len(i.Positions) == 0 {
+		return s
+	}
+	positions := make([]string, len(i.Positions))
+	for k, p := range i.Positions {
+		positions[k] = p.String()
+	}
+	return fmt.Sprintf("%s (%s)", s, strings.Join(positions, ", "))
+}
+
+// RenderError is an error of rendering the template of a definition with the CUE positions kept,
+// so that users can tell which line of the template, the parameter or the context causes it.
+type RenderError struct {
+	// Kind is the kind of the rendered template, e.g. workload, trait
+	Kind string
+	// Name is the name of the rendered template, e.g. the component or the trait type
+	Name string
+	// Definition and Revision are the definition of the template and its revision if they're known
+	Definition string
+	Revision   string
+	// Reason is the stage of rendering failed
+	Reason string
+	// Issues are the CUE errors, they're empty if the cause isn't a CUE error
+	Issues []RenderIssue
+
+	cause error
+}
+
+// Error implements error
+func (e *RenderError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s of %s %s", e.Reason, e.Kind, e.Name)
+	if e.Definition != "" {
+		fmt.Fprintf(&b, " (definition %s", e.Definition)
+		if e.Revision != "" {
+			fmt.Fprintf(&b, ", revision %s", e.Revision)
+		}
+		b.WriteString(")")
+	}
+	if len(e.Issues) == 0 {
+		b.WriteString(": " + e.cause.Error())
+		return b.String()
+	}
+	for i, issue := range e.Issues {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(issue.String())
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause of the error
+func (e *RenderError) Unwrap() error {
+	return e.cause
+}
+
+// Cause returns the cause of the error for github.com/pkg/errors
+func (e *RenderError) Cause() error {
+	return e.cause
+}
+
+// newRenderError creates a RenderError caused by err, the positions of CUE errors are kept in its issues.
+func newRenderError(kind, name, reason string, err error) error {
+	re := &RenderError{Kind: kind, Name: name, Reason: reason, cause: err}
+	var cueErr cueerrors.Error
+	if !errors.As(err, &cueErr) {
+		return re
+	}
+	for _, e := range cueerrors.Errors(cueErr) {
+		format, args := e.Msg()
+		issue := RenderIssue{
+			Path:    strings.Join(e.Path(), "."),
+			Message: fmt.Sprintf(format, args...),
+		}
+		for _, pos := range cueerrors.Positions(e) {
+			if p, ok := newPosition(pos); ok {
+				issue.Positions = append(issue.Positions, p)
+			}
+		}
+		re.Issues = append(re.Issues, issue)
+	}
+	return re
+}
+
+func newPosition(pos token.Pos) (Position, bool) {
+	if !pos.IsValid() {
+		return Position{}, false
+	}
+	file := pos.Filename()
+	if file == "-" || file == "" {
+		file = TemplateFile
+	}
+	return Position{File: file, Line: pos.Line(), Column: pos.Column()}, true
+}
+
+// WithDefinition sets the definition and its revision of the RenderError in err, err is returned as is.
+func WithDefinition(err error, definition, revision string) error {
+	if err == nil {
+		return nil
+	}
+	var re *RenderError
+	if errors.As(err, &re) {
+		re.Definition = definition
+		re.Revision = revision
+	}
+	return err
+}
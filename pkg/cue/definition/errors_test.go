@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+	"github.com/oam-dev/kubevela/pkg/cue/process"
+)
+
+func TestRenderError(t *testing.T) {
+	template := `
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	spec: replicas: parameter.replicas
+}
+parameter: {
+	replicas: int
+}
+`
+	pd := &packages.PackageDiscover{}
+	for _, cacheSize := range []int{0, DefaultTemplateCacheSize} {
+		SetTemplateCacheSize(cacheSize)
+		ctx := process.NewContext("default", "web", "myapp", "myapp-v1")
+		err := NewWorkloadAbstractEngine("web", pd).Complete(ctx, template, map[string]interface{}{"replicas": "3"})
+		err = WithDefinition(errors.WithMessage(err, "render"), "worker", "worker-v2")
+		var re *RenderError
+		if !assert.True(t, errors.As(err, &re)) {
+			continue
+		}
+		assert.Equal(t, "workload", re.Kind)
+		assert.Equal(t, "web", re.Name)
+		assert.Equal(t, "worker", re.Definition)
+		assert.Equal(t, "worker-v2", re.Revision)
+		assert.NotEmpty(t, re.Issues)
+		files := map[string]bool{}
+		for _, issue := range re.Issues {
+			assert.Contains(t, issue.Message, "conflicting values")
+			for _, pos := range issue.Positions {
+				files[pos.File] = true
+				assert.True(t, pos.Line > 0)
+			}
+		}
+		// the conflicting values are located in both the template and the parameter
+		assert.True(t, files[TemplateFile])
+		assert.True(t, files[ParameterFile])
+		assert.Contains(t, err.Error(), "invalid cue template after merging the parameter and the context of workload web (definition worker, revision worker-v2): ")
+		assert.Contains(t, err.Error(), "template:8:")
+	}
+	SetTemplateCacheSize(DefaultTemplateCacheSize)
+
+	// the errors of the template itself are located in it
+	ctx := process.NewContext("default", "web", "myapp", "myapp-v1")
+	err := NewTraitAbstractEngine("expose", pd).Complete(ctx, "outputs: service: {\n\tkind: \"Service\"\n\tspec: ports: [{port: }]\n}", nil)
+	var re *RenderError
+	if assert.True(t, errors.As(err, &re)) {
+		assert.Equal(t, "trait", re.Kind)
+		assert.Equal(t, "invalid cue template", re.Reason)
+		assert.Contains(t, err.Error(), "template:3:")
+	}
+
+	assert.Nil(t, WithDefinition(nil, "worker", ""))
+}
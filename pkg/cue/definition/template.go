@@ -18,13 +18,13 @@ package definition
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/build"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	velacue "github.com/oam-dev/kubevela/pkg/cue"
@@ -43,10 +43,14 @@ const (
 	OutputsFieldName = process.OutputsFieldName
 	// PatchFieldName is the name of the struct contains the patch of CR data
 	PatchFieldName = "patch"
+	// PatchOpsFieldName is the name of the list contains the JSON-patch style operations of CR data
+	PatchOpsFieldName = "patchOps"
 	// CustomMessage defines the custom message in definition template
 	CustomMessage = "message"
 	// HealthCheckPolicy defines the health check policy in definition template
 	HealthCheckPolicy = "isHealth"
+	// OutputValue is the field holding the value of an output expression of a component when it's evaluated
+	OutputValue = "value"
 )
 
 const (
@@ -60,6 +64,7 @@ type AbstractEngine interface {
 	Complete(ctx process.Context, abstractTemplate string, params interface{}) error
 	HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (bool, error)
 	Status(ctx process.Context, cli client.Client, ns string, customStatusTemplate string, parameter interface{}) (string, error)
+	GetTemplateContext(ctx process.Context, cli client.Reader, ns string) (map[string]interface{}, error)
 }
 
 type def struct {
@@ -67,56 +72,150 @@ type def struct {
 	pd   *packages.PackageDiscover
 }
 
-type workloadDef struct {
-	def
-}
-
-// NewWorkloadAbstractEngine create Workload Definition AbstractEngine
-func NewWorkloadAbstractEngine(name string, pd *packages.PackageDiscover) AbstractEngine {
-	return &workloadDef{
-		def: def{
-			name: name,
-			pd:   pd,
-		},
+// render merges the template of the definition with the parameter and the context, and calls fn with the merged instance.
+// The errors of merging are RenderErrors locating the causes in the template, the parameter or the context.
+func (d *def) render(ctx process.Context, kind, abstractTemplate string, params interface{}, fn func(inst *cue.Instance) error) error {
+	contextFile := ctx.ExtendedContextFile()
+	if ct := getTemplateCache().get(d.pd, abstractTemplate); ct != nil && !ct.uncached {
+		if ci := ct.take(); ci != nil {
+			defer ct.put(ci)
+			if inst, ok := fillCompiled(ci.inst, params, contextFile); ok {
+				return fn(inst)
+			}
+		}
 	}
-}
 
-// Complete do workload definition's rendering
-func (wd *workloadDef) Complete(ctx process.Context, abstractTemplate string, params interface{}) error {
 	bi := build.NewContext().NewInstance("", nil)
 	if err := bi.AddFile("-", abstractTemplate); err != nil {
-		return errors.WithMessagef(err, "invalid cue template of workload %s", wd.name)
+		return newRenderError(kind, d.name, "invalid cue template", err)
 	}
 	var paramFile = "parameter: {}"
 	if params != nil {
 		bt, err := json.Marshal(params)
 		if err != nil {
-			return errors.WithMessagef(err, "marshal parameter of workload %s", wd.name)
+			return errors.WithMessagef(err, "marshal parameter of %s %s", kind, d.name)
 		}
 		if string(bt) != "null" {
 			paramFile = fmt.Sprintf("%s: %s", velacue.ParameterTag, string(bt))
 		}
 	}
-	if err := bi.AddFile("parameter", paramFile); err != nil {
-		return errors.WithMessagef(err, "invalid parameter of workload %s", wd.name)
+	if err := bi.AddFile(ParameterFile, paramFile); err != nil {
+		return newRenderError(kind, d.name, "invalid parameter", err)
+	}
+	if err := bi.AddFile(ContextFile, contextFile); err != nil {
+		return newRenderError(kind, d.name, "invalid context", err)
+	}
+	inst, err := d.pd.ImportPackagesAndBuildInstance(bi)
+	if err == nil {
+		err = inst.Value().Validate()
+	}
+	if err != nil {
+		return newRenderError(kind, d.name, "invalid cue template after merging the parameter and the context", err)
 	}
+	return fn(inst)
+}
 
-	if err := bi.AddFile("-", ctx.ExtendedContextFile()); err != nil {
-		return err
+// fillCompiled fills the parameter and the context into the compiled template, the filled instance shares
+// the runtime of the compiled one, so it's only used by the rendering taking the compiled instance.
+// It returns false if the template should be merged with the files of the parameter and the context instead,
+// e.g. the filled template is invalid, since the filled values have no positions to locate the error.
+func fillCompiled(compiled *cue.Instance, params interface{}, contextFile string) (*cue.Instance, bool) {
+	paramValue, ok := decodeParameter(params)
+	if !ok {
+		return nil, false
+	}
+	contextValue, ok := decodeContext(contextFile)
+	if !ok {
+		return nil, false
+	}
+	inst, err := compiled.Fill(paramValue, velacue.ParameterTag)
+	if err == nil {
+		inst, err = inst.Fill(contextValue, "context")
 	}
+	if err == nil {
+		err = inst.Value().Validate()
+	}
+	if err != nil {
+		return nil, false
+	}
+	return inst, true
+}
 
-	inst, err := wd.pd.ImportPackagesAndBuildInstance(bi)
+// decodeParameter decodes the parameter into a value filled into a compiled template,
+// the numbers are decoded as in CUE files.
+func decodeParameter(params interface{}) (interface{}, bool) {
+	var paramValue interface{} = map[string]interface{}{}
+	if params == nil {
+		return paramValue, true
+	}
+	bt, err := json.Marshal(params)
 	if err != nil {
-		return err
+		return nil, false
 	}
+	if string(bt) != "null" {
+		if err := json.Unmarshal(bt, &paramValue); err != nil {
+			return nil, false
+		}
+	}
+	return paramValue, true
+}
 
-	if err := inst.Value().Validate(); err != nil {
-		return errors.WithMessagef(err, "invalid cue template of workload %s after merge parameter and context", wd.name)
+// decodeContext decodes the context into a value filled into a compiled template. It returns false if the
+// context can't be decoded, e.g. it has secrets at the top level or its output isn't concrete, then the
+// template is compiled with the context file.
+func decodeContext(contextFile string) (interface{}, bool) {
+	var r cue.Runtime
+	contextInst, err := r.Compile("context", contextFile)
+	if err != nil {
+		return nil, false
+	}
+	st, err := contextInst.Value().Struct()
+	if err != nil || st.Len() != 1 {
+		return nil, false
+	}
+	bt, err := contextInst.Lookup("context").MarshalJSON()
+	if err != nil {
+		return nil, false
+	}
+	var contextValue interface{}
+	if err := json.Unmarshal(bt, &contextValue); err != nil {
+		return nil, false
+	}
+	return contextValue, true
+}
+
+type workloadDef struct {
+	def
+}
+
+// NewWorkloadAbstractEngine create Workload Definition AbstractEngine
+func NewWorkloadAbstractEngine(name string, pd *packages.PackageDiscover) AbstractEngine {
+	return &workloadDef{
+		def: def{
+			name: name,
+			pd:   pd,
+		},
+	}
+}
+
+// Complete do workload definition's rendering
+func (wd *workloadDef) Complete(ctx process.Context, abstractTemplate string, params interface{}) error {
+	return wd.render(ctx, "workload", abstractTemplate, params, func(inst *cue.Instance) error {
+		return wd.complete(ctx, inst)
+	})
+}
+
+func (wd *workloadDef) complete(ctx process.Context, inst *cue.Instance) error {
+	if processing := inst.Lookup("processing"); processing.Exists() {
+		var err error
+		if inst, err = task.Process(inst); err != nil {
+			return errors.WithMessagef(err, "invalid process of workload %s", wd.name)
+		}
 	}
 	output := inst.Lookup(OutputFieldName)
 	base, err := model.NewBase(output)
 	if err != nil {
-		return errors.WithMessagef(err, "invalid output of workload %s", wd.name)
+		return newRenderError("workload", wd.name, "invalid output", err)
 	}
 	if err := ctx.SetBase(base); err != nil {
 		return err
@@ -129,7 +228,7 @@ func (wd *workloadDef) Complete(ctx process.Context, abstractTemplate string, pa
 	}
 	st, err := outputs.Struct()
 	if err != nil {
-		return errors.WithMessagef(err, "invalid outputs of workload %s", wd.name)
+		return newRenderError("workload", wd.name, "invalid outputs", err)
 	}
 	for i := 0; i < st.Len(); i++ {
 		fieldInfo := st.Field(i)
@@ -138,7 +237,7 @@ func (wd *workloadDef) Complete(ctx process.Context, abstractTemplate string, pa
 		}
 		other, err := model.NewOther(fieldInfo.Value)
 		if err != nil {
-			return errors.WithMessagef(err, "invalid outputs(%s) of workload %s", fieldInfo.Name, wd.name)
+			return newRenderError("workload", wd.name, fmt.Sprintf("invalid outputs(%s)", fieldInfo.Name), err)
 		}
 		if err := ctx.AppendAuxiliaries(process.Auxiliary{Ins: other, Type: AuxiliaryWorkload, Name: fieldInfo.Name}); err != nil {
 			return err
@@ -147,7 +246,9 @@ func (wd *workloadDef) Complete(ctx process.Context, abstractTemplate string, pa
 	return nil
 }
 
-func (wd *workloadDef) getTemplateContext(ctx process.Context, cli client.Reader, ns string) (map[string]interface{}, error) {
+// GetTemplateContext returns the context of the health policy and the custom status of the workload,
+// with the resources rendered by the workload got from the cluster in `output` and `outputs`.
+func (wd *workloadDef) GetTemplateContext(ctx process.Context, cli client.Reader, ns string) (map[string]interface{}, error) {
 
 	var root = initRoot(ctx.BaseContextLabels())
 	var commonLabels = GetCommonLabels(ctx.BaseContextLabels())
@@ -197,7 +298,7 @@ func (wd *workloadDef) HealthCheck(ctx process.Context, cli client.Client, ns st
 	if healthPolicyTemplate == "" {
 		return true, nil
 	}
-	templateContext, err := wd.getTemplateContext(ctx, cli, ns)
+	templateContext, err := wd.GetTemplateContext(ctx, cli, ns)
 	if err != nil {
 		return false, errors.WithMessage(err, "get template context")
 	}
@@ -228,7 +329,7 @@ func (wd *workloadDef) Status(ctx process.Context, cli client.Client, ns string,
 	if customStatusTemplate == "" {
 		return "", nil
 	}
-	templateContext, err := wd.getTemplateContext(ctx, cli, ns)
+	templateContext, err := wd.GetTemplateContext(ctx, cli, ns)
 	if err != nil {
 		return "", errors.WithMessage(err, "get template context")
 	}
@@ -266,6 +367,31 @@ func getStatusMessage(templateContext map[string]interface{}, customStatusTempla
 	return message, nil
 }
 
+// EvalOutput evaluates the CUE expression of a component output with the template context.
+// It fails if the value isn't concrete, e.g. the status field it refers to isn't reported yet.
+func EvalOutput(templateContext map[string]interface{}, valueFrom string) (interface{}, error) {
+	bt, err := json.Marshal(templateContext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "json marshal template context")
+	}
+
+	var buff = "context: " + string(bt) + "\n" + OutputValue + ": " + valueFrom + "\n"
+	var r cue.Runtime
+	inst, err := r.Compile("-", buff)
+	if err != nil {
+		return nil, errors.WithMessage(err, "compile output expression")
+	}
+	b, err := inst.Lookup(OutputValue).MarshalJSON()
+	if err != nil {
+		return nil, errors.WithMessage(err, "evaluate output expression")
+	}
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
 type traitDef struct {
 	def
 }
@@ -282,37 +408,15 @@ func NewTraitAbstractEngine(name string, pd *packages.PackageDiscover) AbstractE
 
 // Complete do trait definition's rendering
 func (td *traitDef) Complete(ctx process.Context, abstractTemplate string, params interface{}) error {
-	bi := build.NewContext().NewInstance("", nil)
-	if err := bi.AddFile("-", abstractTemplate); err != nil {
-		return errors.WithMessagef(err, "invalid template of trait %s", td.name)
-	}
-	var paramFile = "parameter: {}"
-	if params != nil {
-		bt, err := json.Marshal(params)
-		if err != nil {
-			return errors.WithMessagef(err, "marshal parameter of trait %s", td.name)
-		}
-		if string(bt) != "null" {
-			paramFile = fmt.Sprintf("%s: %s", velacue.ParameterTag, string(bt))
-		}
-	}
-	if err := bi.AddFile("parameter", paramFile); err != nil {
-		return errors.WithMessagef(err, "invalid parameter of trait %s", td.name)
-	}
-	if err := bi.AddFile("context", ctx.ExtendedContextFile()); err != nil {
-		return errors.WithMessagef(err, "invalid context of trait %s", td.name)
-	}
-
-	inst, err := td.pd.ImportPackagesAndBuildInstance(bi)
-	if err != nil {
-		return err
-	}
+	return td.render(ctx, "trait", abstractTemplate, params, func(inst *cue.Instance) error {
+		return td.complete(ctx, inst)
+	})
+}
 
-	if err := inst.Value().Validate(); err != nil {
-		return errors.WithMessagef(err, "invalid template of trait %s after merge with parameter and context", td.name)
-	}
+func (td *traitDef) complete(ctx process.Context, inst *cue.Instance) error {
 	processing := inst.Lookup("processing")
 	if processing.Exists() {
+		var err error
 		if inst, err = task.Process(inst); err != nil {
 			return errors.WithMessagef(err, "invalid process of trait %s", td.name)
 		}
@@ -321,7 +425,7 @@ func (td *traitDef) Complete(ctx process.Context, abstractTemplate string, param
 	if outputs.Exists() {
 		st, err := outputs.Struct()
 		if err != nil {
-			return errors.WithMessagef(err, "invalid outputs of trait %s", td.name)
+			return newRenderError("trait", td.name, "invalid outputs", err)
 		}
 		for i := 0; i < st.Len(); i++ {
 			fieldInfo := st.Field(i)
@@ -330,7 +434,7 @@ func (td *traitDef) Complete(ctx process.Context, abstractTemplate string, param
 			}
 			other, err := model.NewOther(fieldInfo.Value)
 			if err != nil {
-				return errors.WithMessagef(err, "invalid outputs(resource=%s) of trait %s", fieldInfo.Name, td.name)
+				return newRenderError("trait", td.name, fmt.Sprintf("invalid outputs(resource=%s)", fieldInfo.Name), err)
 			}
 			if err := ctx.AppendAuxiliaries(process.Auxiliary{Ins: other, Type: td.name, Name: fieldInfo.Name}); err != nil {
 				return err
@@ -343,10 +447,10 @@ func (td *traitDef) Complete(ctx process.Context, abstractTemplate string, param
 		base, auxiliaries := ctx.Output()
 		p, err := model.NewOther(patcher)
 		if err != nil {
-			return errors.WithMessagef(err, "invalid patch of trait %s", td.name)
+			return newRenderError("trait", td.name, "invalid patch", err)
 		}
 		if err := base.Unify(p); err != nil {
-			return errors.WithMessagef(err, "invalid patch trait %s into workload", td.name)
+			return newRenderError("trait", td.name, "invalid patch into workload", err)
 		}
 
 		for _, auxiliary := range auxiliaries {
@@ -363,9 +467,111 @@ func (td *traitDef) Complete(ctx process.Context, abstractTemplate string, param
 		}
 	}
 
+	return td.applyPatchOps(ctx, inst.Lookup(PatchOpsFieldName))
+}
+
+// applyPatchOps applies the JSON-patch style operations in order, an operation is applied to the workload,
+// or to the auxiliary output named by its `output`.
+func (td *traitDef) applyPatchOps(ctx process.Context, patchOps cue.Value) error {
+	if !patchOps.Exists() {
+		return nil
+	}
+	iter, err := patchOps.List()
+	if err != nil {
+		return newRenderError("trait", td.name, "invalid patchOps", err)
+	}
+	base, auxiliaries := ctx.Output()
+	for i := 0; iter.Next(); i++ {
+		reason := fmt.Sprintf("invalid patchOps[%d]", i)
+		v := iter.Value()
+		op, err := v.Lookup("op").String()
+		if err != nil {
+			return newRenderError("trait", td.name, reason, err)
+		}
+		path, err := v.Lookup("path").String()
+		if err != nil {
+			return newRenderError("trait", td.name, reason, err)
+		}
+		target := base
+		if output := v.Lookup("output"); output.Exists() {
+			name, err := output.String()
+			if err != nil {
+				return newRenderError("trait", td.name, reason, err)
+			}
+			target = nil
+			for _, auxiliary := range auxiliaries {
+				if auxiliary.Name == name {
+					target = auxiliary.Ins
+				}
+			}
+			if target == nil {
+				return newRenderError("trait", td.name, reason, errors.Errorf("output %s not found", name))
+			}
+		}
+		patchOp, err := model.NewPatchOp(op, path, v.Lookup("value"))
+		if err != nil {
+			return newRenderError("trait", td.name, reason, err)
+		}
+		if err := target.ApplyPatchOps(patchOp); err != nil {
+			return newRenderError("trait", td.name, reason, err)
+		}
+	}
 	return nil
 }
 
+// WorkflowStepEngine defines the render interface of WorkflowStepDefinition
+type WorkflowStepEngine interface {
+	Evaluate(ctx process.Context, abstractTemplate string, params interface{}) (*cue.Instance, error)
+}
+
+type workflowStepDef struct {
+	def
+}
+
+// NewWorkflowStepEngine create WorkflowStep Definition engine
+func NewWorkflowStepEngine(name string, pd *packages.PackageDiscover) WorkflowStepEngine {
+	return &workflowStepDef{
+		def: def{
+			name: name,
+			pd:   pd,
+		},
+	}
+}
+
+// Evaluate merges the template of workflow step with its parameter and context, the returned instance
+// is executed by the workflow engine, so fields referring to the results of actions can be incomplete.
+func (sd *workflowStepDef) Evaluate(ctx process.Context, abstractTemplate string, params interface{}) (*cue.Instance, error) {
+	bi := build.NewContext().NewInstance("", nil)
+	if err := bi.AddFile("-", abstractTemplate); err != nil {
+		return nil, newRenderError("workflow step", sd.name, "invalid cue template", err)
+	}
+	var paramFile = "parameter: {}"
+	if params != nil {
+		bt, err := json.Marshal(params)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "marshal parameter of workflow step %s", sd.name)
+		}
+		if string(bt) != "null" {
+			paramFile = fmt.Sprintf("%s: %s", velacue.ParameterTag, string(bt))
+		}
+	}
+	if err := bi.AddFile(ParameterFile, paramFile); err != nil {
+		return nil, newRenderError("workflow step", sd.name, "invalid parameter", err)
+	}
+	if err := bi.AddFile(ContextFile, ctx.ExtendedContextFile()); err != nil {
+		return nil, newRenderError("workflow step", sd.name, "invalid context", err)
+	}
+
+	inst, err := sd.pd.ImportPackagesAndBuildInstance(bi)
+	if err == nil {
+		err = inst.Value().Validate()
+	}
+	if err != nil {
+		return nil, newRenderError("workflow step", sd.name, "invalid cue template after merging the parameter and the context", err)
+	}
+	return inst, nil
+}
+
 // GetCommonLabels will convert context based labels to OAM standard labels
 func GetCommonLabels(contextLabels map[string]string) map[string]string {
 	var commonLabels = map[string]string{}
@@ -390,7 +596,9 @@ func initRoot(contextLabels map[string]string) map[string]interface{} {
 	return root
 }
 
-func (td *traitDef) getTemplateContext(ctx process.Context, cli client.Reader, ns string) (map[string]interface{}, error) {
+// GetTemplateContext returns the context of the health policy and the custom status of the trait,
+// with the resources rendered by the trait got from the cluster in `outputs`.
+func (td *traitDef) GetTemplateContext(ctx process.Context, cli client.Reader, ns string) (map[string]interface{}, error) {
 	var root = initRoot(ctx.BaseContextLabels())
 	var commonLabels = GetCommonLabels(ctx.BaseContextLabels())
 
@@ -423,7 +631,7 @@ func (td *traitDef) Status(ctx process.Context, cli client.Client, ns string, cu
 	if customStatusTemplate == "" {
 		return "", nil
 	}
-	templateContext, err := td.getTemplateContext(ctx, cli, ns)
+	templateContext, err := td.GetTemplateContext(ctx, cli, ns)
 	if err != nil {
 		return "", errors.WithMessage(err, "get template context")
 	}
@@ -435,7 +643,7 @@ func (td *traitDef) HealthCheck(ctx process.Context, cli client.Client, ns strin
 	if healthPolicyTemplate == "" {
 		return true, nil
 	}
-	templateContext, err := td.getTemplateContext(ctx, cli, ns)
+	templateContext, err := td.GetTemplateContext(ctx, cli, ns)
 	if err != nil {
 		return false, errors.WithMessage(err, "get template context")
 	}
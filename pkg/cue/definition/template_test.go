@@ -808,6 +808,66 @@ parameter: [string]: string`,
 			},
 		},
 
+		"patch trait with replace, delete and patch ops": {
+			traitTemplate: `
+patch: spec: {
+	// +patchStrategy=replace
+	replicas: parameter.replicas
+	template: spec: {
+		// +patchKey=name
+		containers: [{
+			// +patchStrategy=delete
+			name: "main"
+		}, {
+			name:  "sidecar"
+			image: "metrics-agent:0.2"
+		}]
+	}
+}
+patchOps: [{
+	op:    "add"
+	path:  "/metadata"
+	value: labels: "app.oam.dev/name": context.appName
+}, {
+	op:     "remove"
+	path:   "/data/lives"
+	output: "gameconfig"
+}]
+
+parameter: replicas: int`,
+			params: map[string]interface{}{
+				"replicas": 3,
+			},
+			expWorkload: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"metadata": map[string]interface{}{
+						"labels": map[string]interface{}{"app.oam.dev/name": "myapp"},
+					},
+					"spec": map[string]interface{}{
+						"replicas": int64(3),
+						"selector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{
+								"app.oam.dev/component": "test"}},
+						"template": map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"labels": map[string]interface{}{"app.oam.dev/component": "test"},
+							},
+							"spec": map[string]interface{}{
+								"containers": []interface{}{
+									map[string]interface{}{"image": "metrics-agent:0.2", "name": "sidecar"}}}}}},
+			},
+			expAssObjs: map[string]runtime.Object{
+				"AuxiliaryWorkloadgameconfig": &unstructured.Unstructured{
+					Object: map[string]interface{}{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata":   map[string]interface{}{"name": "testgame-config"}, "data": map[string]interface{}{"enemies": "enemies-data"}},
+				},
+			},
+		},
+
 		// errors
 		"invalid template(space-separated labels) will raise error": {
 			traitTemplate: `
@@ -848,6 +908,26 @@ parameter: [string]: string`,
 			params:        map[string]interface{}{},
 			hasCompileErr: true,
 		},
+		"patch ops to a non-existent output will raise error": {
+			traitTemplate: `
+patchOps: [{
+	op:     "remove"
+	path:   "/data/lives"
+	output: "none"
+}]`,
+			params:        map[string]interface{}{},
+			hasCompileErr: true,
+		},
+		"replacing a non-existent field by patch ops will raise error": {
+			traitTemplate: `
+patchOps: [{
+	op:    "replace"
+	path:  "/spec/none"
+	value: 1
+}]`,
+			params:        map[string]interface{}{},
+			hasCompileErr: true,
+		},
 		"using the wrong keyword in the parameter will raise error": {
 			traitTemplate: `
 patch: {
@@ -1157,6 +1237,48 @@ func TestCheckHealth(t *testing.T) {
 	}
 }
 
+func TestEvalOutput(t *testing.T) {
+	tpContext := map[string]interface{}{
+		"output": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"clusterIP": "10.0.0.1",
+				"ports":     []interface{}{map[string]interface{}{"port": 80}},
+			},
+		},
+	}
+	cases := map[string]struct {
+		valueFrom string
+		exp       interface{}
+		expErr    bool
+	}{
+		"field": {
+			valueFrom: "context.output.spec.clusterIP",
+			exp:       "10.0.0.1",
+		},
+		"expression": {
+			valueFrom: `"http://\(context.output.spec.clusterIP):\(context.output.spec.ports[0].port)"`,
+			exp:       "http://10.0.0.1:80",
+		},
+		"number": {
+			valueFrom: "context.output.spec.ports[0].port",
+			exp:       int64(80),
+		},
+		"not-reported": {
+			valueFrom: "context.output.status.loadBalancer.ingress[0].ip",
+			expErr:    true,
+		},
+	}
+	for message, ca := range cases {
+		value, err := EvalOutput(tpContext, ca.valueFrom)
+		if ca.expErr {
+			assert.Error(t, err, message)
+			continue
+		}
+		assert.NoError(t, err, message)
+		assert.Equal(t, ca.exp, value, message)
+	}
+}
+
 func TestGetStatus(t *testing.T) {
 	cases := map[string]struct {
 		tpContext  map[string]interface{}
@@ -1252,3 +1374,38 @@ if len(context.outputs.ingress.status.loadBalancer.ingress) == 0 {
 		assert.Equal(t, ca.expMessage, gotMessage, message)
 	}
 }
+
+func TestWorkflowStepEvaluate(t *testing.T) {
+	template := `
+parameter: {
+	component: string
+}
+actions: {
+	deploy: {
+		do:        "apply-component"
+		component: parameter.component
+	}
+	wait: {
+		do:       "wait"
+		continue: deploy.result.workload.status.ready
+		message:  "waiting for \(context.appName)"
+	}
+}
+`
+	ctx := process.NewContext("default", "deploy", "myapp", "myapp-v1")
+	sd := NewWorkflowStepEngine("deploy", &packages.PackageDiscover{})
+	inst, err := sd.Evaluate(ctx, template, map[string]interface{}{"component": "web"})
+	assert.NoError(t, err)
+	component, err := inst.Lookup("actions", "deploy", "component").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "web", component)
+	message, err := inst.Lookup("actions", "wait", "message").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "waiting for myapp", message)
+	// the fields referring to results of actions are not concrete until the actions are executed
+	_, err = inst.Lookup("actions", "wait", "continue").Bool()
+	assert.Error(t, err)
+
+	_, err = sd.Evaluate(ctx, template, map[string]interface{}{"component": 1})
+	assert.Error(t, err)
+}
@@ -36,6 +36,7 @@ type Instance interface {
 	Unstructured() (*unstructured.Unstructured, error)
 	IsBase() bool
 	Unify(other Instance) error
+	ApplyPatchOps(ops ...sets.PatchOp) error
 	Compile() ([]byte, error)
 }
 
@@ -97,6 +98,32 @@ func (inst *instance) Unify(other Instance) error {
 	return nil
 }
 
+// ApplyPatchOps implement the JSON-patch style operations on the instance
+func (inst *instance) ApplyPatchOps(ops ...sets.PatchOp) error {
+	pv, err := sets.ApplyPatchOps(inst.v, ops...)
+	if err != nil {
+		return err
+	}
+	inst.v = pv
+	return nil
+}
+
+// NewPatchOp create a JSON-patch style operation, the lists in the value are open like the ones of instances
+func NewPatchOp(op, path string, value cue.Value) (sets.PatchOp, error) {
+	patchOp := sets.PatchOp{Op: op, Path: path}
+	if !value.Exists() {
+		return patchOp, nil
+	}
+	sysopts := []cue.Option{cue.All(), cue.DisallowCycles(true), cue.ResolveReferences(true)}
+	expr, ok := value.Syntax(sysopts...).(ast.Expr)
+	if !ok {
+		return patchOp, errors.Errorf("invalid value of patch op %s %s", op, path)
+	}
+	listOpen(expr)
+	patchOp.Value = expr
+	return patchOp, nil
+}
+
 // NewBase create a base instance
 func NewBase(v cue.Value) (Instance, error) {
 	vs, err := openPrint(v)
@@ -33,6 +33,12 @@ const (
 
 	// StrategyRetainKeys notes on the strategic merge patch using the retainKeys strategy
 	StrategyRetainKeys = "retainKeys"
+	// StrategyReplace notes on the strategic merge patch replacing the value of the field in base,
+	// e.g. the whole list instead of merging the items
+	StrategyReplace = "replace"
+	// StrategyDelete notes on the strategic merge patch deleting the field from base,
+	// or the item with the same patch key from the list in base
+	StrategyDelete = "delete"
 )
 
 var (
@@ -43,6 +49,7 @@ type interceptor func(node ast.Node) (ast.Node, error)
 
 func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.ListLit) {
 	kmaps := map[string]ast.Expr{}
+	deletes := map[string]bool{}
 	nElts := []ast.Expr{}
 
 	for i, elt := range patchList.Elts {
@@ -57,13 +64,19 @@ func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.Lis
 		if !ok {
 			return
 		}
+		if isItemDeleted(elt, key) {
+			deletes[blit.Value] = true
+			continue
+		}
 		kmaps[blit.Value] = patchList.Elts[i]
 	}
 
 	hasStrategyRetainKeys := isStrategyRetainKeys(field)
 
-	for i, elt := range baseList.Elts {
+	baseElts := []ast.Expr{}
+	for _, elt := range baseList.Elts {
 		if _, ok := elt.(*ast.Ellipsis); ok {
+			baseElts = append(baseElts, elt)
 			continue
 		}
 
@@ -76,17 +89,21 @@ func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.Lis
 			return
 		}
 
+		if deletes[blit.Value] {
+			continue
+		}
 		if v, ok := kmaps[blit.Value]; ok {
 			if hasStrategyRetainKeys {
-				baseList.Elts[i] = ast.NewStruct()
+				elt = ast.NewStruct()
 			}
 			nElts = append(nElts, v)
 			delete(kmaps, blit.Value)
 		} else {
 			nElts = append(nElts, ast.NewStruct())
 		}
-
+		baseElts = append(baseElts, elt)
 	}
+	baseList.Elts = baseElts
 
 	for _, elt := range patchList.Elts {
 		for _, v := range kmaps {
@@ -103,12 +120,25 @@ func listMergeProcess(field *ast.Field, key string, baseList, patchList *ast.Lis
 
 func strategyPatchHandle(baseNode ast.Node) interceptor {
 	return func(lnode ast.Node) (ast.Node, error) {
+		var deleted []*ast.Field
 		walker := newWalker(func(node ast.Node, ctx walkCtx) {
 			field, ok := node.(*ast.Field)
 			if !ok {
 				return
 			}
 
+			switch {
+			case hasStrategy(field.Comments(), StrategyReplace):
+				if fe := lookUpField(baseNode, labelStr(field.Label), ctx.Pos()...); fe != nil {
+					fe.Value = ast.NewIdent("_")
+				}
+				return
+			case hasStrategy(field.Comments(), StrategyDelete):
+				removeFields(baseNode, lookUpField(baseNode, labelStr(field.Label), ctx.Pos()...))
+				deleted = append(deleted, field)
+				return
+			}
+
 			value := peelCloseExpr(field.Value)
 
 			switch val := value.(type) {
@@ -164,20 +194,87 @@ func strategyPatchHandle(baseNode ast.Node) interceptor {
 
 		})
 		walker.walk(lnode)
+		// the deleted fields are removed from the patch after walking, their values don't matter
+		removeFields(lnode, deleted...)
 		return lnode, nil
 	}
 }
 
 func isStrategyRetainKeys(node *ast.Field) bool {
-	tags := findCommentTag(node.Comments())
+	return hasStrategy(node.Comments(), StrategyRetainKeys)
+}
+
+// isItemDeleted checks whether the list item is noted to be deleted, the note is either on the item or on its patch key.
+func isItemDeleted(elt ast.Expr, key string) bool {
+	if hasStrategy(ast.Comments(elt), StrategyDelete) {
+		return true
+	}
+	fe := lookUpField(elt, key)
+	return fe != nil && hasStrategy(fe.Comments(), StrategyDelete)
+}
+
+func hasStrategy(commentGroup []*ast.CommentGroup, strategy string) bool {
+	tags := findCommentTag(commentGroup)
 	for tk, tv := range tags {
-		if tk == TagPatchStrategy && tv == StrategyRetainKeys {
+		if tk == TagPatchStrategy && tv == strategy {
 			return true
 		}
 	}
 	return false
 }
 
+// lookUpField returns the field with the label in the struct of the paths, it returns nil if there's no such field.
+func lookUpField(node ast.Node, label string, paths ...string) *ast.Field {
+	parent, err := lookUp(node, paths...)
+	if err != nil {
+		return nil
+	}
+	var decls []ast.Decl
+	switch v := parent.(type) {
+	case *ast.StructLit:
+		decls = v.Elts
+	case *ast.File:
+		decls = v.Decls
+	}
+	for _, decl := range decls {
+		if fe, ok := decl.(*ast.Field); ok && labelStr(fe.Label) == label {
+			return fe
+		}
+	}
+	return nil
+}
+
+// removeFields removes the fields from the structs in node.
+func removeFields(node ast.Node, fields ...*ast.Field) {
+	removed := map[ast.Decl]bool{}
+	for _, fe := range fields {
+		if fe != nil {
+			removed[fe] = true
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	filter := func(decls []ast.Decl) []ast.Decl {
+		kept := decls[:0]
+		for _, decl := range decls {
+			if !removed[decl] {
+				kept = append(kept, decl)
+			}
+		}
+		return kept
+	}
+	ast.Walk(node, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.StructLit:
+			v.Elts = filter(v.Elts)
+		case *ast.File:
+			v.Decls = filter(v.Decls)
+		}
+		return true
+	}, nil)
+}
+
 // StrategyUnify unify the objects by the strategy
 func StrategyUnify(base, patch string) (string, error) {
 	baseFile, err := parser.ParseFile("-", base, parser.ParseComments)
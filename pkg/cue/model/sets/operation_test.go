@@ -370,6 +370,61 @@ spec: {
 		}]
 	}]
 }
+`},
+
+		{
+			base: `containers: [{name: "x1"},{name: "x2"},...]`,
+			patch: `
+// +patchStrategy=replace
+containers: [{name: "x3"}]`,
+			result: `// +patchStrategy=replace
+containers: [{
+	name: "x3"
+}]
+`},
+
+		{
+			base: `spec: replicas: 5`,
+			patch: `
+spec: {
+	// +patchStrategy=replace
+	replicas: 3
+}`,
+			result: `spec: {
+	// +patchStrategy=replace
+	replicas: 3
+}
+`},
+
+		{
+			base: `spec: {replicas: 5, paused: true}`,
+			patch: `
+spec: {
+	// +patchStrategy=delete
+	paused: _
+}
+// +patchStrategy=delete
+none: _`,
+			result: `spec: {
+	replicas: 5
+}
+`},
+
+		{
+			base: `containers: [{name: "x1"},{name: "x2"},{name: "x3"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{
+	// +patchStrategy=delete
+	name: "x2"
+}, {name: "x3", image: "nginx"}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+}, {
+	name:  "x3"
+	image: "nginx"
+}, ...]
 `},
 	}
 
@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sets
+
+import (
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/parser"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PatchOpAdd adds the value to the struct or inserts it into the list, the value of an existing field is replaced
+	PatchOpAdd = "add"
+	// PatchOpReplace replaces the value of an existing field or list item
+	PatchOpReplace = "replace"
+	// PatchOpRemove removes an existing field or list item
+	PatchOpRemove = "remove"
+)
+
+var pointerTokenReplacer = strings.NewReplacer("~1", "/", "~0", "~")
+
+// PatchOp is a JSON-patch style operation, the path is a JSON pointer, e.g. /spec/template/spec/containers/0/image
+type PatchOp struct {
+	Op    string
+	Path  string
+	Value ast.Expr
+}
+
+// ApplyPatchOps applies the operations to the base in order
+func ApplyPatchOps(base string, ops ...PatchOp) (string, error) {
+	baseFile, err := parser.ParseFile("-", base, parser.ParseComments)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid base cue file")
+	}
+	for _, op := range ops {
+		if err := applyPatchOp(baseFile, op); err != nil {
+			return "", errors.WithMessagef(err, "patch op %s %s", op.Op, op.Path)
+		}
+	}
+
+	var r cue.Runtime
+	inst, err := r.CompileFile(baseFile)
+	if err != nil {
+		return "", errors.WithMessage(err, "compile patched file")
+	}
+	ret := inst.Value()
+	rv, err := toString(ret)
+	if err != nil {
+		return rv, errors.WithMessage(err, " format result toString")
+	}
+	if err := ret.Validate(cue.All()); err != nil {
+		return rv, errors.WithMessage(err, "result validate")
+	}
+	return rv, nil
+}
+
+func applyPatchOp(file *ast.File, op PatchOp) error {
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace:
+		if op.Value == nil {
+			return errors.New("value is required")
+		}
+	case PatchOpRemove:
+	default:
+		return errors.Errorf("unsupported op, supported ops are %s, %s and %s", PatchOpAdd, PatchOpReplace, PatchOpRemove)
+	}
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	var parent ast.Node = file
+	for _, token := range tokens[:len(tokens)-1] {
+		child := patchChild(parent, token)
+		if child == nil {
+			return errors.Errorf("%s not found", token)
+		}
+		parent = child
+	}
+
+	last := tokens[len(tokens)-1]
+	switch v := parent.(type) {
+	case *ast.File:
+		v.Decls, err = patchDecls(v.Decls, last, op)
+	case *ast.StructLit:
+		v.Elts, err = patchDecls(v.Elts, last, op)
+	case *ast.ListLit:
+		v.Elts, err = patchElts(v.Elts, last, op)
+	default:
+		err = errors.Errorf("parent of %s is neither a struct nor a list", last)
+	}
+	return err
+}
+
+// parsePointer splits the JSON pointer into the unescaped reference tokens
+func parsePointer(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.Errorf("path must be a JSON pointer starting with /")
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerTokenReplacer.Replace(token)
+	}
+	return tokens, nil
+}
+
+// patchChild returns the value of the field or the list item of the token, it returns nil if there's no such one.
+func patchChild(node ast.Node, token string) ast.Node {
+	switch v := node.(type) {
+	case *ast.File:
+		if i := indexField(v.Decls, token); i >= 0 {
+			return peelCloseExpr(v.Decls[i].(*ast.Field).Value)
+		}
+	case *ast.StructLit:
+		if i := indexField(v.Elts, token); i >= 0 {
+			return peelCloseExpr(v.Elts[i].(*ast.Field).Value)
+		}
+	case *ast.ListLit:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < itemCount(v.Elts) {
+			return peelCloseExpr(v.Elts[i])
+		}
+	}
+	return nil
+}
+
+func patchDecls(decls []ast.Decl, name string, op PatchOp) ([]ast.Decl, error) {
+	i := indexField(decls, name)
+	if i < 0 {
+		if op.Op != PatchOpAdd {
+			return nil, errors.Errorf("%s not found", name)
+		}
+		return append(decls, &ast.Field{Label: ast.NewString(name), Value: op.Value}), nil
+	}
+	if op.Op == PatchOpRemove {
+		return append(decls[:i:i], decls[i+1:]...), nil
+	}
+	decls[i].(*ast.Field).Value = op.Value
+	return decls, nil
+}
+
+func patchElts(elts []ast.Expr, token string, op PatchOp) ([]ast.Expr, error) {
+	count := itemCount(elts)
+	i := count
+	if token != "-" || op.Op != PatchOpAdd {
+		var err error
+		if i, err = strconv.Atoi(token); err != nil || i < 0 {
+			return nil, errors.Errorf("invalid list index %s", token)
+		}
+	}
+	switch {
+	case op.Op == PatchOpAdd && i <= count:
+		nElts := append(elts[:i:i], op.Value)
+		return append(nElts, elts[i:]...), nil
+	case i >= count:
+		return nil, errors.Errorf("list index %s out of range", token)
+	case op.Op == PatchOpRemove:
+		return append(elts[:i:i], elts[i+1:]...), nil
+	default:
+		elts[i] = op.Value
+		return elts, nil
+	}
+}
+
+func indexField(decls []ast.Decl, name string) int {
+	for i, decl := range decls {
+		if fe, ok := decl.(*ast.Field); ok && fieldName(fe.Label) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// itemCount returns the number of the items in the list, the trailing ellipsis of an open list isn't an item
+func itemCount(elts []ast.Expr) int {
+	if len(elts) > 0 {
+		if _, ok := elts[len(elts)-1].(*ast.Ellipsis); ok {
+			return len(elts) - 1
+		}
+	}
+	return len(elts)
+}
+
+// fieldName returns the name of the label, the quoted names are unquoted
+func fieldName(label ast.Label) string {
+	name := labelStr(label)
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		return unquoted
+	}
+	return name
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sets
+
+import (
+	"fmt"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/parser"
+	"github.com/bmizerany/assert"
+)
+
+func newPatchOp(t *testing.T, op, path, value string) PatchOp {
+	patchOp := PatchOp{Op: op, Path: path}
+	if value != "" {
+		expr, err := parser.ParseExpr("-", value)
+		assert.Equal(t, err, nil)
+		patchOp.Value = expr
+	}
+	return patchOp
+}
+
+func TestApplyPatchOps(t *testing.T) {
+	base := `
+metadata: name: "web"
+spec: {
+	replicas: 1
+	ports: [{port: 80}, ...]
+}
+`
+	ops := []PatchOp{
+		newPatchOp(t, PatchOpReplace, "/spec/replicas", `3`),
+		newPatchOp(t, PatchOpAdd, "/spec/ports/-", `{port: 443}`),
+		newPatchOp(t, PatchOpAdd, "/spec/ports/0", `{port: 8080}`),
+		newPatchOp(t, PatchOpRemove, "/metadata/name", ""),
+		newPatchOp(t, PatchOpAdd, "/metadata/labels", `{"app.oam.dev/name": "web"}`),
+		newPatchOp(t, PatchOpReplace, "/metadata/labels/app.oam.dev~1name", `"api"`),
+	}
+	v, err := ApplyPatchOps(base, ops...)
+	assert.Equal(t, err, nil)
+
+	var r cue.Runtime
+	inst, err := r.Compile("-", v)
+	assert.Equal(t, err, nil)
+	js, err := inst.Value().MarshalJSON()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(js), `{"metadata":{"labels":{"app.oam.dev/name":"api"}},"spec":{"replicas":3,"ports":[{"port":8080},{"port":80},{"port":443}]}}`)
+
+	errCases := []PatchOp{
+		newPatchOp(t, PatchOpReplace, "/spec/none", `1`),
+		newPatchOp(t, PatchOpRemove, "/spec/ports/1", ""),
+		newPatchOp(t, PatchOpAdd, "/spec/ports/x", `{port: 443}`),
+		newPatchOp(t, PatchOpAdd, "/none/replicas", `1`),
+		newPatchOp(t, PatchOpAdd, "/spec/replicas", ""),
+		newPatchOp(t, "move", "/spec/replicas", ""),
+		newPatchOp(t, PatchOpRemove, "spec", ""),
+	}
+	for i, op := range errCases {
+		_, err := ApplyPatchOps(`spec: {replicas: int, ports: [{port: 80}, ...]}`, op)
+		assert.NotEqual(t, err, nil, fmt.Sprintf("case(no:%d) %s %s", i, op.Op, op.Path))
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	tokens, err := parsePointer("/metadata/annotations/a~1b~0c/0")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tokens, []string{"metadata", "annotations", "a/b~c", "0"})
+	_, err = parsePointer("metadata")
+	assert.NotEqual(t, err, nil)
+
+	assert.Equal(t, fieldName(ast.NewString("a/b")), "a/b")
+	assert.Equal(t, fieldName(ast.NewIdent("name")), "name")
+}
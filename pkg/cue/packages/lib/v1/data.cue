@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// #Checksum is the sha256 in hex of the data marshalled to JSON, e.g. annotating the pods with the checksum of
+// the data of a ConfigMap rolls them out when the data changes.
+//
+//   metadata: annotations: "checksum/config": (lib.#Checksum & {data: parameter.config}).out
+#Checksum: {
+	data: _
+	out:  hex.Encode(sha256.Sum256(json.Marshal(data)))
+}
+
+// #Base64Data encodes the values of the data in base64, e.g. for the data of a Secret.
+//
+//   data: (lib.#Base64Data & {data: parameter.credentials}).out
+#Base64Data: {
+	data: [string]: string
+	out: {for k, v in data {"\(k)": base64.Encode(null, v)}}
+}
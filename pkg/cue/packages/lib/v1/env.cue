@@ -0,0 +1,12 @@
+package lib
+
+// #MergeEnvs merges the env lists by name, the envs in override replace the ones with the same name in base.
+//
+//   env: (lib.#MergeEnvs & {base: parameter.env, override: [{name: "MODE", value: "prod"}]}).out
+#MergeEnvs: {
+	base: [...{name: string, ...}]
+	override: [...{name: string, ...}]
+
+	_overridden: {for e in override {"\(e.name)": true}}
+	out: [ for e in base if _overridden[e.name] == _|_ {e}] + [ for e in override {e}]
+}
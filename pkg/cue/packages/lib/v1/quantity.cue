@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// #ParseQuantity parses a resource quantity, e.g. "500m", "1.5", "128Mi" or "1G", into integers as
+// Quantity.Value and Quantity.MilliValue of Kubernetes do, so quantities can be compared and computed.
+//
+//   _memory: lib.#ParseQuantity & {quantity: parameter.memory}
+//   if _memory.value < 134217728 {...}
+#ParseQuantity: {
+	quantity: string | number
+
+	_match: regexp.FindSubmatch(#"^([0-9]+)(?:\.([0-9]+))?(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$"#, "\(quantity)")
+	_whole: strconv.Atoi(_match[1])
+	_fraction: {
+		digits: _match[2]
+		if digits == "" {
+			value: 0
+			scale: 1
+		}
+		if digits != "" {
+			value: strconv.Atoi(digits)
+			scale: _pow10[len(digits)]
+		}
+	}
+	// the milli units of the suffixes
+	_milli: {
+		"":   1000
+		"m":  1
+		"k":  1000 * 1000
+		"M":  1000 * 1000 * 1000
+		"G":  1000 * 1000 * 1000 * 1000
+		"T":  1000 * 1000 * 1000 * 1000 * 1000
+		"P":  1000 * 1000 * 1000 * 1000 * 1000 * 1000
+		"E":  1000 * 1000 * 1000 * 1000 * 1000 * 1000 * 1000
+		"Ki": 1000 * 1024
+		"Mi": 1000 * 1024 * 1024
+		"Gi": 1000 * 1024 * 1024 * 1024
+		"Ti": 1000 * 1024 * 1024 * 1024 * 1024
+		"Pi": 1000 * 1024 * 1024 * 1024 * 1024 * 1024
+		"Ei": 1000 * 1024 * 1024 * 1024 * 1024 * 1024 * 1024
+	}[_match[3]]
+	_pow10: [1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000, 1000000000]
+
+	// milliValue is the quantity in milli units rounded up, e.g. 500 of "500m"
+	milliValue: int & (_whole*_milli*_fraction.scale+_fraction.value*_milli+_fraction.scale-1) div _fraction.scale
+	// value is the quantity rounded up, e.g. 1 of "500m", 134217728 of "128Mi"
+	value: int & (milliValue+999) div 1000
+}
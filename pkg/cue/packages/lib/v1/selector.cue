@@ -0,0 +1,18 @@
+package lib
+
+import (
+	"list"
+	"strings"
+)
+
+// #LabelSelector builds the label selector of the labels, in the structured form and the string form.
+//
+//   spec: selector: (lib.#LabelSelector & {labels: {"app": context.name}}).out
+//   labelSelector: (lib.#LabelSelector & {labels: {"app": context.name}}).query
+#LabelSelector: {
+	labels: [string]: string
+
+	out: matchLabels: labels
+	// query is the selector of the labels sorted by key, e.g. "app=web,tier=frontend"
+	query: strings.Join(list.SortStrings([ for k, v in labels {"\(k)=\(v)"}]), ",")
+}
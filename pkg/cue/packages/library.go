@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packages
+
+import (
+	"embed"
+	"path"
+	"sort"
+
+	"cuelang.org/go/cue/build"
+	"github.com/pkg/errors"
+)
+
+const (
+	// LibraryPackagePrefix is the import path prefix of the helper library shipped with KubeVela,
+	// its versions are imported as `vela/lib/<version>`, e.g. `vela/lib/v1`
+	LibraryPackagePrefix = "vela/lib"
+	// LibraryPackageName is the package name of the helper library
+	LibraryPackageName = "lib"
+
+	libraryDir = "lib"
+)
+
+// library is the CUE files of the helper library, each directory is a version of it. A version is never changed
+// incompatibly once released, so templates can rely on it, incompatible changes are released as a new version.
+//
+//go:embed lib
+var library embed.FS
+
+// libraryFile is a CUE file of a version of the helper library
+type libraryFile struct {
+	name string
+	src  string
+}
+
+// libraryVersions are the files of the versions of the helper library read at init, libraryErr is the error of reading them.
+var (
+	libraryVersions map[string][]libraryFile
+	libraryErr      error
+)
+
+func init() {
+	libraryVersions, libraryErr = readLibrary()
+}
+
+func readLibrary() (map[string][]libraryFile, error) {
+	versions, err := library.ReadDir(libraryDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]libraryFile)
+	for _, version := range versions {
+		if !version.IsDir() {
+			continue
+		}
+		dir := path.Join(libraryDir, version.Name())
+		entries, err := library.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range entries {
+			if f.IsDir() || path.Ext(f.Name()) != ".cue" {
+				continue
+			}
+			src, err := library.ReadFile(path.Join(dir, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			files[version.Name()] = append(files[version.Name()], libraryFile{name: f.Name(), src: string(src)})
+		}
+	}
+	return files, nil
+}
+
+// newLibraryPackages builds the versions of the helper library. They're built once for each PackageDiscover
+// since instances can't be shared by the builds without PackageDiscover locked, e.g. those of different PackageDiscovers.
+func newLibraryPackages() ([]*build.Instance, error) {
+	if libraryErr != nil {
+		return nil, errors.WithMessage(libraryErr, "read the helper library")
+	}
+	versions := make([]string, 0, len(libraryVersions))
+	for version := range libraryVersions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	var pkgs []*build.Instance
+	for _, version := range versions {
+		pkg := newPackage(path.Join(LibraryPackagePrefix, version))
+		pkg.PkgName = LibraryPackageName
+		for _, f := range libraryVersions[version] {
+			if err := pkg.AddFile(path.Join(pkg.ImportPath, f.name), f.src); err != nil {
+				return nil, errors.WithMessagef(err, "invalid library file %s", f.name)
+			}
+		}
+		pkgs = append(pkgs, pkg.Instance)
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packages
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cuelang.org/go/cue/build"
+	"gotest.tools/assert"
+)
+
+func TestLibraryPackages(t *testing.T) {
+	pkgs, err := newLibraryPackages()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pkgs), 1)
+	assert.Equal(t, pkgs[0].ImportPath, "vela/lib/v1")
+	assert.Equal(t, pkgs[0].PkgName, LibraryPackageName)
+
+	// the library is imported without any kube package
+	bi := build.NewContext().NewInstance("", nil)
+	assert.NilError(t, bi.AddFile("-", `
+import lib "vela/lib/v1"
+
+output: {
+	env: (lib.#MergeEnvs & {
+		base: [{name: "MODE", value: "dev"}, {name: "PORT", value: "80"}]
+		override: [{name: "MODE", value: "prod"}, {name: "DEBUG", value: "false"}]
+	}).out
+	selector: lib.#LabelSelector & {labels: {b: "2", a: "1"}}
+	milliCPU:  (lib.#ParseQuantity & {quantity: "500m"}).milliValue
+	cpu:       (lib.#ParseQuantity & {quantity: "500m"}).value
+	fraction:  (lib.#ParseQuantity & {quantity: "1.5"}).milliValue
+	number:    (lib.#ParseQuantity & {quantity: 2}).value
+	memory:    (lib.#ParseQuantity & {quantity: "128Mi"}).value
+	storage:   (lib.#ParseQuantity & {quantity: "1G"}).value
+	checksum:  (lib.#Checksum & {data: {key: "value"}}).out
+	secret:    (lib.#Base64Data & {data: {password: "vela"}}).out
+}
+`))
+	inst, err := (&PackageDiscover{}).ImportPackagesAndBuildInstance(bi)
+	assert.NilError(t, err)
+	data, err := inst.Lookup("output").MarshalJSON()
+	assert.NilError(t, err)
+	var output struct {
+		Env      []map[string]string `json:"env"`
+		Selector struct {
+			Out   map[string]interface{} `json:"out"`
+			Query string                 `json:"query"`
+		} `json:"selector"`
+		MilliCPU int64             `json:"milliCPU"`
+		CPU      int64             `json:"cpu"`
+		Fraction int64             `json:"fraction"`
+		Number   int64             `json:"number"`
+		Memory   int64             `json:"memory"`
+		Storage  int64             `json:"storage"`
+		Checksum string            `json:"checksum"`
+		Secret   map[string]string `json:"secret"`
+	}
+	assert.NilError(t, json.Unmarshal(data, &output))
+	assert.DeepEqual(t, output.Env, []map[string]string{
+		{"name": "PORT", "value": "80"},
+		{"name": "MODE", "value": "prod"},
+		{"name": "DEBUG", "value": "false"},
+	})
+	assert.DeepEqual(t, output.Selector.Out, map[string]interface{}{
+		"matchLabels": map[string]interface{}{"a": "1", "b": "2"},
+	})
+	assert.Equal(t, output.Selector.Query, "a=1,b=2")
+	assert.Equal(t, output.MilliCPU, int64(500))
+	assert.Equal(t, output.CPU, int64(1))
+	assert.Equal(t, output.Fraction, int64(1500))
+	assert.Equal(t, output.Number, int64(2))
+	assert.Equal(t, output.Memory, int64(134217728))
+	assert.Equal(t, output.Storage, int64(1000000000))
+	// sha256 of {"key":"value"}
+	assert.Equal(t, output.Checksum, "e43abcf3375244839c012f9633f95862d232a95b00d5bc7348b3098b9fed7f32")
+	assert.DeepEqual(t, output.Secret, map[string]string{"password": "dmVsYQ=="})
+}
+
+func TestLibraryRegisteredOnce(t *testing.T) {
+	pd := &PackageDiscover{}
+	var imported [][]*build.Instance
+	for i := 0; i < 2; i++ {
+		bi := build.NewContext().NewInstance("", nil)
+		assert.NilError(t, pd.ImportBuiltinPackagesFor(bi))
+		imported = append(imported, bi.Imports)
+	}
+	assert.Equal(t, len(imported[0]), 1)
+	assert.Equal(t, imported[0][0].ImportPath, "vela/lib/v1")
+	// the library built for the PackageDiscover is reused by the imports
+	assert.Equal(t, imported[0][0], imported[1][0])
+	assert.Equal(t, len(pd.velaBuiltinPackages), 1)
+}
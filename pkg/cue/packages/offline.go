@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packages
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultKubeVersion is the Kubernetes version of the embedded snapshot used if the version isn't specified
+	DefaultKubeVersion = "v1.20"
+	// OpenAPISchemaFileEnv is the env naming the OpenAPI document used when no cluster is reachable
+	OpenAPISchemaFileEnv = "VELA_OPENAPI_SCHEMA"
+	// KubeVersionEnv is the env choosing the embedded snapshot used when no cluster is reachable
+	KubeVersionEnv = "VELA_KUBE_VERSION"
+
+	snapshotDir    = "openapi"
+	snapshotSuffix = ".json.gz"
+)
+
+// snapshots are the gzipped OpenAPI documents of Kubernetes releases, named `<version>.json.gz`,
+// they're updated by `make openapi-snapshots`.
+//
+//go:embed openapi
+var snapshots embed.FS
+
+// NewPackageDiscoverFromFile creates a PackageDiscover with the packages built from the OpenAPI document
+// in the file, e.g. the output of `kubectl get --raw /openapi/v2`, the file can be gzipped.
+// The packages can't be refreshed since there is no cluster.
+func NewPackageDiscoverFromFile(file string) (*PackageDiscover, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	return newPackageDiscoverFromSchema(data)
+}
+
+// NewPackageDiscoverFromSnapshot creates a PackageDiscover with the packages built from the embedded
+// OpenAPI document of the Kubernetes version, e.g. `v1.20`.
+func NewPackageDiscoverFromSnapshot(kubeVersion string) (*PackageDiscover, error) {
+	if kubeVersion == "" {
+		kubeVersion = DefaultKubeVersion
+	}
+	data, err := snapshots.ReadFile(path.Join(snapshotDir, kubeVersion+snapshotSuffix))
+	if err != nil {
+		return nil, errors.Errorf("there is no OpenAPI snapshot of kubernetes %s, the available ones are [%s]",
+			kubeVersion, strings.Join(ListSnapshotVersions(), ", "))
+	}
+	return newPackageDiscoverFromSchema(data)
+}
+
+// NewOfflinePackageDiscover creates a PackageDiscover without a cluster, the packages are built from the OpenAPI
+// document in the file if it's given, otherwise from the embedded snapshot of the Kubernetes version.
+func NewOfflinePackageDiscover(file, kubeVersion string) (*PackageDiscover, error) {
+	if file != "" {
+		return NewPackageDiscoverFromFile(file)
+	}
+	return NewPackageDiscoverFromSnapshot(kubeVersion)
+}
+
+// ListSnapshotVersions lists the Kubernetes versions of the embedded OpenAPI snapshots.
+func ListSnapshotVersions() []string {
+	entries, err := snapshots.ReadDir(snapshotDir)
+	if err != nil {
+		return nil
+	}
+	var versions []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), snapshotSuffix) {
+			versions = append(versions, strings.TrimSuffix(e.Name(), snapshotSuffix))
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func newPackageDiscoverFromSchema(data []byte) (*PackageDiscover, error) {
+	// gzip magic number
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return nil, errors.Wrap(err, "decompress OpenAPI document")
+		}
+	}
+	pd := &PackageDiscover{
+		pkgKinds: make(map[string][]VersionKind),
+	}
+	if err := pd.addKubeCUEPackagesFromCluster(string(data)); err != nil {
+		return nil, err
+	}
+	return pd, nil
+}
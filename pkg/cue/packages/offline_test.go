@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package packages
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/build"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewOfflinePackageDiscover(t *testing.T) {
+	openAPISchema := `
+{
+	"paths": {
+		"/apis/apps/v1/namespaces/{namespace}/deployments": {
+			"post": {
+				"x-kubernetes-group-version-kind": {"group": "apps", "kind": "Deployment", "version": "v1"}
+			}
+		}
+	},
+	"definitions": {
+		"io.k8s.api.apps.v1.Deployment": {
+			"properties": {
+				"apiVersion": {"type": "string"},
+				"kind": {"type": "string"},
+				"replicas": {"type": "integer"}
+			},
+			"type": "object"
+		}
+	}
+}
+`
+	dir, err := ioutil.TempDir("", "openapi")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	plain := filepath.Join(dir, "swagger.json")
+	assert.NilError(t, ioutil.WriteFile(plain, []byte(openAPISchema), 0600))
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	_, err = w.Write([]byte(openAPISchema))
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	compressed := filepath.Join(dir, "swagger.json.gz")
+	assert.NilError(t, ioutil.WriteFile(compressed, gzipped.Bytes(), 0600))
+
+	for _, file := range []string{plain, compressed} {
+		pd, err := NewOfflinePackageDiscover(file, "")
+		assert.NilError(t, err)
+		assert.Equal(t, pd.Exist(metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}), true)
+		// there is no cluster to refresh the packages from
+		assert.NilError(t, pd.RefreshKubePackagesFromCluster())
+
+		bi := build.NewContext().NewInstance("", nil)
+		assert.NilError(t, bi.AddFile("-", `
+import "kube/apps/v1"
+output: v1.#Deployment & {replicas: 2}
+`))
+		inst, err := pd.ImportPackagesAndBuildInstance(bi)
+		assert.NilError(t, err)
+		replicas, err := inst.Lookup("output", "replicas").Int64()
+		assert.NilError(t, err)
+		assert.Equal(t, replicas, int64(2))
+	}
+
+	_, err = NewOfflinePackageDiscover(filepath.Join(dir, "none.json"), "")
+	assert.Assert(t, err != nil)
+	_, err = NewOfflinePackageDiscover("", "v0.1")
+	assert.ErrorContains(t, err, "there is no OpenAPI snapshot of kubernetes v0.1")
+}
+
+func TestNewPackageDiscoverFromDefaultSnapshot(t *testing.T) {
+	assert.Assert(t, is.Contains(ListSnapshotVersions(), DefaultKubeVersion))
+	pd, err := NewOfflinePackageDiscover("", "")
+	assert.NilError(t, err)
+	assert.Equal(t, pd.Exist(metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}), true)
+
+	bi := build.NewContext().NewInstance("", nil)
+	assert.NilError(t, bi.AddFile("-", `
+import "kube/apps/v1"
+output: v1.#Deployment & {
+	metadata: name: "web"
+	spec: replicas: 2
+}
+`))
+	inst, err := pd.ImportPackagesAndBuildInstance(bi)
+	assert.NilError(t, err)
+	replicas, err := inst.Lookup("output", "spec", "replicas").Int64()
+	assert.NilError(t, err)
+	assert.Equal(t, replicas, int64(2))
+}
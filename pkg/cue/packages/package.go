@@ -18,10 +18,12 @@ package packages
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"cuelang.org/go/cue"
@@ -32,6 +34,7 @@ import (
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -47,12 +50,20 @@ const (
 	ParseJSONSchemaErr ParseErrType = "parse json schema of k8s crds error"
 )
 
+// generations numbers the mounts of packages of all PackageDiscovers
+var generations uint64
+
 // PackageDiscover defines the inner CUE packages loaded from K8s cluster
 type PackageDiscover struct {
 	velaBuiltinPackages []*build.Instance
 	pkgKinds            map[string][]VersionKind
 	mutex               sync.RWMutex
 	client              *rest.RESTClient
+	generation          uint64
+
+	// libraryOnce registers the helper library into the built-in packages on the first import
+	libraryOnce sync.Once
+	libraryErr  error
 }
 
 // VersionKind contains the resource metadata and reference name
@@ -97,16 +108,37 @@ func NewPackageDiscover(config *rest.Config) (*PackageDiscover, error) {
 	return pd, nil
 }
 
-// ImportBuiltinPackagesFor will add KubeVela built-in packages into your CUE instance
-func (pd *PackageDiscover) ImportBuiltinPackagesFor(bi *build.Instance) {
+// ImportBuiltinPackagesFor will add KubeVela built-in packages into your CUE instance,
+// they're the kube packages and the helper library `vela/lib/<version>`.
+func (pd *PackageDiscover) ImportBuiltinPackagesFor(bi *build.Instance) error {
+	pd.libraryOnce.Do(pd.registerLibrary)
+	if pd.libraryErr != nil {
+		return pd.libraryErr
+	}
 	pd.mutex.RLock()
 	defer pd.mutex.RUnlock()
 	bi.Imports = append(bi.Imports, pd.velaBuiltinPackages...)
+	return nil
+}
+
+// registerLibrary builds the helper library and registers it into the built-in packages,
+// the library isn't changed so the templates compiled before aren't invalidated.
+func (pd *PackageDiscover) registerLibrary() {
+	libs, err := newLibraryPackages()
+	if err != nil {
+		pd.libraryErr = err
+		return
+	}
+	pd.mutex.Lock()
+	defer pd.mutex.Unlock()
+	pd.velaBuiltinPackages = append(pd.velaBuiltinPackages, libs...)
 }
 
 // ImportPackagesAndBuildInstance Combine import built-in packages and build cue template together to avoid data race
 func (pd *PackageDiscover) ImportPackagesAndBuildInstance(bi *build.Instance) (inst *cue.Instance, err error) {
-	pd.ImportBuiltinPackagesFor(bi)
+	if err := pd.ImportBuiltinPackagesFor(bi); err != nil {
+		return nil, err
+	}
 
 	var r cue.Runtime
 	pd.mutex.Lock()
@@ -118,6 +150,14 @@ func (pd *PackageDiscover) ImportPackagesAndBuildInstance(bi *build.Instance) (i
 	return cueInst, err
 }
 
+// Generation returns a number changed whenever packages are mounted, and unique among PackageDiscovers,
+// so the templates compiled with the packages can be cached by it.
+func (pd *PackageDiscover) Generation() uint64 {
+	pd.mutex.RLock()
+	defer pd.mutex.RUnlock()
+	return pd.generation
+}
+
 // ListPackageKinds list packages and their kinds
 func (pd *PackageDiscover) ListPackageKinds() map[string][]VersionKind {
 	pd.mutex.RLock()
@@ -127,6 +167,10 @@ func (pd *PackageDiscover) ListPackageKinds() map[string][]VersionKind {
 
 // RefreshKubePackagesFromCluster will use K8s client to load/refresh all K8s open API as a reference kube package using in template
 func (pd *PackageDiscover) RefreshKubePackagesFromCluster() error {
+	if pd.client == nil {
+		// created offline, the packages are loaded from an OpenAPI document which doesn't change
+		return nil
+	}
 	body, err := pd.client.Get().AbsPath("/openapi/v2").Do(context.Background()).Raw()
 	if err != nil {
 		return err
@@ -134,6 +178,111 @@ func (pd *PackageDiscover) RefreshKubePackagesFromCluster() error {
 	return pd.addKubeCUEPackagesFromCluster(string(body))
 }
 
+// RefreshKubePackagesOf refreshes the packages of the group versions of the kinds from the cluster, the other
+// packages are untouched. It's used when CRDs change, the packages of the group versions without any kind are removed.
+// The OpenAPI v2 document can't be fetched by group version, so only the schemas of the group versions and
+// the definitions they refer to are kept from it to be compiled.
+func (pd *PackageDiscover) RefreshKubePackagesOf(gvks []metav1.GroupVersionKind) error {
+	if pd.client == nil || len(gvks) == 0 {
+		return nil
+	}
+	body, err := pd.client.Get().AbsPath("/openapi/v2").Do(context.Background()).Raw()
+	if err != nil {
+		return err
+	}
+	groupVersions := make(map[string]bool)
+	for _, gvk := range gvks {
+		groupVersions[convert2DGVK(gvk).APIVersion] = true
+	}
+	filtered, err := filterOpenAPISchema(body, groupVersions)
+	if err != nil {
+		return err
+	}
+	return pd.addKubeCUEPackages(string(filtered), groupVersions)
+}
+
+// filterOpenAPISchema keeps the paths creating the kinds of the group versions in the OpenAPI document,
+// and the definitions of the kinds with the ones they refer to.
+func filterOpenAPISchema(apiSchema []byte, groupVersions map[string]bool) ([]byte, error) {
+	var doc struct {
+		Paths       map[string]map[string]json.RawMessage `json:"paths"`
+		Definitions map[string]json.RawMessage            `json:"definitions"`
+	}
+	if err := json.Unmarshal(apiSchema, &doc); err != nil {
+		return nil, errors.Wrap(err, "invalid OpenAPI document")
+	}
+	kinds := make(map[string]bool)
+	paths := make(map[string]interface{})
+	for name, item := range doc.Paths {
+		post, ok := item["post"]
+		if !ok {
+			continue
+		}
+		var op struct {
+			GVK *metav1.GroupVersionKind `json:"x-kubernetes-group-version-kind"`
+		}
+		if err := json.Unmarshal(post, &op); err != nil || op.GVK == nil {
+			continue
+		}
+		dgvk := convert2DGVK(*op.GVK)
+		if !groupVersions[dgvk.APIVersion] {
+			continue
+		}
+		kinds[dgvk.reverseString()] = true
+		paths[name] = map[string]interface{}{"post": map[string]interface{}{"x-kubernetes-group-version-kind": op.GVK}}
+	}
+
+	definitions := make(map[string]json.RawMessage)
+	var refer func(name string)
+	refer = func(name string) {
+		def, ok := doc.Definitions[name]
+		if !ok {
+			return
+		}
+		if _, ok := definitions[name]; ok {
+			return
+		}
+		definitions[name] = def
+		for _, ref := range definitionRefs(def) {
+			refer(ref)
+		}
+	}
+	for name := range doc.Definitions {
+		if kinds[strings.NewReplacer(".", "_", "-", "_").Replace(name)] {
+			refer(name)
+		}
+	}
+	return json.Marshal(map[string]interface{}{"paths": paths, "definitions": definitions})
+}
+
+// definitionRefs returns the names of the definitions referred by `$ref` in the schema.
+func definitionRefs(schema json.RawMessage) []string {
+	var v interface{}
+	if err := json.Unmarshal(schema, &v); err != nil {
+		return nil
+	}
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			for k, field := range x {
+				if ref, ok := field.(string); ok && k == "$ref" && strings.HasPrefix(ref, "#/definitions/") {
+					refs = append(refs, strings.TrimPrefix(ref, "#/definitions/"))
+					continue
+				}
+				walk(field)
+			}
+		case []interface{}:
+			for _, item := range x {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+	return refs
+}
+
 // Exist checks if the GVK exists in the built-in packages
 func (pd *PackageDiscover) Exist(gvk metav1.GroupVersionKind) bool {
 	dgvk := convert2DGVK(gvk)
@@ -153,10 +302,30 @@ func (pd *PackageDiscover) Exist(gvk metav1.GroupVersionKind) bool {
 	return false
 }
 
+// unmount will remove the packages of the import paths from PackageDiscover built-in packages
+func (pd *PackageDiscover) unmount(importPaths ...string) {
+	pd.mutex.Lock()
+	defer pd.mutex.Unlock()
+	for _, importPath := range importPaths {
+		if _, ok := pd.pkgKinds[importPath]; !ok {
+			continue
+		}
+		pd.generation = atomic.AddUint64(&generations, 1)
+		delete(pd.pkgKinds, importPath)
+		for i, p := range pd.velaBuiltinPackages {
+			if p.ImportPath == importPath {
+				pd.velaBuiltinPackages = append(pd.velaBuiltinPackages[:i:i], pd.velaBuiltinPackages[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
 // mount will mount the new parsed package into PackageDiscover built-in packages
 func (pd *PackageDiscover) mount(pkg *pkgInstance, pkgKinds []VersionKind) {
 	pd.mutex.Lock()
 	defer pd.mutex.Unlock()
+	pd.generation = atomic.AddUint64(&generations, 1)
 	for i, p := range pd.velaBuiltinPackages {
 		if p.ImportPath == pkg.ImportPath {
 			pd.pkgKinds[pkg.ImportPath] = pkgKinds
@@ -193,6 +362,12 @@ func (pd *PackageDiscover) pkgBuild(packages map[string]*pkgInstance, pkgName st
 }
 
 func (pd *PackageDiscover) addKubeCUEPackagesFromCluster(apiSchema string) error {
+	return pd.addKubeCUEPackages(apiSchema, nil)
+}
+
+// addKubeCUEPackages mounts the packages built from the OpenAPI document. If groupVersions isn't nil, only the
+// packages of the group versions in it are mounted, and those of them without any kind in the document are unmounted.
+func (pd *PackageDiscover) addKubeCUEPackages(apiSchema string, groupVersions map[string]bool) error {
 	var r cue.Runtime
 	oaInst, err := r.Compile("-", apiSchema)
 	if err != nil {
@@ -235,6 +410,9 @@ func (pd *PackageDiscover) addKubeCUEPackagesFromCluster(apiSchema string) error
 	for k := range dgvkMapper {
 		v := dgvkMapper[k]
 		apiVersion := v.APIVersion
+		if groupVersions != nil && !groupVersions[apiVersion] {
+			continue
+		}
 		def := fmt.Sprintf(`
 import "kube"
 
@@ -253,6 +431,18 @@ apiVersion: "%s",
 	for name, pkg := range packages {
 		pd.mount(pkg, groupKinds[name])
 	}
+	for apiVersion := range groupVersions {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			continue
+		}
+		v := convert2DGVK(metav1.GroupVersionKind{Group: gv.Group, Version: gv.Version})
+		for _, name := range []string{genStandardPkgName(v), genOpenPkgName(v)} {
+			if _, ok := packages[name]; !ok {
+				pd.unmount(name)
+			}
+		}
+	}
 	return nil
 }
 
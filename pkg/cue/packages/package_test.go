@@ -17,7 +17,12 @@ limitations under the License.
 package packages
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue"
@@ -27,6 +32,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"gotest.tools/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
 
 	"github.com/oam-dev/kubevela/pkg/cue/model"
 )
@@ -628,3 +634,106 @@ func TestReverseString(t *testing.T) {
 		assert.Equal(t, convert2DGVK(tCase.gvr).reverseString(), tCase.reverseString)
 	}
 }
+
+func TestRefreshKubePackagesOf(t *testing.T) {
+	openAPISchema := func(kinds ...metav1.GroupVersionKind) string {
+		var paths, definitions []string
+		for _, gvk := range kinds {
+			name := convert2DGVK(gvk).reverseString()
+			paths = append(paths, fmt.Sprintf(`"/%s": {"post": {"x-kubernetes-group-version-kind": {"group": "%s", "kind": "%s", "version": "%s"}}}`,
+				name, gvk.Group, gvk.Kind, gvk.Version))
+			definitions = append(definitions, fmt.Sprintf(`"%s": {"properties": {"apiVersion": {"type": "string"}, "kind": {"type": "string"}}, "type": "object"}`, name))
+		}
+		return fmt.Sprintf(`{"paths": {%s}, "definitions": {%s}}`, strings.Join(paths, ","), strings.Join(definitions, ","))
+	}
+	bucket := metav1.GroupVersionKind{Group: "apps.test.io", Version: "v1", Kind: "Bucket"}
+	vpc := metav1.GroupVersionKind{Group: "apps.test.io", Version: "v1", Kind: "Vpc"}
+	job := metav1.GroupVersionKind{Group: "batch.test.io", Version: "v1", Kind: "Job"}
+
+	schema := openAPISchema(bucket, job)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(schema))
+	}))
+	defer srv.Close()
+	pd, err := NewPackageDiscover(&rest.Config{Host: srv.URL})
+	assert.NilError(t, err)
+	assert.Equal(t, pd.Exist(bucket), true)
+	assert.Equal(t, pd.Exist(job), true)
+
+	// only the packages of the group versions are refreshed
+	schema = openAPISchema(bucket, vpc)
+	generation := pd.Generation()
+	assert.NilError(t, pd.RefreshKubePackagesOf([]metav1.GroupVersionKind{vpc}))
+	assert.Assert(t, pd.Generation() != generation)
+	assert.Equal(t, pd.Exist(bucket), true)
+	assert.Equal(t, pd.Exist(vpc), true)
+	assert.Equal(t, pd.Exist(job), true)
+
+	// the packages of the group versions without any kind are removed
+	schema = openAPISchema()
+	assert.NilError(t, pd.RefreshKubePackagesOf([]metav1.GroupVersionKind{bucket, vpc}))
+	assert.Equal(t, pd.Exist(bucket), false)
+	assert.Equal(t, pd.Exist(vpc), false)
+	assert.Equal(t, pd.Exist(job), true)
+	_, ok := pd.ListPackageKinds()["kube/apps.test.io/v1"]
+	assert.Equal(t, ok, false)
+	bi := build.NewContext().NewInstance("", nil)
+	assert.NilError(t, bi.AddFile("-", `
+import "kube/batch.test.io/v1"
+output: v1.#Job
+`))
+	_, err = pd.ImportPackagesAndBuildInstance(bi)
+	assert.NilError(t, err)
+}
+
+func TestFilterOpenAPISchema(t *testing.T) {
+	openAPISchema := `
+{
+	"paths": {
+		"/apis/apps.test.io/v1/namespaces/{namespace}/buckets": {
+			"post": {"x-kubernetes-group-version-kind": {"group": "apps.test.io", "kind": "Bucket", "version": "v1"}},
+			"parameters": [{"name": "namespace", "in": "path"}]
+		},
+		"/apis/batch.test.io/v1/namespaces/{namespace}/jobs": {
+			"post": {"x-kubernetes-group-version-kind": {"group": "batch.test.io", "kind": "Job", "version": "v1"}}
+		},
+		"/apis/apps.test.io/v1/namespaces/{namespace}/buckets/{name}": {
+			"get": {"x-kubernetes-group-version-kind": {"group": "apps.test.io", "kind": "Bucket", "version": "v1"}}
+		}
+	},
+	"definitions": {
+		"io.test.apps.v1.Bucket": {
+			"properties": {
+				"metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+				"spec": {"properties": {"rules": {"items": {"$ref": "#/definitions/io.test.apps.v1.Rule"}, "type": "array"}}}
+			},
+			"type": "object"
+		},
+		"io.test.apps.v1.Rule": {"properties": {"name": {"type": "string"}}, "type": "object"},
+		"io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {"properties": {"name": {"type": "string"}}, "type": "object"},
+		"io.test.batch.v1.Job": {"properties": {"image": {"$ref": "#/definitions/io.test.batch.v1.Image"}}, "type": "object"},
+		"io.test.batch.v1.Image": {"type": "string"}
+	}
+}
+`
+	filtered, err := filterOpenAPISchema([]byte(openAPISchema), map[string]bool{"apps.test.io/v1": true})
+	assert.NilError(t, err)
+	var doc struct {
+		Paths       map[string]interface{} `json:"paths"`
+		Definitions map[string]interface{} `json:"definitions"`
+	}
+	assert.NilError(t, json.Unmarshal(filtered, &doc))
+	var paths, definitions []string
+	for name := range doc.Paths {
+		paths = append(paths, name)
+	}
+	for name := range doc.Definitions {
+		definitions = append(definitions, name)
+	}
+	sort.Strings(definitions)
+	assert.DeepEqual(t, paths, []string{"/apis/apps.test.io/v1/namespaces/{namespace}/buckets"})
+	assert.DeepEqual(t, definitions, []string{"io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta", "io.test.apps.v1.Bucket", "io.test.apps.v1.Rule"})
+
+	_, err = filterOpenAPISchema([]byte("404 page not found"), map[string]bool{"apps.test.io/v1": true})
+	assert.ErrorContains(t, err, "invalid OpenAPI document")
+}
@@ -17,36 +17,123 @@ limitations under the License.
 package task
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"cuelang.org/go/cue"
 
 	"github.com/oam-dev/kubevela/pkg/builtin"
+	"github.com/oam-dev/kubevela/pkg/builtin/http"
+	"github.com/oam-dev/kubevela/pkg/builtin/kube"
 	"github.com/oam-dev/kubevela/pkg/builtin/registry"
+	"github.com/oam-dev/kubevela/pkg/cue/process"
 )
 
-// Process processing the http task
+const (
+	// TasksFieldName is the field in `processing` holding the named tasks
+	TasksFieldName = "tasks"
+	// TaskTypeFieldName is the field of a task naming the registered runner executing it, e.g. `http` or `kube`
+	TaskTypeFieldName = "type"
+	// TaskResultFieldName is the field of a task filled with its result
+	TaskResultFieldName = "result"
+)
+
+// Process runs the tasks in `processing`.
+// The named tasks in `processing.tasks` are executed by the runners of their `type`, a task runs once
+// the values it refers to are complete, so it can use the results of other tasks, and its result is filled
+// into its `result` field. The legacy `processing.http` and `processing.kube` fill their results into `processing.output`.
 func Process(inst *cue.Instance) (*cue.Instance, error) {
-	taskVal := inst.Lookup("processing", "http")
-	if !taskVal.Exists() {
-		return inst, errors.New("there is no http in processing")
+	tasksVal := inst.Lookup("processing", TasksFieldName)
+	httpVal := inst.Lookup("processing", "http")
+	kubeVal := inst.Lookup("processing", kube.TaskName)
+	if !tasksVal.Exists() && !httpVal.Exists() && !kubeVal.Exists() {
+		return inst, errors.New("there is no tasks, http or kube in processing")
 	}
-	resp, err := exec(taskVal)
-	if err != nil {
-		return nil, fmt.Errorf("fail to exec http task, %w", err)
+	ctx := context.Background()
+	if namespace, err := inst.Lookup("context", process.ContextNamespace).String(); err == nil {
+		ctx = kube.WithNamespace(ctx, namespace)
+	}
+	if tasksVal.Exists() {
+		var err error
+		if inst, err = runTasks(ctx, inst); err != nil {
+			return nil, err
+		}
+	}
+	if httpVal.Exists() {
+		resp, err := exec(ctx, inst.Lookup("processing", "http"))
+		if err != nil {
+			return nil, fmt.Errorf("fail to exec http task, %w", err)
+		}
+		if inst, err = inst.Fill(resp, "processing", "output"); err != nil {
+			return nil, fmt.Errorf("fail to fill output from http, %w", err)
+		}
 	}
+	if kubeVal.Exists() {
+		if _, err := inst.Lookup("context", process.ContextNamespace).String(); err != nil {
+			return nil, fmt.Errorf("fail to get namespace for kube task, %w", err)
+		}
+		got, err := builtin.RunTaskByKey(kube.TaskName, cue.Value{}, &registry.Meta{
+			Context: ctx,
+			Obj:     inst.Lookup("processing", kube.TaskName),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fail to exec kube task, %w", err)
+		}
+		if inst, err = inst.Fill(got, "processing", "output"); err != nil {
+			return nil, fmt.Errorf("fail to fill output from kube, %w", err)
+		}
+	}
+	return inst, nil
+}
 
-	appInst, err := inst.Fill(resp, "processing", "output")
+// runTasks runs the tasks whose values are complete until all of them are done.
+// A task referring to the result of another one is incomplete until that task is done.
+func runTasks(ctx context.Context, inst *cue.Instance) (*cue.Instance, error) {
+	names, err := taskNames(inst.Lookup("processing", TasksFieldName))
 	if err != nil {
-		return nil, fmt.Errorf("fail to fill output from http, %w", err)
+		return nil, fmt.Errorf("invalid tasks in processing, %w", err)
+	}
+	done := make(map[string]bool, len(names))
+	for len(done) < len(names) {
+		var pending []string
+		var incomplete error
+		ran := false
+		for _, name := range names {
+			if done[name] {
+				continue
+			}
+			task := inst.Lookup("processing", TasksFieldName, name)
+			if err := checkComplete(task); err != nil {
+				pending = append(pending, name)
+				incomplete = err
+				continue
+			}
+			typ, err := task.Lookup(TaskTypeFieldName).String()
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s of task %s, %w", TaskTypeFieldName, name, err)
+			}
+			result, err := builtin.RunTaskByKey(typ, cue.Value{}, &registry.Meta{Context: ctx, Obj: task})
+			if err != nil {
+				return nil, fmt.Errorf("fail to exec task %s, %w", name, err)
+			}
+			if inst, err = inst.Fill(result, "processing", TasksFieldName, name, TaskResultFieldName); err != nil {
+				return nil, fmt.Errorf("fail to fill result of task %s, %w", name, err)
+			}
+			done[name] = true
+			ran = true
+		}
+		if !ran {
+			return nil, fmt.Errorf("tasks %s are incomplete or refer to each other, %w", strings.Join(pending, ", "), incomplete)
+		}
 	}
-	return appInst, nil
+	return inst, nil
 }
 
-func exec(v cue.Value) (map[string]interface{}, error) {
-	got, err := builtin.RunTaskByKey("http", cue.Value{}, &registry.Meta{Obj: v})
+func exec(ctx context.Context, v cue.Value) (map[string]interface{}, error) {
+	got, err := builtin.RunTaskByKey(http.TaskName, cue.Value{}, &registry.Meta{Context: ctx, Obj: v})
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +147,41 @@ func exec(v cue.Value) (map[string]interface{}, error) {
 	}
 	resp := make(map[string]interface{})
 	if err := json.Unmarshal([]byte(body), &resp); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("the response body is not a JSON object, use processing.tasks to get it as text, %w", err)
 	}
 	return resp, nil
 }
+
+// taskNames returns the names of the tasks in declaration order.
+func taskNames(tasks cue.Value) ([]string, error) {
+	st, err := tasks.Struct()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := 0; i < st.Len(); i++ {
+		fieldInfo := st.Field(i)
+		if fieldInfo.IsDefinition || fieldInfo.IsHidden || fieldInfo.IsOptional {
+			continue
+		}
+		names = append(names, fieldInfo.Name)
+	}
+	return names, nil
+}
+
+// checkComplete checks whether the fields of a task except its result are concrete.
+func checkComplete(task cue.Value) error {
+	iter, err := task.Fields()
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		if iter.Label() == TaskResultFieldName {
+			continue
+		}
+		if _, err := iter.Value().MarshalJSON(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
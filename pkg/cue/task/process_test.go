@@ -22,12 +22,19 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue"
 	cueJson "cuelang.org/go/pkg/encoding/json"
 	"github.com/bmizerany/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/oam-dev/kubevela/pkg/builtin/kube"
 	velacue "github.com/oam-dev/kubevela/pkg/cue"
 )
 
@@ -103,3 +110,131 @@ func NewMock() *httptest.Server {
 	ts.Start()
 	return ts
 }
+
+const KubeTaskTemplate = `
+context: namespace: "default"
+parameter: {
+  secretName: string
+}
+
+processing: {
+  output: {
+    secret ?: {...}
+  }
+  kube: get: secret: {
+    apiVersion: "v1"
+    kind: "Secret"
+    name: parameter.secretName
+  }
+}
+
+output: {
+  data: processing.output.secret.data.password
+}
+`
+
+func TestProcessKube(t *testing.T) {
+	kube.Register(fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}), kube.DefaultReadableKinds)
+
+	r := cue.Runtime{}
+	taskTemplate, err := r.Compile("", KubeTaskTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskTemplate, _ = taskTemplate.Fill(map[string]interface{}{
+		"secretName": "db",
+	}, velacue.ParameterTag)
+
+	inst, err := Process(taskTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := inst.Lookup("output")
+	data, _ := cueJson.Marshal(output)
+	assert.Equal(t, "{\"data\":\"c2VjcmV0\"}", data)
+}
+
+const TasksTemplate = `
+parameter: {
+  serviceURL: string
+}
+
+processing: tasks: {
+  // config refers to the result of token, so it runs after token
+  config: {
+    type: "http"
+    method: "GET"
+    url: parameter.serviceURL + "/config"
+    request: header: Authorization: "Bearer " + processing.tasks.token.result.json.token
+  }
+  token: {
+    type: "http"
+    method: "POST"
+    url: parameter.serviceURL + "/token"
+    request: header: {}
+    result: json: token: string
+  }
+}
+
+output: {
+  config: processing.tasks.config.result.body
+  version: processing.tasks.config.result.header["X-Version"][0]
+  code: processing.tasks.config.result.statusCode
+}
+`
+
+func TestProcessTasks(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Write([]byte(`{"token":"abc"}`))
+		case "/config":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-Version", "2")
+			w.Write([]byte("replicas=3"))
+		}
+	}))
+	defer s.Close()
+
+	r := cue.Runtime{}
+	taskTemplate, err := r.Compile("", TasksTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskTemplate, _ = taskTemplate.Fill(map[string]interface{}{
+		"serviceURL": s.URL,
+	}, velacue.ParameterTag)
+
+	inst, err := Process(taskTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := inst.Lookup("output")
+	data, _ := cueJson.Marshal(output)
+	assert.Equal(t, "{\"config\":\"replicas=3\",\"version\":\"2\",\"code\":200}", data)
+
+	cyclic, err := r.Compile("", `
+processing: tasks: {
+  a: {
+    type: "http"
+    url: processing.tasks.b.result.body
+  }
+  b: {
+    type: "http"
+    url: processing.tasks.a.result.body
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Process(cyclic)
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "tasks a, b are incomplete or refer to each other"))
+}
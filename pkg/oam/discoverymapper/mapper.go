@@ -139,3 +139,37 @@ func IsNamespacedScope(dm DiscoveryMapper, gk schema.GroupKind) (bool, error) {
 	isNamespaced := restMapping.Scope.Name() == meta.RESTScopeNameNamespace
 	return isNamespaced, nil
 }
+
+// ErrOffline is returned by the lookups of the offline DiscoveryMapper
+var ErrOffline = errors.New("cannot discover the resources of the cluster offline")
+
+var _ DiscoveryMapper = offlineMapper{}
+
+// offlineMapper is the DiscoveryMapper used when no cluster is reachable, e.g. by dry-run,
+// it fails every lookup with ErrOffline since there are no resources to discover.
+type offlineMapper struct{}
+
+// NewOffline creates a DiscoveryMapper working without a cluster
+func NewOffline() DiscoveryMapper {
+	return offlineMapper{}
+}
+
+func (offlineMapper) GetMapper() (meta.RESTMapper, error) {
+	return nil, ErrOffline
+}
+
+func (offlineMapper) Refresh() (meta.RESTMapper, error) {
+	return nil, ErrOffline
+}
+
+func (offlineMapper) RESTMapping(gk schema.GroupKind, version ...string) (*meta.RESTMapping, error) {
+	return nil, errors.WithMessagef(ErrOffline, "cannot map %s", gk.String())
+}
+
+func (offlineMapper) KindsFor(input schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	return nil, errors.WithMessagef(ErrOffline, "cannot get the kinds of %s", input.String())
+}
+
+func (offlineMapper) ResourcesFor(input schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	return schema.GroupVersionResource{}, errors.WithMessagef(ErrOffline, "cannot get the resource of %s", input.String())
+}
@@ -97,9 +97,38 @@ const (
 	// AnnotationWorkflowContext is used to pass in the workflow context marshalled in json format.
 	AnnotationWorkflowContext = "app.oam.dev/workflow-context"
 
+	// AnnotationWorkflowSuspend suspends the workflow of the application if it's true,
+	// the workflow is resumed once it's removed. It's kept across app revisions.
+	AnnotationWorkflowSuspend = "app.oam.dev/workflow-suspend"
+
+	// AnnotationWorkflowTerminate terminates the workflow of the application executed for the app revision in its value
+	AnnotationWorkflowTerminate = "app.oam.dev/workflow-terminate"
+
+	// AnnotationWorkflowRestart is the restart generation of the workflow of the application,
+	// the workflow is executed from scratch once it's bumped.
+	AnnotationWorkflowRestart = "app.oam.dev/workflow-restart"
+
+	// AnnotationWorkflowApprovals records the decisions made on the suspend steps of the workflow of the application
+	AnnotationWorkflowApprovals = "app.oam.dev/workflow-approvals"
+
 	// AnnotationKubeVelaVersion is used to record current KubeVela version
 	AnnotationKubeVelaVersion = "oam.dev/kubevela-version"
 
+	// AnnotationDriftPolicy is the policy on the changes made to the dispatched resources out of KubeVela,
+	// it's report, repair or ignore
+	AnnotationDriftPolicy = "app.oam.dev/drift-policy"
+
+	// AnnotationApplyMode is the way to apply the dispatched resources of the application,
+	// the resources are applied by server-side apply if it's server-side, otherwise by three-way merge
+	AnnotationApplyMode = "app.oam.dev/apply-mode"
+
+	// AnnotationApplyForceConflicts indicates server-side apply takes over the fields owned by others if it's true
+	AnnotationApplyForceConflicts = "app.oam.dev/apply-force-conflicts"
+
+	// AnnotationDeletionPolicy is the deletion policy of a dispatched resource, it's Delete, Orphan or Retain.
+	// Set on the application, it overrides the deletion policies of all components and traits.
+	AnnotationDeletionPolicy = "app.oam.dev/deletion-policy"
+
 	// AnnotationFilterAnnotationKeys is used to filter annotations passed to workload and trait, split by comma
 	AnnotationFilterAnnotationKeys = "filter.oam.dev/annotation-keys"
 
@@ -36,7 +36,11 @@ func NewDryRunCommand(c common.Args, ioStreams cmdutil.IOStreams) *cobra.Command
 		Long:                  "Dry Run an application, and output the K8s resources as result to stdout, only CUE template supported for now",
 		Example:               "kubectl vela dry-run",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			return c.SetConfig()
+			if err := c.SetConfig(); err != nil {
+				// the application can still be rendered offline with the definitions in --definition
+				ioStreams.Infof("No cluster is configured, dry-run offline: %v\n", err)
+			}
+			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			buff, err := cli.DryRunApplication(o, c, namespace)
@@ -18,6 +18,7 @@ package apply
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/oam-dev/kubevela/pkg/oam"
 
@@ -46,6 +47,9 @@ type Applicator interface {
 // nolint: golint
 type ApplyOption func(ctx context.Context, existing, desired runtime.Object) error
 
+// FieldManager is the field manager of the fields applied by server-side apply
+const FieldManager = "kubevela"
+
 // NewAPIApplicator creates an Applicator that applies state to an
 // object or creates the object if not exist.
 func NewAPIApplicator(c client.Client) *APIApplicator {
@@ -56,6 +60,20 @@ func NewAPIApplicator(c client.Client) *APIApplicator {
 	}
 }
 
+// WithServerSideApply returns an APIApplicator applying objects by the server-side apply of Kubernetes with the
+// FieldManager, instead of the three-way merge based on the last-applied configuration annotation. So the fields
+// owned by others, e.g. the replicas scaled by HPA, are kept if they're not applied.
+// Applying a field owned by others fails with a conflict, unless forceConflicts is true to take over the field.
+func (a *APIApplicator) WithServerSideApply(forceConflicts bool) *APIApplicator {
+	return &APIApplicator{
+		creator:        a.creator,
+		patcher:        a.patcher,
+		c:              a.c,
+		serverSide:     true,
+		forceConflicts: forceConflicts,
+	}
+}
+
 type creator interface {
 	createOrGetExisting(context.Context, client.Client, runtime.Object, ...ApplyOption) (runtime.Object, error)
 }
@@ -81,6 +99,9 @@ type APIApplicator struct {
 	creator
 	patcher
 	c client.Client
+
+	serverSide     bool
+	forceConflicts bool
 }
 
 // loggingApply will record a log with desired object applied
@@ -95,6 +116,9 @@ func loggingApply(msg string, desired runtime.Object) {
 
 // Apply applies new state to an object or create it if not exist
 func (a *APIApplicator) Apply(ctx context.Context, desired runtime.Object, ao ...ApplyOption) error {
+	if a.serverSide {
+		return a.serverSideApply(ctx, desired, ao...)
+	}
 	existing, err := a.createOrGetExisting(ctx, a.c, desired, ao...)
 	if err != nil {
 		return err
@@ -115,6 +139,60 @@ func (a *APIApplicator) Apply(ctx context.Context, desired runtime.Object, ao ..
 	return errors.Wrapf(a.c.Patch(ctx, desired, patch), "cannot patch object")
 }
 
+// serverSideApply applies the object by server-side apply, the object is created if it does not exist.
+func (a *APIApplicator) serverSideApply(ctx context.Context, desired runtime.Object, ao ...ApplyOption) error {
+	m, ok := desired.(oam.Object)
+	if !ok {
+		return errors.New("cannot access object metadata")
+	}
+	// server-side apply requires the name
+	if m.GetName() == "" && m.GetGenerateName() != "" {
+		if err := executeApplyOptions(ctx, nil, desired, ao); err != nil {
+			return err
+		}
+		loggingApply("creating object", desired)
+		return errors.Wrap(a.c.Create(ctx, desired), "cannot create object")
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.GetObjectKind().SetGroupVersionKind(desired.GetObjectKind().GroupVersionKind())
+	err := a.c.Get(ctx, types.NamespacedName{Name: m.GetName(), Namespace: m.GetNamespace()}, existing)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, "cannot get object")
+	}
+	var current runtime.Object
+	if err == nil {
+		current = existing
+	}
+	if err := executeApplyOptions(ctx, current, desired, ao); err != nil {
+		return err
+	}
+
+	annots := m.GetAnnotations()
+	if _, ok := annots[oam.AnnotationLastAppliedConfig]; ok {
+		delete(annots, oam.AnnotationLastAppliedConfig)
+		m.SetAnnotations(annots)
+	}
+	m.SetManagedFields(nil)
+	m.SetResourceVersion("")
+	opts := []client.PatchOption{client.FieldOwner(FieldManager)}
+	if a.forceConflicts {
+		opts = append(opts, client.ForceOwnership)
+	}
+	loggingApply("applying object by server-side apply", desired)
+	if err := a.c.Patch(ctx, desired, client.Apply, opts...); err != nil {
+		return errors.Wrap(err, "cannot apply object by server-side apply")
+	}
+
+	// the last-applied configuration left by the three-way merge is stale since the object is applied server-side
+	if _, ok := existing.GetAnnotations()[oam.AnnotationLastAppliedConfig]; ok {
+		patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, oam.AnnotationLastAppliedConfig)
+		return errors.Wrap(a.c.Patch(ctx, desired, client.RawPatch(types.MergePatchType, []byte(patch))),
+			"cannot remove last-applied configuration annotation")
+	}
+	return nil
+}
+
 // createOrGetExisting will create the object if it does not exist
 // or get and return the existing object
 func createOrGetExisting(ctx context.Context, c client.Client, desired runtime.Object, ao ...ApplyOption) (runtime.Object, error) {
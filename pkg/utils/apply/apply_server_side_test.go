@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apply
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/pkg/oam"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+var _ = Describe("Test server-side apply", func() {
+	var (
+		int32_3   = int32(3)
+		ctx       = context.Background()
+		deploy    *appsv1.Deployment
+		deployKey = types.NamespacedName{
+			Name:      "testdeploy-ssa",
+			Namespace: ns,
+		}
+		ssaApplicator      *APIApplicator
+		forceSSAApplicator *APIApplicator
+	)
+
+	testDeployment := func() *appsv1.Deployment {
+		d := basicTestDeployment()
+		d.SetName(deployKey.Name)
+		return d
+	}
+
+	// scale applies the replicas of the deployment by another field manager, e.g. an HPA
+	scale := func(replicas int64) error {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("apps/v1")
+		u.SetKind("Deployment")
+		u.SetName(deployKey.Name)
+		u.SetNamespace(deployKey.Namespace)
+		Expect(unstructured.SetNestedField(u.Object, replicas, "spec", "replicas")).Should(Succeed())
+		return rawClient.Patch(ctx, u, client.Apply, client.FieldOwner("scaler"), client.ForceOwnership)
+	}
+
+	managers := func(d *appsv1.Deployment) []string {
+		var ms []string
+		for _, f := range d.GetManagedFields() {
+			ms = append(ms, f.Manager)
+		}
+		return ms
+	}
+
+	BeforeEach(func() {
+		// the applicators are created after the client is set up in BeforeSuite
+		ssaApplicator = NewAPIApplicator(rawClient).WithServerSideApply(false)
+		forceSSAApplicator = NewAPIApplicator(rawClient).WithServerSideApply(true)
+		deploy = testDeployment()
+	})
+
+	AfterEach(func() {
+		Expect(rawClient.Delete(ctx, testDeployment())).Should(SatisfyAny(Succeed(), &oamutil.NotFoundMatcher{}))
+	})
+
+	It("Test create and update object by server-side apply", func() {
+		Expect(ssaApplicator.Apply(ctx, deploy)).Should(Succeed())
+		result := &appsv1.Deployment{}
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(managers(result)).Should(ContainElement(FieldManager))
+		Expect(result.GetAnnotations()).ShouldNot(HaveKey(oam.AnnotationLastAppliedConfig))
+
+		By("Update the image")
+		deploy = testDeployment()
+		deploy.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+		Expect(ssaApplicator.Apply(ctx, deploy)).Should(Succeed())
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(result.Spec.Template.Spec.Containers[0].Image).Should(Equal("nginx:1.19"))
+	})
+
+	It("Test keep fields owned by others", func() {
+		Expect(ssaApplicator.Apply(ctx, deploy)).Should(Succeed())
+		Expect(scale(5)).Should(Succeed())
+
+		By("Apply without replicas")
+		deploy = testDeployment()
+		deploy.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+		Expect(ssaApplicator.Apply(ctx, deploy)).Should(Succeed())
+		result := &appsv1.Deployment{}
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(*result.Spec.Replicas).Should(Equal(int32(5)))
+		Expect(result.Spec.Template.Spec.Containers[0].Image).Should(Equal("nginx:1.19"))
+	})
+
+	It("Test conflicts with fields owned by others", func() {
+		Expect(ssaApplicator.Apply(ctx, deploy)).Should(Succeed())
+		Expect(scale(5)).Should(Succeed())
+
+		By("Apply replicas without forcing conflicts")
+		deploy = testDeployment()
+		deploy.Spec.Replicas = &int32_3
+		err := ssaApplicator.Apply(ctx, deploy)
+		Expect(err).Should(HaveOccurred())
+		Expect(kerrors.IsConflict(errors.Cause(err))).Should(BeTrue())
+
+		By("Apply replicas with forcing conflicts")
+		deploy = testDeployment()
+		deploy.Spec.Replicas = &int32_3
+		Expect(forceSSAApplicator.Apply(ctx, deploy)).Should(Succeed())
+		result := &appsv1.Deployment{}
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(*result.Spec.Replicas).Should(Equal(int32_3))
+	})
+
+	It("Test switch from three-way merge to server-side apply", func() {
+		Expect(k8sApplicator.Apply(ctx, deploy)).Should(Succeed())
+		result := &appsv1.Deployment{}
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(result.GetAnnotations()).Should(HaveKey(oam.AnnotationLastAppliedConfig))
+
+		deploy = testDeployment()
+		Expect(ssaApplicator.Apply(ctx, deploy)).Should(Succeed())
+		result = &appsv1.Deployment{}
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(result.GetAnnotations()).ShouldNot(HaveKey(oam.AnnotationLastAppliedConfig))
+		Expect(managers(result)).Should(ContainElement(FieldManager))
+	})
+
+	It("Test apply options are executed", func() {
+		var existings []runtime.Object
+		label := func(_ context.Context, existing, desired runtime.Object) error {
+			existings = append(existings, existing)
+			desired.(oam.Object).SetLabels(map[string]string{"applied": "true"})
+			return nil
+		}
+		Expect(ssaApplicator.Apply(ctx, deploy, label)).Should(Succeed())
+		deploy = testDeployment()
+		Expect(ssaApplicator.Apply(ctx, deploy, label)).Should(Succeed())
+		Expect(existings).Should(HaveLen(2))
+		Expect(existings[0]).Should(BeNil())
+		Expect(existings[1]).ShouldNot(BeNil())
+		result := &appsv1.Deployment{}
+		Expect(rawClient.Get(ctx, deployKey, result)).Should(Succeed())
+		Expect(result.GetLabels()).Should(HaveKeyWithValue("applied", "true"))
+
+		By("Apply options fail the apply")
+		deploy = testDeployment()
+		failed := func(_ context.Context, _, _ runtime.Object) error {
+			return errors.New("failed")
+		}
+		Expect(ssaApplicator.Apply(ctx, deploy, failed)).Should(HaveOccurred())
+	})
+})
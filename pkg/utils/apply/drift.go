@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// DetectDrift compares the object with the last-applied configuration recorded by the Applicator, and returns the
+// paths of the fields changed out of the Applicator, e.g. spec.replicas or spec.template.spec.containers[0].image.
+// The fields not in the last-applied configuration, e.g. the ones defaulted by the apiserver, and the status are
+// ignored. It returns nil if the object has no last-applied configuration.
+func DetectDrift(current runtime.Object) ([]string, error) {
+	original, err := getOriginalConfiguration(current)
+	if err != nil || original == nil {
+		return nil, err
+	}
+	var applied map[string]interface{}
+	if err := json.Unmarshal(original, &applied); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal last-applied configuration")
+	}
+	// round trip the object to compare the numbers in the same type
+	data, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	var live map[string]interface{}
+	if err := json.Unmarshal(data, &live); err != nil {
+		return nil, err
+	}
+	delete(applied, "status")
+	if meta, ok := live["metadata"].(map[string]interface{}); ok {
+		if annots, ok := meta["annotations"].(map[string]interface{}); ok {
+			delete(annots, oam.AnnotationLastAppliedConfig)
+		}
+	}
+	return diffPaths("", applied, live), nil
+}
+
+// LastAppliedObject returns the last-applied configuration of the object, applying it repairs the drift.
+func LastAppliedObject(current runtime.Object) (*unstructured.Unstructured, error) {
+	original, err := getOriginalConfiguration(current)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil {
+		return nil, errors.New("object has no last-applied configuration")
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(original); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal last-applied configuration")
+	}
+	return obj, nil
+}
+
+func diffPaths(path string, applied, live interface{}) []string {
+	switch a := applied.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+		keys := make([]string, 0, len(a))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var paths []string
+		for _, k := range keys {
+			paths = append(paths, diffPaths(fieldPath(path, k), a[k], l[k])...)
+		}
+		return paths
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(a) {
+			return []string{path}
+		}
+		var paths []string
+		for i := range a {
+			paths = append(paths, diffPaths(fmt.Sprintf("%s[%d]", path, i), a[i], l[i])...)
+		}
+		return paths
+	default:
+		if !reflect.DeepEqual(applied, live) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+func fieldPath(parent, field string) string {
+	if strings.ContainsAny(field, ".[]") {
+		return fmt.Sprintf("%s[%s]", parent, field)
+	}
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
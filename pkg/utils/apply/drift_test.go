@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDetectDrift(t *testing.T) {
+	applied := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "web",
+			"annotations": map[string]interface{}{"app.oam.dev/owner": "vela"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "main", "image": "nginx:1.20"},
+					},
+				},
+			},
+		},
+	}}
+	if err := addLastAppliedConfigAnnotation(applied); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		mutate    func(obj *unstructured.Unstructured)
+		wantPaths []string
+	}{
+		"NoDrift": {
+			mutate: func(obj *unstructured.Unstructured) {
+				// fields defaulted by the apiserver and the status are ignored
+				_ = unstructured.SetNestedField(obj.Object, "RollingUpdate", "spec", "strategy", "type")
+				_ = unstructured.SetNestedField(obj.Object, int64(2), "status", "replicas")
+			},
+		},
+		"Drifted": {
+			mutate: func(obj *unstructured.Unstructured) {
+				_ = unstructured.SetNestedField(obj.Object, int64(5), "spec", "replicas")
+				_ = unstructured.SetNestedSlice(obj.Object, []interface{}{
+					map[string]interface{}{"name": "main", "image": "nginx:latest"},
+				}, "spec", "template", "spec", "containers")
+				_ = unstructured.SetNestedField(obj.Object, "someone", "metadata", "annotations", "app.oam.dev/owner")
+			},
+			wantPaths: []string{
+				"metadata.annotations[app.oam.dev/owner]",
+				"spec.replicas",
+				"spec.template.spec.containers[0].image",
+			},
+		},
+		"ListChanged": {
+			mutate: func(obj *unstructured.Unstructured) {
+				_ = unstructured.SetNestedSlice(obj.Object, []interface{}{
+					map[string]interface{}{"name": "main", "image": "nginx:1.20"},
+					map[string]interface{}{"name": "debug", "image": "busybox"},
+				}, "spec", "template", "spec", "containers")
+			},
+			wantPaths: []string{"spec.template.spec.containers"},
+		},
+		"FieldRemoved": {
+			mutate: func(obj *unstructured.Unstructured) {
+				unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+			},
+			wantPaths: []string{"spec.replicas"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			live := applied.DeepCopy()
+			tc.mutate(live)
+			paths, err := DetectDrift(live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantPaths, paths); diff != "" {
+				t.Errorf("DetectDrift(...): -want paths, +got paths\n%s", diff)
+			}
+
+			desired, err := LastAppliedObject(live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths, err = DetectDrift(applied)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 0 || desired.GetName() != "web" {
+				t.Errorf("LastAppliedObject(...): got %v, drifted %v", desired, paths)
+			}
+		})
+	}
+
+	paths, err := DetectDrift(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	if err != nil || paths != nil {
+		t.Errorf("DetectDrift(...) of object without last-applied configuration: got %v, %v", paths, err)
+	}
+}
@@ -17,10 +17,16 @@ limitations under the License.
 package common
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
@@ -84,19 +90,56 @@ func (a *Args) GetDiscoveryMapper() (discoverymapper.DiscoveryMapper, error) {
 }
 
 // GetPackageDiscover get PackageDiscover client if exist, create if not exist.
+// The kube packages are loaded offline from the OpenAPI document in the file of $VELA_OPENAPI_SCHEMA,
+// or from the embedded snapshot of the Kubernetes version in $VELA_KUBE_VERSION, if either is set,
+// or if there is no cluster to discover them from, e.g. no kubeconfig or the cluster is unreachable.
 func (a *Args) GetPackageDiscover() (*packages.PackageDiscover, error) {
+	if a.pd != nil {
+		return a.pd, nil
+	}
+	schemaFile, kubeVersion := os.Getenv(packages.OpenAPISchemaFileEnv), os.Getenv(packages.KubeVersionEnv)
+	if schemaFile != "" || kubeVersion != "" {
+		klog.InfoS("Load CUE packages offline as requested", "schemaFile", schemaFile, "kubeVersion", kubeVersion)
+		return a.getOfflinePackageDiscover(schemaFile, kubeVersion)
+	}
 	if a.Config == nil {
 		if err := a.SetConfig(); err != nil {
-			return nil, err
+			klog.InfoS("No cluster is configured, load CUE packages offline", "err", err)
+			return a.getOfflinePackageDiscover("", "")
 		}
 	}
-	if a.pd != nil {
-		return a.pd, nil
-	}
 	pd, err := packages.NewPackageDiscover(a.Config)
 	if err != nil {
+		if isDiscoveryUnavailable(err) {
+			klog.InfoS("CUE packages can't be discovered from the cluster, load them offline", "err", err)
+			return a.getOfflinePackageDiscover("", "")
+		}
 		return nil, fmt.Errorf("failed to create CRD discovery for CUE package client %w", err)
 	}
 	a.pd = pd
 	return pd, nil
 }
+
+func (a *Args) getOfflinePackageDiscover(schemaFile, kubeVersion string) (*packages.PackageDiscover, error) {
+	pd, err := packages.NewOfflinePackageDiscover(schemaFile, kubeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CUE packages offline %w", err)
+	}
+	a.pd = pd
+	return pd, nil
+}
+
+// isDiscoveryUnavailable checks whether the error of discovering the packages is caused by the cluster
+// being unreachable or not serving the OpenAPI document, other errors, e.g. the requester is forbidden,
+// are returned instead of being hidden by the packages loaded offline.
+func isDiscoveryUnavailable(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return apierrors.IsNotFound(err) || apierrors.IsServiceUnavailable(err)
+}
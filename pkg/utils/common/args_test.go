@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+)
+
+const argsTestOpenAPISchema = `
+{
+	"paths": {
+		"/apis/apps/v1/namespaces/{namespace}/deployments": {
+			"post": {
+				"x-kubernetes-group-version-kind": {"group": "apps", "kind": "Deployment", "version": "v1"}
+			}
+		}
+	},
+	"definitions": {
+		"io.k8s.api.apps.v1.Deployment": {
+			"properties": {
+				"apiVersion": {"type": "string"},
+				"kind": {"type": "string"}
+			},
+			"type": "object"
+		}
+	}
+}
+`
+
+func TestGetPackageDiscover(t *testing.T) {
+	requests := 0
+	status := http.StatusForbidden
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	deployment := metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	dir, err := ioutil.TempDir("", "openapi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	schemaFile := filepath.Join(dir, "swagger.json")
+	assert.NoError(t, ioutil.WriteFile(schemaFile, []byte(argsTestOpenAPISchema), 0600))
+
+	// the errors of the cluster other than being unavailable aren't hidden by the packages loaded offline
+	_, err = (&Args{Config: &rest.Config{Host: server.URL}}).GetPackageDiscover()
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "offline")
+	assert.Equal(t, 1, requests)
+
+	// the packages are loaded offline as requested without discovering them from the cluster
+	os.Setenv(packages.OpenAPISchemaFileEnv, schemaFile)
+	defer os.Unsetenv(packages.OpenAPISchemaFileEnv)
+	pd, err := (&Args{Config: &rest.Config{Host: server.URL}}).GetPackageDiscover()
+	assert.NoError(t, err)
+	assert.True(t, pd.Exist(deployment))
+	assert.Equal(t, 1, requests)
+}
+
+func TestIsDiscoveryUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+	_, err := packages.NewPackageDiscover(&rest.Config{Host: server.URL})
+	assert.Error(t, err)
+	assert.True(t, isDiscoveryUnavailable(err))
+
+	for status, expected := range map[int]bool{
+		http.StatusNotFound:           true,
+		http.StatusServiceUnavailable: true,
+		http.StatusForbidden:          false,
+		http.StatusUnauthorized:       false,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		_, err := packages.NewPackageDiscover(&rest.Config{Host: server.URL})
+		server.Close()
+		assert.Error(t, err)
+		assert.Equal(t, expected, isDiscoveryUnavailable(err), "status %d", status)
+	}
+}
@@ -33,6 +33,7 @@ import (
 func Register(mgr manager.Manager, args controller.Args) {
 
 	if args.OAMSpecVer == "v0.3" || args.OAMSpecVer == "all" {
+		application.RegisterMutatingHandler(mgr)
 		application.RegisterValidatingHandler(mgr, args)
 		componentdefinition.RegisterMutatingHandler(mgr, args)
 		componentdefinition.RegisterValidatingHandler(mgr, args)
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+var _ admission.Handler = &MutatingHandler{}
+
+// MutatingHandler handles application
+type MutatingHandler struct {
+	// Decoder decodes objects
+	Decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &MutatingHandler{}
+
+// InjectDecoder injects the decoder into the MutatingHandler
+func (h *MutatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.Decoder = d
+	return nil
+}
+
+// Handle handles admission requests.
+func (h *MutatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	app := &v1beta1.Application{}
+	if err := h.Decoder.Decode(req, app); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var oldApp *v1beta1.Application
+	if req.Operation == admissionv1beta1.Update {
+		oldApp = &v1beta1.Application{}
+		if err := h.Decoder.DecodeRaw(req.AdmissionRequest.OldObject, oldApp); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	if err := StampWorkflowApprovals(app, oldApp, req.UserInfo.Username, metav1.NewTime(time.Now())); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	marshalled, err := json.Marshal(app)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	resp := admission.PatchResponseFromRaw(req.AdmissionRequest.Object.Raw, marshalled)
+	if len(resp.Patches) > 0 {
+		klog.InfoS("admit Application",
+			"namespace", app.Namespace, "name", app.Name, "patches", util.JSONMarshal(resp.Patches))
+	}
+	return resp
+}
+
+// StampWorkflowApprovals records the requester as the approver of the decisions added or changed in the
+// workflow approvals annotation, so the approver can't be given by the requester itself.
+func StampWorkflowApprovals(app, oldApp *v1beta1.Application, user string, now metav1.Time) error {
+	value, ok := app.GetAnnotations()[oam.AnnotationWorkflowApprovals]
+	if !ok {
+		return nil
+	}
+	approvals, err := types.ParseWorkflowApprovals(value)
+	if err != nil {
+		return errors.Wrapf(err, "invalid annotation %s", oam.AnnotationWorkflowApprovals)
+	}
+	oldApprovals := types.WorkflowApprovals{}
+	if oldApp != nil {
+		// an invalid old annotation can only be replaced by the requester, all the decisions are stamped then
+		if parsed, err := types.ParseWorkflowApprovals(oldApp.GetAnnotations()[oam.AnnotationWorkflowApprovals]); err == nil {
+			oldApprovals = parsed
+		}
+	}
+	changed := false
+	for name, approval := range approvals {
+		if old, ok := oldApprovals[name]; ok && apiequality.Semantic.DeepEqual(old, approval) {
+			continue
+		}
+		approval.Approver = user
+		approval.Time = now
+		approvals[name] = approval
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	b, err := json.Marshal(approvals)
+	if err != nil {
+		return err
+	}
+	annotations := app.GetAnnotations()
+	annotations[oam.AnnotationWorkflowApprovals] = string(b)
+	app.SetAnnotations(annotations)
+	return nil
+}
+
+// RegisterMutatingHandler will register application mutation handler to the webhook
+func RegisterMutatingHandler(mgr manager.Manager) {
+	server := mgr.GetWebhookServer()
+	server.Register("/mutating-core-oam-dev-v1beta1-applications", &webhook.Admission{Handler: &MutatingHandler{}})
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package application
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+var _ = Describe("Test Application Mutator", func() {
+	mutatingHandler := &MutatingHandler{}
+
+	BeforeEach(func() {
+		Expect(mutatingHandler.InjectDecoder(decoder)).Should(BeNil())
+	})
+
+	appWithApprovals := func(approvals types.WorkflowApprovals) *v1beta1.Application {
+		app := &v1beta1.Application{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "core.oam.dev/v1beta1", Kind: "Application"},
+			ObjectMeta: metav1.ObjectMeta{Name: "application-sample", Namespace: "default"},
+		}
+		if approvals != nil {
+			b, err := json.Marshal(approvals)
+			Expect(err).Should(BeNil())
+			app.SetAnnotations(map[string]string{oam.AnnotationWorkflowApprovals: string(b)})
+		}
+		return app
+	}
+	approvalsOf := func(app *v1beta1.Application) types.WorkflowApprovals {
+		approvals, err := types.ParseWorkflowApprovals(app.GetAnnotations()[oam.AnnotationWorkflowApprovals])
+		Expect(err).Should(BeNil())
+		return approvals
+	}
+	approval := func(approver, comment string) types.WorkflowApproval {
+		return types.WorkflowApproval{
+			WorkflowStepApproval: common.WorkflowStepApproval{Approved: true, Approver: approver, Comment: comment},
+			AppRevision:          "application-sample-v1",
+		}
+	}
+
+	It("Test stamping the requester on the workflow approvals", func() {
+		now := metav1.NewTime(time.Unix(1600000000, 0))
+
+		By("the approver given by the requester is replaced")
+		app := appWithApprovals(types.WorkflowApprovals{"approve-production": approval("mallory", "LGTM")})
+		Expect(StampWorkflowApprovals(app, nil, "alice", now)).Should(BeNil())
+		stamped := approvalsOf(app)["approve-production"]
+		Expect(stamped.Approver).Should(Equal("alice"))
+		Expect(stamped.Time.Unix()).Should(Equal(now.Unix()))
+		Expect(stamped.Comment).Should(Equal("LGTM"))
+
+		By("the decisions not changed are kept")
+		oldApp := app.DeepCopy()
+		approvals := approvalsOf(app)
+		approvals["approve-staging"] = approval("", "")
+		app = appWithApprovals(approvals)
+		Expect(StampWorkflowApprovals(app, oldApp, "bob", metav1.NewTime(now.Add(time.Hour)))).Should(BeNil())
+		Expect(approvalsOf(app)["approve-production"].Approver).Should(Equal("alice"))
+		Expect(approvalsOf(app)["approve-staging"].Approver).Should(Equal("bob"))
+
+		By("the approver of a kept decision can't be changed")
+		oldApp = app.DeepCopy()
+		approvals = approvalsOf(app)
+		approvals["approve-production"] = approval("carol", "LGTM")
+		app = appWithApprovals(approvals)
+		Expect(StampWorkflowApprovals(app, oldApp, "bob", now)).Should(BeNil())
+		Expect(approvalsOf(app)["approve-production"].Approver).Should(Equal("bob"))
+
+		By("the invalid annotation is rejected")
+		app = appWithApprovals(nil)
+		app.SetAnnotations(map[string]string{oam.AnnotationWorkflowApprovals: "approved"})
+		Expect(StampWorkflowApprovals(app, nil, "alice", now)).ShouldNot(BeNil())
+	})
+
+	It("Test Application Mutator [Patch approver]", func() {
+		raw, err := json.Marshal(appWithApprovals(types.WorkflowApprovals{"approve-production": approval("mallory", "")}))
+		Expect(err).Should(BeNil())
+		req := admission.Request{
+			AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Create,
+				Resource:  metav1.GroupVersionResource{Group: "core.oam.dev", Version: "v1beta1", Resource: "applications"},
+				Object:    runtime.RawExtension{Raw: raw},
+				UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+			},
+		}
+		resp := mutatingHandler.Handle(ctx, req)
+		Expect(resp.Allowed).Should(BeTrue())
+		Expect(resp.Patches).Should(HaveLen(1))
+		Expect(resp.Patches[0].Path).Should(Equal("/metadata/annotations/app.oam.dev~1workflow-approvals"))
+		Expect(resp.Patches[0].Value).Should(ContainSubstring(`"approver":"alice"`))
+	})
+
+	It("Test Application Mutator [Nothing to patch]", func() {
+		raw, err := json.Marshal(appWithApprovals(nil))
+		Expect(err).Should(BeNil())
+		req := admission.Request{
+			AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Create,
+				Resource:  metav1.GroupVersionResource{Group: "core.oam.dev", Version: "v1beta1", Resource: "applications"},
+				Object:    runtime.RawExtension{Raw: raw},
+				UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+			},
+		}
+		resp := mutatingHandler.Handle(ctx, req)
+		Expect(resp.Allowed).Should(BeTrue())
+		Expect(resp.Patches).Should(BeEmpty())
+	})
+})
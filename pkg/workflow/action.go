@@ -0,0 +1,291 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/cue/process"
+)
+
+const (
+	// ActionsFieldName is the name of the struct containing the actions of a workflow step,
+	// the actions are executed one by one in the order they are declared.
+	ActionsFieldName = "actions"
+	// ActionTypeFieldName is the field of an action telling what to do
+	ActionTypeFieldName = "do"
+	// ActionResultFieldName is the field filled with the result of an action, so the following actions can refer to it
+	ActionResultFieldName = "result"
+)
+
+const (
+	// ActionApply applies the resource in `value`, the result is the applied resource
+	ActionApply = "apply"
+	// ActionApplyComponent applies the workload and traits of the component named by `component`,
+	// the result contains the applied `workload` and `traits`
+	ActionApplyComponent = "apply-component"
+	// ActionRead reads the resource identified by `value`, the result is the resource in cluster.
+	// The step keeps running until the resource exists.
+	ActionRead = "read"
+	// ActionWait keeps the step running with `message` until `continue` is true
+	ActionWait = "wait"
+	// ActionFail fails the step with `message`
+	ActionFail = "fail"
+	// ActionStepObject applies the object in `value` and waits for its controller to report
+	// the `workflow-progress` condition, the result is the applied object
+	ActionStepObject = "step-object"
+)
+
+// executeStep runs the actions of a workflow step until one of them doesn't succeed.
+// A template without actions renders its step object in `output` as it used to be.
+func (w *workflow) executeStep(ctx context.Context, step oamcore.WorkflowStep, inst *cue.Instance,
+	wctx *types.WorkflowContext) (*common.WorkflowStepStatus, *stepResult, error) {
+	status := &common.WorkflowStepStatus{
+		Name:  step.Name,
+		Type:  step.Type,
+		Phase: common.WorkflowStepPhaseSucceeded,
+	}
+	actions := inst.Lookup(ActionsFieldName)
+	if !actions.Exists() {
+		output := inst.Lookup(process.OutputFieldName)
+		if !output.Exists() {
+			return nil, nil, errors.Errorf("workflow step %s has neither %s nor %s", step.Name, ActionsFieldName, process.OutputFieldName)
+		}
+		obj, err := decodeObject(output)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "invalid output of workflow step %s", step.Name)
+		}
+		obj.SetName(step.Name)
+		if err := w.runStepObject(ctx, step, obj, status, wctx); err != nil {
+			return nil, nil, err
+		}
+		return status, &stepResult{inst: inst, stepObject: obj}, nil
+	}
+
+	names, err := actionNames(actions)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "invalid actions of workflow step %s", step.Name)
+	}
+	for _, name := range names {
+		action := inst.Lookup(ActionsFieldName, name)
+		do, err := action.Lookup(ActionTypeFieldName).String()
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "workflow step %s: invalid action %s", step.Name, name)
+		}
+		result, err := w.runAction(ctx, step, do, action, status, wctx)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "workflow step %s: run action %s", step.Name, name)
+		}
+		if status.Phase != common.WorkflowStepPhaseSucceeded {
+			return status, &stepResult{inst: inst}, nil
+		}
+		if result == nil {
+			continue
+		}
+		if inst, err = inst.Fill(result, ActionsFieldName, name, ActionResultFieldName); err != nil {
+			return nil, nil, errors.WithMessagef(err, "workflow step %s: fill result of action %s", step.Name, name)
+		}
+	}
+	return status, &stepResult{inst: inst}, nil
+}
+
+func (w *workflow) runAction(ctx context.Context, step oamcore.WorkflowStep, do string, action cue.Value,
+	status *common.WorkflowStepStatus, wctx *types.WorkflowContext) (interface{}, error) {
+	switch do {
+	case ActionApply:
+		obj, err := decodeObject(action.Lookup("value"))
+		if err != nil {
+			return nil, err
+		}
+		w.completeObject(obj)
+		if !w.inAppNamespace(obj, status) {
+			return nil, nil
+		}
+		if err := w.applicator.Apply(ctx, obj); err != nil {
+			return nil, err
+		}
+		return obj.Object, nil
+	case ActionApplyComponent:
+		compName, err := action.Lookup("component").String()
+		if err != nil {
+			return nil, err
+		}
+		if w.applyComponent == nil {
+			return nil, errors.Errorf("cannot apply component %s in this workflow", compName)
+		}
+		workload, traits, err := w.applyComponent(ctx, compName)
+		if err != nil {
+			return nil, err
+		}
+		traitObjs := make([]interface{}, len(traits))
+		for i, t := range traits {
+			traitObjs[i] = t.Object
+		}
+		return map[string]interface{}{
+			"workload": workload.Object,
+			"traits":   traitObjs,
+		}, nil
+	case ActionRead:
+		obj, err := decodeObject(action.Lookup("value"))
+		if err != nil {
+			return nil, err
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(w.app.Namespace)
+		}
+		if !w.inAppNamespace(obj, status) {
+			return nil, nil
+		}
+		if err := w.cli.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, obj); err != nil {
+			if !kerrors.IsNotFound(err) {
+				return nil, err
+			}
+			status.Phase = common.WorkflowStepPhaseRunning
+			status.Message = fmt.Sprintf("waiting for %s %s to be created", obj.GetKind(), obj.GetName())
+			return nil, nil
+		}
+		return obj.Object, nil
+	case ActionWait:
+		cont, err := action.Lookup("continue").Bool()
+		if err != nil {
+			return nil, err
+		}
+		if !cont {
+			status.Phase = common.WorkflowStepPhaseRunning
+			status.Message = actionMessage(action)
+		}
+		return nil, nil
+	case ActionFail:
+		status.Phase = common.WorkflowStepPhaseFailed
+		status.Message = actionMessage(action)
+		return nil, nil
+	case ActionStepObject:
+		obj, err := decodeObject(action.Lookup("value"))
+		if err != nil {
+			return nil, err
+		}
+		if obj.GetName() == "" {
+			obj.SetName(step.Name)
+		}
+		if err := w.runStepObject(ctx, step, obj, status, wctx); err != nil {
+			return nil, err
+		}
+		return obj.Object, nil
+	default:
+		return nil, errors.Errorf("unknown action %q", do)
+	}
+}
+
+// runStepObject applies the step object and syncs the step status from its `workflow-progress` condition.
+func (w *workflow) runStepObject(ctx context.Context, step oamcore.WorkflowStep, obj *unstructured.Unstructured,
+	status *common.WorkflowStepStatus, wctx *types.WorkflowContext) error {
+	obj.SetNamespace(w.app.Namespace)
+	obj.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(w.app, oamcore.ApplicationKindVersionKind),
+	})
+	if err := w.applyWorkflowStep(ctx, obj, wctx); err != nil {
+		return err
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		// the step object of the last run is being deleted, it's created again once it's gone
+		status.Phase = common.WorkflowStepPhaseRunning
+		status.Message = fmt.Sprintf("waiting for the step object %s of the last run to be deleted", obj.GetName())
+		return nil
+	}
+	synced, err := w.syncWorkflowStatus(step, obj)
+	if err != nil {
+		return err
+	}
+	status.Phase = synced.Phase
+	status.Message = synced.Message
+	status.ResourceRef = synced.ResourceRef
+	return nil
+}
+
+// completeObject sets the namespace and owner of the resources applied by workflow steps,
+// so they will be garbage collected together with the application.
+func (w *workflow) completeObject(obj *unstructured.Unstructured) {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(w.app.Namespace)
+	}
+	if len(obj.GetOwnerReferences()) == 0 {
+		obj.SetOwnerReferences([]metav1.OwnerReference{
+			*metav1.NewControllerRef(w.app, oamcore.ApplicationKindVersionKind),
+		})
+	}
+}
+
+// inAppNamespace checks whether the resource read or applied by an action is in the namespace of the application,
+// the step is failed otherwise since the actions run with the permissions of the controller.
+func (w *workflow) inAppNamespace(obj *unstructured.Unstructured, status *common.WorkflowStepStatus) bool {
+	if obj.GetNamespace() == w.app.Namespace {
+		return true
+	}
+	status.Phase = common.WorkflowStepPhaseFailed
+	status.Message = fmt.Sprintf("%s %s is in namespace %s, workflow steps can only access the namespace %s of the application",
+		obj.GetKind(), obj.GetName(), obj.GetNamespace(), w.app.Namespace)
+	return false
+}
+
+func actionNames(actions cue.Value) ([]string, error) {
+	st, err := actions.Struct()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := 0; i < st.Len(); i++ {
+		fieldInfo := st.Field(i)
+		if fieldInfo.IsDefinition || fieldInfo.IsHidden || fieldInfo.IsOptional {
+			continue
+		}
+		names = append(names, fieldInfo.Name)
+	}
+	return names, nil
+}
+
+func actionMessage(action cue.Value) string {
+	msg, err := action.Lookup("message").String()
+	if err != nil {
+		return ""
+	}
+	return msg
+}
+
+// decodeObject converts a CUE value to unstructured object, numbers are decoded as int64 if possible
+func decodeObject(v cue.Value) (*unstructured.Unstructured, error) {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
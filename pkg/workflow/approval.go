@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// stampedApprovals returns the decisions in the workflow approvals annotation of the application,
+// they're refused unless their approvers are stamped by the mutating webhook.
+func (w *workflow) stampedApprovals() (types.WorkflowApprovals, error) {
+	if !w.approvalsStamped {
+		return types.WorkflowApprovals{}, nil
+	}
+	approvals, err := types.ParseWorkflowApprovals(w.app.GetAnnotations()[oam.AnnotationWorkflowApprovals])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid annotation %s", oam.AnnotationWorkflowApprovals)
+	}
+	return approvals, nil
+}
+
+// executeSuspendStep keeps the suspend step running until a decision is made on it in the workflow approvals annotation,
+// the step is succeeded if it's approved, or failed if it's rejected. The decision is recorded in the step status.
+// The step can't be approved or rejected by the annotation if the approvers in it aren't stamped by the webhook.
+func executeSuspendStep(step oamcore.WorkflowStep, last *common.WorkflowStepStatus, approval *common.WorkflowStepApproval, approvalsStamped bool) *common.WorkflowStepStatus {
+	status := &common.WorkflowStepStatus{
+		Name: step.Name,
+		Type: step.Type,
+	}
+	if approval == nil && last != nil && last.Approval != nil {
+		approval = last.Approval.DeepCopy()
+	}
+	if approval == nil {
+		status.Phase = common.WorkflowStepPhaseRunning
+		status.Reason = common.WorkflowStepReasonWaitingForApproval
+		status.Message = "waiting for approval"
+		if !approvalsStamped {
+			status.Message = "waiting for approval, the approvals are refused without the admission webhook of applications stamping the approvers"
+		}
+		return status
+	}
+	status.Approval = approval
+	if status.Approval.Approved {
+		status.Phase = common.WorkflowStepPhaseSucceeded
+		status.Message = withCause(fmt.Sprintf("approved by %s", approverOf(status.Approval)), status.Approval.Comment)
+		return status
+	}
+	status.Phase = common.WorkflowStepPhaseFailed
+	status.Reason = common.WorkflowStepReasonRejected
+	status.Message = withCause(fmt.Sprintf("rejected by %s", approverOf(status.Approval)), status.Approval.Comment)
+	return status
+}
+
+func approverOf(approval *common.WorkflowStepApproval) string {
+	if approval.Approver == "" {
+		return "unknown approver"
+	}
+	return approval.Approver
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/parser"
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	velacue "github.com/oam-dev/kubevela/pkg/cue"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// conditionFieldName is the field which the `if` expression of a step is evaluated to,
+// `if` itself is a keyword of CUE.
+const conditionFieldName = "condition"
+
+// isFinished checks whether the step won't change any more in this execution.
+func isFinished(phase common.WorkflowStepPhase) bool {
+	switch phase {
+	case common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseFailed,
+		common.WorkflowStepPhaseStopped, common.WorkflowStepPhaseSkipped:
+		return true
+	default:
+		return false
+	}
+}
+
+// stepContextFields are the fields which the `if` expression and the notification message can refer to
+var stepContextFields = []string{"context", "app", velacue.ParameterTag, "steps"}
+
+// stepContext returns the workflow context, the application metadata, the step parameter
+// and the statuses of the other steps, keyed by stepContextFields.
+func (w *workflow) stepContext(step oamcore.WorkflowStep, rev string, statuses []common.WorkflowStepStatus) (map[string]interface{}, error) {
+	parameter, err := oamutil.RawExtension2Map(&step.Properties)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid properties of workflow step %s", step.Name)
+	}
+	if parameter == nil {
+		parameter = map[string]interface{}{}
+	}
+	stepStatuses := make(map[string]interface{}, len(statuses))
+	for _, status := range statuses {
+		if status.Name == "" {
+			continue
+		}
+		stepStatuses[status.Name] = map[string]interface{}{
+			"phase":   status.Phase,
+			"reason":  status.Reason,
+			"message": status.Message,
+		}
+	}
+	return map[string]interface{}{
+		"context": map[string]interface{}{
+			"appName":     w.app.Name,
+			"appRevision": rev,
+			"namespace":   w.app.Namespace,
+		},
+		"app": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":        w.app.Name,
+				"namespace":   w.app.Namespace,
+				"labels":      nonNilMap(w.app.Labels),
+				"annotations": nonNilMap(w.app.Annotations),
+			},
+		},
+		velacue.ParameterTag: parameter,
+		"steps":              stepStatuses,
+	}, nil
+}
+
+// parseCondition parses the `if` expression of a step, and returns the names of the steps referred by it.
+func parseCondition(step oamcore.WorkflowStep) (ast.Expr, []string, error) {
+	expr, err := parser.ParseExpr("if", step.If)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "invalid if expression of workflow step %s", step.Name)
+	}
+	var refs []string
+	ast.Walk(expr, func(n ast.Node) bool {
+		var x, sel ast.Node
+		switch v := n.(type) {
+		case *ast.SelectorExpr:
+			x, sel = v.X, v.Sel
+		case *ast.IndexExpr:
+			x, sel = v.X, v.Index
+		default:
+			return true
+		}
+		if ident, ok := x.(*ast.Ident); !ok || ident.Name != "steps" {
+			return true
+		}
+		switch v := sel.(type) {
+		case *ast.Ident:
+			refs = append(refs, v.Name)
+		case *ast.BasicLit:
+			if name, err := strconv.Unquote(v.Value); err == nil {
+				refs = append(refs, name)
+			}
+		}
+		return true
+	}, nil)
+	return expr, refs, nil
+}
+
+// conditionDependencies parses the `if` expressions of the steps, and returns them with the indexes of the steps
+// referred by each of them. The steps referred are executed before the step, so the expression can't refer to
+// the step itself or the steps depending on it, which would never be executed before it.
+func conditionDependencies(steps []oamcore.WorkflowStep, deps [][]int) ([]ast.Expr, [][]int, error) {
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		index[step.Name] = i
+	}
+	exprs := make([]ast.Expr, len(steps))
+	refs := make([][]int, len(steps))
+	for i, step := range steps {
+		if step.If == "" {
+			continue
+		}
+		expr, names, err := parseCondition(step)
+		if err != nil {
+			return nil, nil, err
+		}
+		exprs[i] = expr
+		for _, name := range names {
+			j, ok := index[name]
+			switch {
+			case !ok:
+				return nil, nil, errors.Errorf("if expression of workflow step %s refers to step %s which does not exist", step.Name, name)
+			case j == i:
+				return nil, nil, errors.Errorf("if expression of workflow step %s refers to itself", step.Name)
+			case dependsOn(deps, j, i):
+				return nil, nil, errors.Errorf("if expression of workflow step %s refers to step %s which depends on it", step.Name, name)
+			}
+			if !containsIndex(refs[i], j) {
+				refs[i] = append(refs[i], j)
+			}
+		}
+	}
+	return exprs, refs, nil
+}
+
+// dependsOn checks whether the step i depends on the step j directly or indirectly.
+func dependsOn(deps [][]int, i, j int) bool {
+	visited := make([]bool, len(deps))
+	var visit func(k int) bool
+	visit = func(k int) bool {
+		for _, d := range deps[k] {
+			if d == j {
+				return true
+			}
+			if !visited[d] {
+				visited[d] = true
+				if visit(d) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return visit(i)
+}
+
+// evalCondition evaluates the parsed `if` expression of a step with the step context.
+// The expression is put into the AST rather than the source, so it can't declare any other field.
+func (w *workflow) evalCondition(step oamcore.WorkflowStep, expr ast.Expr, rev string, statuses []common.WorkflowStepStatus) (bool, error) {
+	data, err := w.stepContext(step, rev, statuses)
+	if err != nil {
+		return false, err
+	}
+	file := &ast.File{}
+	for _, name := range stepContextFields {
+		b, err := json.Marshal(data[name])
+		if err != nil {
+			return false, err
+		}
+		value, err := parser.ParseExpr(name, b)
+		if err != nil {
+			return false, err
+		}
+		file.Decls = append(file.Decls, &ast.Field{Label: ast.NewIdent(name), Value: value})
+	}
+	file.Decls = append(file.Decls, &ast.Field{Label: ast.NewIdent(conditionFieldName), Value: expr})
+
+	var r cue.Runtime
+	inst, err := r.CompileFile(file)
+	if err != nil {
+		return false, errors.WithMessagef(err, "invalid if expression of workflow step %s", step.Name)
+	}
+	cond, err := inst.Lookup(conditionFieldName).Bool()
+	if err != nil {
+		return false, errors.WithMessagef(err, "evaluate if expression of workflow step %s", step.Name)
+	}
+	return cond, nil
+}
+
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
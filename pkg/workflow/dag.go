@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+// isDAG checks whether the steps should be executed as a DAG.
+// Steps are executed in array order if none of them declares dependsOn.
+func isDAG(steps []oamcore.WorkflowStep) bool {
+	for _, step := range steps {
+		if len(step.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stepDependencies returns the indexes of the steps which each step depends on.
+// A step also depends on the steps producing its inputs.
+func stepDependencies(steps []oamcore.WorkflowStep) ([][]int, error) {
+	// the statuses, the if expressions and the outputs refer to the steps by their names
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		if _, ok := index[step.Name]; ok {
+			return nil, errors.Errorf("workflow step %q is duplicated", step.Name)
+		}
+		index[step.Name] = i
+	}
+	deps := make([][]int, len(steps))
+	if !isDAG(steps) {
+		for i := 1; i < len(steps); i++ {
+			deps[i] = []int{i - 1}
+		}
+	} else {
+		for i, step := range steps {
+			for _, name := range step.DependsOn {
+				j, ok := index[name]
+				if !ok {
+					return nil, errors.Errorf("workflow step %q depends on step %q which does not exist", step.Name, name)
+				}
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	producers := make(map[string]int)
+	for i, step := range steps {
+		for _, output := range step.Outputs {
+			if _, ok := producers[output.Name]; ok {
+				return nil, errors.Errorf("workflow output %q is duplicated", output.Name)
+			}
+			producers[output.Name] = i
+		}
+	}
+	for i, step := range steps {
+		for _, input := range step.Inputs {
+			j, ok := producers[input.From]
+			if !ok {
+				return nil, errors.Errorf("workflow step %q takes input %q which is not an output of any step", step.Name, input.From)
+			}
+			if j == i {
+				return nil, errors.Errorf("workflow step %q takes its own output %q as input", step.Name, input.From)
+			}
+			if !containsIndex(deps[i], j) {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return deps, nil
+}
+
+func containsIndex(indexes []int, i int) bool {
+	for _, j := range indexes {
+		if j == i {
+			return true
+		}
+	}
+	return false
+}
+
+// sortSteps sorts the steps in topological order and keeps the array order among independent steps,
+// the steps referred by the `if` expression of a step are sorted before it too.
+// An error is returned if the dependencies of the steps form a cycle.
+func sortSteps(steps []oamcore.WorkflowStep, deps, refs [][]int) ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(steps))
+	order := make([]int, 0, len(steps))
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("workflow steps have cyclic dependencies: %s -> %s", strings.Join(path, " -> "), steps[i].Name)
+		}
+		state[i] = visiting
+		path = append(path, steps[i].Name)
+		for _, j := range append(deps[i][:len(deps[i]):len(deps[i])], refs[i]...) {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		order = append(order, i)
+		return nil
+	}
+
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
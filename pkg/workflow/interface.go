@@ -19,16 +19,47 @@ package workflow
 import (
 	"context"
 
+	"cuelang.org/go/cue"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // Workflow is used to execute the workflow steps of Application.
 type Workflow interface {
-	// ExecuteSteps executes the steps of an Application with given steps of rendered resources.
-	// It returns done=true only if all steps are executed and succeeded.
-	ExecuteSteps(ctx context.Context, appRevName string, steps []*unstructured.Unstructured) (done bool, err error)
+	// ExecuteSteps executes the steps of an Application with given steps of evaluated templates.
+	// It returns StateFinished only if no step is running any more.
+	ExecuteSteps(ctx context.Context, appRevName string, steps []*cue.Instance) (State, error)
 }
 
+// ComponentApplier applies the workload and traits of a component in the Application,
+// it returns the applied workload and traits.
+type ComponentApplier func(ctx context.Context, compName string) (*unstructured.Unstructured, []*unstructured.Unstructured, error)
+
+// Option configures how a workflow is executed.
+type Option func(w *workflow)
+
+// WithApprovalsStamped tells whether the approvers in the workflow approvals annotation are stamped by the mutating
+// webhook of applications. The approvals in the annotation are refused if they aren't, since any user able to update
+// the application could write any approver into it.
+func WithApprovalsStamped(stamped bool) Option {
+	return func(w *workflow) {
+		w.approvalsStamped = stamped
+	}
+}
+
+// State is the state of a workflow after its steps are executed.
+type State string
+
+const (
+	// StateExecuting means some steps are still running and the workflow should be checked again shortly.
+	StateExecuting State = "executing"
+	// StateFinished means all steps are finished or blocked by failed steps.
+	StateFinished State = "finished"
+	// StateSuspended means the workflow is suspended and waits to be resumed.
+	StateSuspended State = "suspended"
+	// StateTerminated means the workflow is terminated and waits to be restarted.
+	StateTerminated State = "terminated"
+)
+
 // SucceededMessage is the data json-marshalled into the message of `workflow-progress` condition
 // when its reason is `succeeded`.
 type SucceededMessage struct {
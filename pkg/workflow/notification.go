@@ -0,0 +1,240 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/builtin"
+	velahttp "github.com/oam-dev/kubevela/pkg/builtin/http"
+	"github.com/oam-dev/kubevela/pkg/builtin/registry"
+)
+
+const (
+	// defaultSMTPPort is the submission port of SMTP servers
+	defaultSMTPPort = 587
+	// defaultEmailSubject is the subject of the notification email if it's not specified
+	defaultEmailSubject = "Workflow of application {{.context.appName}}"
+)
+
+// sendMail is replaced in tests
+var sendMail = smtp.SendMail
+
+// notificationSpec is the properties of the built-in notification step
+type notificationSpec struct {
+	// Message is a go template rendered with the same context as the `if` expression,
+	// e.g. `{{.context.appName}} is {{.steps.deploy.phase}}`
+	Message string       `json:"message"`
+	Webhook *webhookSpec `json:"webhook,omitempty"`
+	Slack   *webhookSpec `json:"slack,omitempty"`
+	Email   *emailSpec   `json:"email,omitempty"`
+}
+
+// webhookSpec is a webhook receiving the notification,
+// a generic webhook receives the message with the workflow context, a slack webhook receives the message as `text`
+type webhookSpec struct {
+	URL velahttp.ValueSource `json:"url"`
+}
+
+// emailSpec is the SMTP server and the receivers of the notification email
+type emailSpec struct {
+	Host     string               `json:"host"`
+	Port     int                  `json:"port,omitempty"`
+	Username velahttp.ValueSource `json:"username,omitempty"`
+	Password velahttp.ValueSource `json:"password,omitempty"`
+	From     string               `json:"from"`
+	To       []string             `json:"to"`
+	Subject  string               `json:"subject,omitempty"`
+}
+
+// executeNotificationStep sends the message to the receivers of the step. The notification is sent only once,
+// the step is failed if any receiver is not reached, and it will be sent again if the step is retried.
+func (w *workflow) executeNotificationStep(ctx context.Context, step oamcore.WorkflowStep, rev string,
+	statuses []common.WorkflowStepStatus, last *common.WorkflowStepStatus) (*common.WorkflowStepStatus, error) {
+	if last != nil && last.Phase == common.WorkflowStepPhaseSucceeded {
+		return last.DeepCopy(), nil
+	}
+	spec := &notificationSpec{}
+	if len(step.Properties.Raw) > 0 {
+		if err := json.Unmarshal(step.Properties.Raw, spec); err != nil {
+			return nil, errors.Wrapf(err, "invalid properties of workflow step %s", step.Name)
+		}
+	}
+	if spec.Webhook == nil && spec.Slack == nil && spec.Email == nil {
+		return nil, errors.Errorf("workflow step %s has no receiver of the notification", step.Name)
+	}
+	data, err := w.stepContext(step, rev, statuses)
+	if err != nil {
+		return nil, err
+	}
+	message, err := renderMessage(step.Name, spec.Message, data)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "render message of workflow step %s", step.Name)
+	}
+
+	var failures []string
+	if spec.Webhook != nil {
+		body := map[string]interface{}{
+			"message": message,
+			"context": data["context"],
+			"steps":   data["steps"],
+		}
+		if err := w.postWebhook(ctx, spec.Webhook, body); err != nil {
+			failures = append(failures, "webhook: "+err.Error())
+		}
+	}
+	if spec.Slack != nil {
+		if err := w.postWebhook(ctx, spec.Slack, map[string]interface{}{"text": message}); err != nil {
+			failures = append(failures, "slack: "+err.Error())
+		}
+	}
+	if spec.Email != nil {
+		if err := w.sendEmail(ctx, step.Name, spec.Email, message, data); err != nil {
+			failures = append(failures, "email: "+err.Error())
+		}
+	}
+
+	status := &common.WorkflowStepStatus{
+		Name:    step.Name,
+		Type:    step.Type,
+		Phase:   common.WorkflowStepPhaseSucceeded,
+		Message: "notification is sent",
+	}
+	if len(failures) > 0 {
+		status.Phase = common.WorkflowStepPhaseFailed
+		status.Message = "failed to send notification: " + strings.Join(failures, "; ")
+	}
+	return status, nil
+}
+
+// postWebhook posts the body in json to the webhook by the http runner of builtin tasks.
+// The url is not put in the error since it may contain credentials.
+func (w *workflow) postWebhook(ctx context.Context, hook *webhookSpec, body interface{}) error {
+	hookURL, err := velahttp.ResolveValue(ctx, w.cli, w.app.Namespace, hook.URL)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := json.Marshal(map[string]interface{}{
+		"method": "POST",
+		"url":    hookURL,
+		// the status code is checked below
+		"allowErrorStatus": true,
+		"request": map[string]interface{}{
+			"body":   string(b),
+			"header": map[string]string{"Content-Type": "application/json"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var r cue.Runtime
+	inst, err := r.Compile("-", req)
+	if err != nil {
+		return err
+	}
+	got, err := builtin.RunTaskByKey(velahttp.TaskName, cue.Value{}, &registry.Meta{Context: ctx, Obj: inst.Value()})
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			// only the cause is kept, the url.Error has the url in its message
+			return errors.Errorf("the webhook is not reached: %v", uerr.Err)
+		}
+		return errors.New("the webhook is not reached")
+	}
+	resp, ok := got.(map[string]interface{})
+	if !ok {
+		return errors.New("invalid response of the webhook")
+	}
+	if code, _ := resp["statusCode"].(int); code < 200 || code >= 300 {
+		return errors.Errorf("the webhook responded %d: %v", code, resp["body"])
+	}
+	return nil
+}
+
+func (w *workflow) sendEmail(ctx context.Context, stepName string, spec *emailSpec, message string, data map[string]interface{}) error {
+	if spec.Host == "" || spec.From == "" || len(spec.To) == 0 {
+		return errors.New("host, from and to are required")
+	}
+	username, err := velahttp.ResolveValue(ctx, w.cli, w.app.Namespace, spec.Username)
+	if err != nil {
+		return err
+	}
+	password, err := velahttp.ResolveValue(ctx, w.cli, w.app.Namespace, spec.Password)
+	if err != nil {
+		return err
+	}
+	subjectTmpl := spec.Subject
+	if subjectTmpl == "" {
+		subjectTmpl = defaultEmailSubject
+	}
+	subject, err := renderMessage(stepName, subjectTmpl, data)
+	if err != nil {
+		return err
+	}
+	// the header values must not break the header, otherwise any header could be injected into the email
+	for name, values := range map[string][]string{"from": {spec.From}, "to": spec.To, "subject": {subject}} {
+		for _, value := range values {
+			if strings.ContainsAny(value, "\r\n") {
+				return errors.Errorf("%s must not contain line breaks", name)
+			}
+		}
+	}
+	port := spec.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, spec.Host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		spec.From, strings.Join(spec.To, ", "), subject, message)
+	return sendMail(net.JoinHostPort(spec.Host, strconv.Itoa(port)), auth, spec.From, spec.To, []byte(msg))
+}
+
+func renderMessage(name, text string, data map[string]interface{}) (string, error) {
+	if text == "" {
+		return "", errors.New("message is empty")
+	}
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid template")
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", errors.Wrap(err, "execute template")
+	}
+	return buf.String(), nil
+}
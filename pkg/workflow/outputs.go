@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	velacue "github.com/oam-dev/kubevela/pkg/cue"
+	"github.com/oam-dev/kubevela/pkg/cue/process"
+)
+
+// ConfigMapKeyOutputs is the key in the resource ConfigMap of app revision holding the outputs of workflow steps.
+// Outputs once recorded are not changed, so the steps consume the same inputs when the workflow is executed again.
+const ConfigMapKeyOutputs = "workflow-outputs"
+
+// stepResult is where the outputs of a workflow step are looked up from
+type stepResult struct {
+	// inst is the step template with the results of actions filled
+	inst *cue.Instance
+	// stepObject is the step object applied for a template without actions
+	stepObject *unstructured.Unstructured
+}
+
+// lookup returns the value at the dot separated path.
+func (r *stepResult) lookup(valueFrom string) (interface{}, error) {
+	if r == nil {
+		// the built-in steps have no template to look up
+		return nil, errors.Errorf("%s is not found", valueFrom)
+	}
+	prefix := process.OutputFieldName + "."
+	if r.stepObject != nil && strings.HasPrefix(valueFrom, prefix) {
+		return fieldpath.Pave(r.stepObject.Object).GetValue(strings.TrimPrefix(valueFrom, prefix))
+	}
+	v := r.inst.Lookup(strings.Split(valueFrom, ".")...)
+	if !v.Exists() {
+		return nil, errors.Errorf("%s is not found", valueFrom)
+	}
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "%s is not concrete", valueFrom)
+	}
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// hasInputsOrOutputs checks whether any step exchanges data with others.
+func hasInputsOrOutputs(steps []oamcore.WorkflowStep) bool {
+	for _, step := range steps {
+		if len(step.Inputs) > 0 || len(step.Outputs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fillInputs fills the recorded outputs into the parameter of the step template.
+func fillInputs(inst *cue.Instance, inputs oamcore.StepInputs, outputs map[string]interface{}) (*cue.Instance, error) {
+	for _, input := range inputs {
+		value, ok := outputs[input.From]
+		if !ok {
+			return nil, errors.Errorf("output %s is not recorded", input.From)
+		}
+		path := append([]string{velacue.ParameterTag}, strings.Split(input.ParameterKey, ".")...)
+		var err error
+		if inst, err = inst.Fill(value, path...); err != nil {
+			return nil, errors.WithMessagef(err, "fill output %s into %s", input.From, input.ParameterKey)
+		}
+	}
+	return inst, nil
+}
+
+// recordOutputs records the outputs of a succeeded step which are not recorded yet,
+// it returns true if any output is added.
+func recordOutputs(outputs map[string]interface{}, items oamcore.StepOutputs, result *stepResult) (bool, error) {
+	added := false
+	for _, item := range items {
+		if _, ok := outputs[item.Name]; ok {
+			continue
+		}
+		value, err := result.lookup(item.ValueFrom)
+		if err != nil {
+			return false, errors.WithMessagef(err, "get output %s", item.Name)
+		}
+		outputs[item.Name] = value
+		added = true
+	}
+	return added, nil
+}
+
+func (w *workflow) loadOutputs(ctx context.Context, cmName string) (map[string]interface{}, error) {
+	cm := &corev1.ConfigMap{}
+	if err := w.cli.Get(ctx, client.ObjectKey{Namespace: w.app.Namespace, Name: cmName}, cm); err != nil {
+		return nil, errors.WithMessagef(err, "get resource ConfigMap %s", cmName)
+	}
+	outputs := make(map[string]interface{})
+	if data, ok := cm.Data[ConfigMapKeyOutputs]; ok {
+		if err := json.Unmarshal([]byte(data), &outputs); err != nil {
+			return nil, errors.WithMessagef(err, "invalid workflow outputs in ConfigMap %s", cmName)
+		}
+	}
+	return outputs, nil
+}
+
+func (w *workflow) saveOutputs(ctx context.Context, cmName string, outputs map[string]interface{}) error {
+	cm := &corev1.ConfigMap{}
+	if err := w.cli.Get(ctx, client.ObjectKey{Namespace: w.app.Namespace, Name: cmName}, cm); err != nil {
+		return errors.WithMessagef(err, "get resource ConfigMap %s", cmName)
+	}
+	b, err := json.Marshal(outputs)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[ConfigMapKeyOutputs] = string(b)
+	return errors.WithMessagef(w.cli.Update(ctx, cm), "record workflow outputs in ConfigMap %s", cmName)
+}
+
+// clearOutputs removes the recorded outputs from the resource ConfigMap, so they are recorded again.
+func (w *workflow) clearOutputs(ctx context.Context, cmName string) error {
+	cm := &corev1.ConfigMap{}
+	if err := w.cli.Get(ctx, client.ObjectKey{Namespace: w.app.Namespace, Name: cmName}, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.WithMessagef(err, "get resource ConfigMap %s", cmName)
+	}
+	if _, ok := cm.Data[ConfigMapKeyOutputs]; !ok {
+		return nil
+	}
+	delete(cm.Data, ConfigMapKeyOutputs)
+	return errors.WithMessagef(w.cli.Update(ctx, cm), "clear workflow outputs in ConfigMap %s", cmName)
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+)
+
+// maxBackoff is the upper bound of the backoff between retries
+const maxBackoff = time.Hour
+
+// stepPolicy is the timeout and retry policy of a workflow step
+type stepPolicy struct {
+	timeout    time.Duration
+	backoff    time.Duration
+	maxRetries int
+	recreate   bool
+}
+
+func parseStepPolicy(step oamcore.WorkflowStep) (stepPolicy, error) {
+	policy := stepPolicy{
+		maxRetries: step.MaxRetries,
+		recreate:   step.RecreateOnRetry,
+	}
+	var err error
+	if step.Timeout != "" {
+		if policy.timeout, err = time.ParseDuration(step.Timeout); err != nil {
+			return policy, errors.Wrapf(err, "invalid timeout of workflow step %s", step.Name)
+		}
+	}
+	if step.Backoff != "" {
+		if policy.backoff, err = time.ParseDuration(step.Backoff); err != nil {
+			return policy, errors.Wrapf(err, "invalid backoff of workflow step %s", step.Name)
+		}
+	}
+	return policy, nil
+}
+
+// backoffOf returns the duration to wait before the next attempt, it doubles after every retry.
+func (p stepPolicy) backoffOf(attempts int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// waitingForRetry checks whether the step is waiting for the backoff of its next attempt.
+func waitingForRetry(last *common.WorkflowStepStatus, now time.Time) bool {
+	return last != nil && last.Reason == common.WorkflowStepReasonBackoff &&
+		last.NextRetryTime != nil && now.Before(last.NextRetryTime.Time)
+}
+
+// trackAttempt carries the start time and attempts of the last execution into the status,
+// and applies the timeout and retry policy to it.
+func (p stepPolicy) trackAttempt(status, last *common.WorkflowStepStatus, now time.Time) {
+	switch {
+	case last == nil || last.StartTime == nil:
+		status.StartTime = &metav1.Time{Time: now}
+		status.Attempts = 1
+	case last.NextRetryTime != nil:
+		// the backoff of the last attempt is passed, the next attempt is started now
+		status.StartTime = &metav1.Time{Time: now}
+		status.Attempts = last.Attempts + 1
+	default:
+		status.StartTime = last.StartTime.DeepCopy()
+		status.Attempts = last.Attempts
+	}
+
+	if status.Phase == common.WorkflowStepPhaseRunning && p.timeout > 0 && now.Sub(status.StartTime.Time) > p.timeout {
+		status.Phase = common.WorkflowStepPhaseFailed
+		status.Reason = common.WorkflowStepReasonTimeout
+		status.Message = fmt.Sprintf("step is timed out after %s", p.timeout)
+	}
+	if status.Phase != common.WorkflowStepPhaseFailed || p.maxRetries == 0 {
+		return
+	}
+	if status.Attempts > p.maxRetries {
+		if status.Reason != common.WorkflowStepReasonTimeout {
+			status.Reason = common.WorkflowStepReasonRetryLimitExceeded
+		}
+		status.Message = withCause(fmt.Sprintf("failed after %d attempts", status.Attempts), status.Message)
+		return
+	}
+	backoff := p.backoffOf(status.Attempts)
+	status.Phase = common.WorkflowStepPhaseRunning
+	status.Reason = common.WorkflowStepReasonBackoff
+	status.Message = withCause(fmt.Sprintf("attempt %d failed, retry in %s", status.Attempts, backoff), status.Message)
+	status.NextRetryTime = &metav1.Time{Time: now.Add(backoff)}
+}
+
+func withCause(message, cause string) string {
+	if cause == "" {
+		return message
+	}
+	return message + ": " + cause
+}
+
+// resetStepObject removes the workflow progress condition of the step object of the last attempt,
+// so the failure is not reported again and its controller starts the next attempt on the same object.
+func (w *workflow) resetStepObject(ctx context.Context, ref *common.WorkflowStepStatus) error {
+	if ref.ResourceRef.Kind == "" || ref.ResourceRef.Name == "" {
+		return nil
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.ResourceRef.APIVersion)
+	obj.SetKind(ref.ResourceRef.Kind)
+	if err := w.cli.Get(ctx, client.ObjectKey{Namespace: w.app.Namespace, Name: ref.ResourceRef.Name}, obj); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.WithMessagef(err, "get step object %s of workflow step %s", ref.ResourceRef.Name, ref.Name)
+	}
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return errors.WithMessagef(err, "invalid conditions of step object %s", ref.ResourceRef.Name)
+	}
+	kept := make([]interface{}, 0, len(conditions))
+	for _, cond := range conditions {
+		if c, ok := cond.(map[string]interface{}); ok && c["type"] == CondTypeWorkflowFinish {
+			continue
+		}
+		kept = append(kept, cond)
+	}
+	if len(kept) == len(conditions) {
+		return nil
+	}
+	if err := unstructured.SetNestedSlice(obj.Object, kept, "status", "conditions"); err != nil {
+		return err
+	}
+	return errors.WithMessagef(w.cli.Status().Update(ctx, obj), "reset step object %s of workflow step %s", ref.ResourceRef.Name, ref.Name)
+}
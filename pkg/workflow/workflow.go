@@ -19,11 +19,19 @@ package workflow
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"cuelang.org/go/cue"
 	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
@@ -35,67 +43,341 @@ import (
 )
 
 type workflow struct {
-	app        *oamcore.Application
-	applicator apply.Applicator
+	app            *oamcore.Application
+	wf             *oamcore.Workflow
+	cli            client.Client
+	applicator     apply.Applicator
+	applyComponent ComponentApplier
+
+	approvalsStamped bool
 }
 
-// NewWorkflow returns a Workflow implementation.
-func NewWorkflow(app *oamcore.Application, applicator apply.Applicator) Workflow {
-	return &workflow{
-		app:        app,
-		applicator: applicator,
+// NewWorkflow returns a Workflow implementation executing the workflow of the application,
+// wf is the workflow given by the application with its template resolved.
+func NewWorkflow(app *oamcore.Application, wf *oamcore.Workflow, cli client.Client, applicator apply.Applicator, applyComponent ComponentApplier, opts ...Option) Workflow {
+	w := &workflow{
+		app:            app,
+		wf:             wf,
+		cli:            cli,
+		applicator:     applicator,
+		applyComponent: applyComponent,
+	}
+	for _, opt := range opts {
+		opt(w)
 	}
+	return w
 }
 
-func (w *workflow) ExecuteSteps(ctx context.Context, rev string, objects []*unstructured.Unstructured) (bool, error) {
-	if w.app.Spec.Workflow == nil {
-		return true, nil
+func (w *workflow) ExecuteSteps(ctx context.Context, rev string, instances []*cue.Instance) (State, error) {
+	if w.wf == nil {
+		return StateFinished, nil
 	}
 
-	steps := w.app.Spec.Workflow.Steps
+	steps := w.wf.Steps
 	if len(steps) == 0 {
-		return true, nil
+		return StateFinished, nil
+	}
+
+	deps, err := stepDependencies(steps)
+	if err != nil {
+		return StateExecuting, err
+	}
+	conditions, refs, err := conditionDependencies(steps, deps)
+	if err != nil {
+		return StateExecuting, err
+	}
+	order, err := sortSteps(steps, deps, refs)
+	if err != nil {
+		return StateExecuting, err
+	}
+
+	restart, err := restartGenerationOf(w.app)
+	if err != nil {
+		return StateExecuting, err
+	}
+	now := time.Now()
+	wfStatus := w.app.Status.Workflow
+	if wfStatus == nil || wfStatus.AppRevision != rev || wfStatus.RestartGeneration != restart {
+		if wfStatus != nil && wfStatus.AppRevision == rev {
+			// the workflow is restarted, nothing done by the last run is kept
+			if err := w.cleanupLastRun(ctx, wfStatus, rev); err != nil {
+				return StateExecuting, err
+			}
+		}
+		wfStatus = &common.WorkflowStatus{
+			AppRevision:       rev,
+			RestartGeneration: restart,
+			StartTime:         &metav1.Time{Time: now},
+		}
+		w.app.Status.Workflow = wfStatus
+	}
+	// the operations of users are only read from the annotations, so they are never lost by the status updates
+	annotations := w.app.GetAnnotations()
+	wfStatus.Suspend = annotations[oam.AnnotationWorkflowSuspend] == "true"
+	wfStatus.Terminated = wfStatus.Terminated || annotations[oam.AnnotationWorkflowTerminate] == rev
+	if wfStatus.Terminated {
+		w.app.Status.Phase = common.ApplicationWorkflowTerminated
+		stopUnfinishedSteps(wfStatus, now)
+		if wfStatus.EndTime == nil {
+			wfStatus.EndTime = &metav1.Time{Time: now}
+		}
+		return StateTerminated, nil
+	}
+	if wfStatus.Suspend {
+		w.app.Status.Phase = common.ApplicationWorkflowSuspending
+		return StateSuspended, nil
 	}
 
 	w.app.Status.Phase = common.ApplicationRunningWorkflow
 
-	w.app.Status.Workflow = &common.WorkflowStatus{
-		Steps: []common.WorkflowStepStatus{},
-	}
-	for i, step := range steps {
-		obj := objects[i].DeepCopy()
-		obj.SetName(step.Name)
-		obj.SetNamespace(w.app.Namespace)
-		obj.SetOwnerReferences([]metav1.OwnerReference{
-			*metav1.NewControllerRef(w.app, oamcore.ApplicationKindVersionKind),
-		})
-		err := w.applyWorkflowStep(ctx, obj, &types.WorkflowContext{
-			AppName:       w.app.Name,
-			AppRevision:   rev,
-			WorkflowIndex: i,
-			ResourceConfigMap: corev1.LocalObjectReference{
-				Name: rev,
-			},
-		})
-		if err != nil {
-			return false, err
+	approvals, err := w.stampedApprovals()
+	if err != nil {
+		return StateExecuting, err
+	}
+
+	var outputs map[string]interface{}
+	if hasInputsOrOutputs(steps) {
+		if outputs, err = w.loadOutputs(ctx, rev); err != nil {
+			return StateExecuting, err
 		}
+	}
+	outputsAdded := false
+
+	lastStatuses := make(map[string]*common.WorkflowStepStatus, len(wfStatus.Steps))
+	for i := range wfStatus.Steps {
+		lastStatuses[wfStatus.Steps[i].Name] = &wfStatus.Steps[i]
+	}
 
-		status, err := w.syncWorkflowStatus(step, obj)
+	statuses := make([]common.WorkflowStepStatus, len(steps))
+	// settled marks the steps which won't change any more in this execution,
+	// including the pending ones blocked by failed steps.
+	settled := make([]bool, len(steps))
+	state := StateFinished
+	for _, i := range order {
+		step := steps[i]
+		last := lastStatuses[step.Name]
+		if step.If == "" {
+			if blocked := blockingSteps(statuses, deps[i]); len(blocked) > 0 {
+				statuses[i] = common.WorkflowStepStatus{
+					Name:    step.Name,
+					Type:    step.Type,
+					Phase:   common.WorkflowStepPhasePending,
+					Message: fmt.Sprintf("waiting for steps %s to succeed", strings.Join(blocked, ", ")),
+				}
+				settled[i] = len(unsettledSteps(statuses, settled, deps[i])) == 0
+				continue
+			}
+		} else {
+			// a conditional step runs once its dependencies are finished, so it can handle their failures
+			if unsettled := unsettledSteps(statuses, settled, deps[i]); len(unsettled) > 0 {
+				statuses[i] = common.WorkflowStepStatus{
+					Name:    step.Name,
+					Type:    step.Type,
+					Phase:   common.WorkflowStepPhasePending,
+					Message: fmt.Sprintf("waiting for steps %s to finish", strings.Join(unsettled, ", ")),
+				}
+				continue
+			}
+			// the steps referred are sorted before, the pending ones which will never run are settled too
+			if unsettled := unsettledSteps(statuses, settled, refs[i]); len(unsettled) > 0 {
+				statuses[i] = common.WorkflowStepStatus{
+					Name:    step.Name,
+					Type:    step.Type,
+					Phase:   common.WorkflowStepPhasePending,
+					Message: fmt.Sprintf("waiting for steps %s referred by the if expression to finish", strings.Join(unsettled, ", ")),
+				}
+				continue
+			}
+			ok, err := w.evalCondition(step, conditions[i], rev, statuses)
+			if err != nil {
+				return StateExecuting, err
+			}
+			if !ok {
+				statuses[i] = common.WorkflowStepStatus{
+					Name:    step.Name,
+					Type:    step.Type,
+					Phase:   common.WorkflowStepPhaseSkipped,
+					Message: "the if condition is false",
+				}
+				trackEndTime(&statuses[i], last, now)
+				settled[i] = true
+				continue
+			}
+		}
+
+		if last != nil && last.Phase == common.WorkflowStepPhaseFailed {
+			// the failed step won't be executed again until the workflow is restarted
+			statuses[i] = *last
+			settled[i] = true
+			continue
+		}
+		if waitingForRetry(last, now) {
+			statuses[i] = *last
+			state = StateExecuting
+			continue
+		}
+		policy, err := parseStepPolicy(step)
 		if err != nil {
-			return false, err
+			return StateExecuting, err
 		}
 
-		w.app.Status.Workflow.Steps = append(w.app.Status.Workflow.Steps, *status)
-		switch status.Phase {
-		case common.WorkflowStepPhaseSucceeded: // This one is done. Continue
-		case common.WorkflowStepPhaseRunning: // Need to retry shortly.
-			return false, nil
+		var status *common.WorkflowStepStatus
+		var result *stepResult
+		switch {
+		case types.IsSuspendStep(step.Type):
+			// a rejected step is not retried, the decision won't change
+			policy.maxRetries = 0
+			status = executeSuspendStep(step, last, approvals.Of(step.Name, wfStatus), w.approvalsStamped)
+		case step.Type == types.WorkflowStepTypeNotification:
+			if status, err = w.executeNotificationStep(ctx, step, rev, statuses, last); err != nil {
+				return StateExecuting, err
+			}
 		default:
-			return true, nil
+			inst := instances[i]
+			if len(step.Inputs) > 0 {
+				if inst, err = fillInputs(inst, step.Inputs, outputs); err != nil {
+					return StateExecuting, errors.WithMessagef(err, "fill inputs of workflow step %s", step.Name)
+				}
+			}
+			status, result, err = w.executeStep(ctx, step, inst, &types.WorkflowContext{
+				AppName:       w.app.Name,
+				AppRevision:   rev,
+				WorkflowIndex: i,
+				ResourceConfigMap: corev1.LocalObjectReference{
+					Name: rev,
+				},
+			})
+			if err != nil {
+				return StateExecuting, err
+			}
+		}
+		policy.trackAttempt(status, last, now)
+		trackEndTime(status, last, now)
+		if status.Reason == common.WorkflowStepReasonBackoff {
+			// the failure of the last attempt must not be reported again by the step object
+			retry := w.resetStepObject
+			if policy.recreate {
+				retry = w.deleteStepObject
+			}
+			if err := retry(ctx, status); err != nil {
+				return StateExecuting, err
+			}
+		}
+		statuses[i] = *status
+		settled[i] = isFinished(status.Phase)
+		if status.Phase == common.WorkflowStepPhaseRunning {
+			// Need to retry shortly.
+			state = StateExecuting
+		}
+		if status.Phase == common.WorkflowStepPhaseSucceeded && len(step.Outputs) > 0 {
+			added, err := recordOutputs(outputs, step.Outputs, result)
+			if err != nil {
+				return StateExecuting, errors.WithMessagef(err, "workflow step %s", step.Name)
+			}
+			outputsAdded = outputsAdded || added
+		}
+	}
+	if outputsAdded {
+		if err := w.saveOutputs(ctx, rev, outputs); err != nil {
+			return StateExecuting, err
+		}
+	}
+
+	wfStatus.Steps = statuses
+	// the workflow is finished if no step is running, the pending steps
+	// left are blocked by failed or stopped steps and will never run.
+	if state != StateFinished {
+		wfStatus.EndTime = nil
+	} else if wfStatus.EndTime == nil {
+		wfStatus.EndTime = &metav1.Time{Time: now}
+	}
+	return state, nil
+}
+
+// restartGenerationOf returns the restart generation of the workflow set by the workflow restart annotation.
+func restartGenerationOf(app *oamcore.Application) (int64, error) {
+	value, ok := app.GetAnnotations()[oam.AnnotationWorkflowRestart]
+	if !ok || value == "" {
+		return 0, nil
+	}
+	restart, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid annotation %s", oam.AnnotationWorkflowRestart)
+	}
+	return restart, nil
+}
+
+// cleanupLastRun deletes the step objects and the outputs of the last run, so the steps are executed from scratch.
+func (w *workflow) cleanupLastRun(ctx context.Context, wfStatus *common.WorkflowStatus, rev string) error {
+	for i := range wfStatus.Steps {
+		if err := w.deleteStepObject(ctx, &wfStatus.Steps[i]); err != nil {
+			return err
+		}
+	}
+	return w.clearOutputs(ctx, rev)
+}
+
+// deleteStepObject deletes the step object of a workflow step, so it will be created again.
+func (w *workflow) deleteStepObject(ctx context.Context, ref *common.WorkflowStepStatus) error {
+	if ref.ResourceRef.Kind == "" || ref.ResourceRef.Name == "" {
+		return nil
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.ResourceRef.APIVersion)
+	obj.SetKind(ref.ResourceRef.Kind)
+	obj.SetName(ref.ResourceRef.Name)
+	obj.SetNamespace(w.app.Namespace)
+	if err := w.cli.Delete(ctx, obj); err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithMessagef(err, "delete step object %s of workflow step %s", ref.ResourceRef.Name, ref.Name)
+	}
+	return nil
+}
+
+// stopUnfinishedSteps marks the running and pending steps of a terminated workflow as stopped.
+func stopUnfinishedSteps(wfStatus *common.WorkflowStatus, now time.Time) {
+	for i, status := range wfStatus.Steps {
+		if status.Phase == common.WorkflowStepPhaseRunning || status.Phase == common.WorkflowStepPhasePending {
+			wfStatus.Steps[i].Phase = common.WorkflowStepPhaseStopped
+			wfStatus.Steps[i].Message = "workflow is terminated"
+			wfStatus.Steps[i].EndTime = &metav1.Time{Time: now}
+			wfStatus.Steps[i].NextRetryTime = nil
+		}
+	}
+}
+
+// trackEndTime keeps the time when the step is finished in the status, it's left empty while the step is unfinished.
+func trackEndTime(status, last *common.WorkflowStepStatus, now time.Time) {
+	if !isFinished(status.Phase) {
+		return
+	}
+	if last != nil && last.Phase == status.Phase && last.EndTime != nil {
+		status.EndTime = last.EndTime.DeepCopy()
+		return
+	}
+	status.EndTime = &metav1.Time{Time: now}
+}
+
+// blockingSteps returns the names of the dependencies which are not succeeded yet.
+// A skipped dependency doesn't block the steps depending on it.
+func blockingSteps(statuses []common.WorkflowStepStatus, deps []int) []string {
+	var blocked []string
+	for _, j := range deps {
+		if statuses[j].Phase != common.WorkflowStepPhaseSucceeded && statuses[j].Phase != common.WorkflowStepPhaseSkipped {
+			blocked = append(blocked, statuses[j].Name)
+		}
+	}
+	return blocked
+}
+
+// unsettledSteps returns the names of the dependencies which may still change in this execution.
+func unsettledSteps(statuses []common.WorkflowStepStatus, settled []bool, deps []int) []string {
+	var unsettled []string
+	for _, j := range deps {
+		if !settled[j] {
+			unsettled = append(unsettled, statuses[j].Name)
 		}
 	}
-	return true, nil // all steps done
+	return unsettled
 }
 
 func (w *workflow) applyWorkflowStep(ctx context.Context, obj *unstructured.Unstructured, wctx *types.WorkflowContext) error {
@@ -168,6 +450,7 @@ func (w *workflow) syncWorkflowStatus(step oamcore.WorkflowStep, obj *unstructur
 		}
 	case CondReasonFailed:
 		status.Phase = common.WorkflowStepPhaseFailed
+		status.Message = cond.Message
 	case CondReasonStopped:
 		status.Phase = common.WorkflowStepPhaseStopped
 	default:
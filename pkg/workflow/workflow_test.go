@@ -19,14 +19,29 @@ package workflow
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
 	"testing"
+	"time"
 
+	"cuelang.org/go/cue"
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	oamcore "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/utils/apply"
 )
 
@@ -115,8 +130,8 @@ func TestExecuteSteps(t *testing.T) {
 	}
 
 	type want struct {
-		done bool
-		err  error
+		state State
+		err   error
 	}
 
 	testcases := []struct {
@@ -128,62 +143,1265 @@ func TestExecuteSteps(t *testing.T) {
 		desc: "zero steps should return true",
 		app:  zerostepApp.DeepCopy(),
 		want: want{
-			done: true,
+			state: StateFinished,
 		},
 	}, {
 		desc:  "one succeeded step should return true",
 		app:   onestepApp.DeepCopy(),
 		steps: []*unstructured.Unstructured{succeededStep.DeepCopy()},
 		want: want{
-			done: true,
+			state: StateFinished,
 		},
 	}, {
 		desc:  "one succeeded step with unmatched generation should return false",
 		app:   onestepApp.DeepCopy(),
 		steps: []*unstructured.Unstructured{succeededStepUnmatchedGen.DeepCopy()},
 		want: want{
-			done: false,
+			state: StateExecuting,
 		},
 	}, {
 		desc:  "one running step should return false",
 		app:   onestepApp.DeepCopy(),
 		steps: []*unstructured.Unstructured{runningStep.DeepCopy()},
 		want: want{
-			done: false,
+			state: StateExecuting,
 		},
 	}, {
 		desc:  "one stopped step should return true",
 		app:   onestepApp.DeepCopy(),
 		steps: []*unstructured.Unstructured{stoppedStep.DeepCopy()},
 		want: want{
-			done: true,
+			state: StateFinished,
 		},
 	}, {
 		desc:  "one succeeded step and one running step should return false",
 		app:   twostepsApp.DeepCopy(),
 		steps: []*unstructured.Unstructured{succeededStep.DeepCopy(), runningStep.DeepCopy()},
 		want: want{
-			done: false,
+			state: StateExecuting,
 		},
 	}}
 	for _, tc := range testcases {
 		t.Logf("%s", tc.desc)
-		done, err := NewWorkflow(tc.app, mockApplicator()).ExecuteSteps(context.Background(), "app-v1", tc.steps)
+		state, err := NewWorkflow(tc.app, tc.app.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v1", stepInstances(t, tc.steps))
 		if err != nil {
 			assert.Equal(t, tc.want.err, err)
 			continue
 		}
-		assert.Equal(t, tc.want.done, done)
+		assert.Equal(t, tc.want.state, state)
 	}
+
+	t.Log("the steps resolved from the workflow template are executed")
+	templateApp := zerostepApp.DeepCopy()
+	templateApp.Spec.Workflow = &oamcore.Workflow{Ref: "template"}
+	state, err := NewWorkflow(templateApp, onestepApp.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateExecuting, state)
+	assert.Equal(t, &oamcore.Workflow{Ref: "template"}, templateApp.Spec.Workflow)
+}
+
+func TestExecuteStepsDAG(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "database",
+					Type: "test",
+				}, {
+					Name: "cache",
+					Type: "test",
+				}, {
+					Name:      "web",
+					Type:      "test",
+					DependsOn: []string{"database", "cache"},
+				}},
+			},
+		},
+	}
+
+	succeededMessage, err := json.Marshal(&SucceededMessage{})
+	assert.NoError(t, err)
+	succeededStep := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{
+					"type":    CondTypeWorkflowFinish,
+					"reason":  CondReasonSucceeded,
+					"message": string(succeededMessage),
+					"status":  CondStatusTrue,
+				}},
+			},
+		},
+	}
+	failedStep := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{
+					"type":   CondTypeWorkflowFinish,
+					"reason": CondReasonFailed,
+					"status": CondStatusTrue,
+				}},
+			},
+		},
+	}
+	runningStep := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	testcases := map[string]struct {
+		app    *oamcore.Application
+		steps  []*unstructured.Unstructured
+		state  State
+		phases []common.WorkflowStepPhase
+		err    string
+	}{
+		"independent steps run at the same time": {
+			app:    app.DeepCopy(),
+			steps:  []*unstructured.Unstructured{runningStep.DeepCopy(), succeededStep.DeepCopy(), succeededStep.DeepCopy()},
+			state:  StateExecuting,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseRunning, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhasePending},
+		},
+		"step runs after all dependencies succeeded": {
+			app:    app.DeepCopy(),
+			steps:  []*unstructured.Unstructured{succeededStep.DeepCopy(), succeededStep.DeepCopy(), succeededStep.DeepCopy()},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded},
+		},
+		"step blocked by failed dependency": {
+			app:    app.DeepCopy(),
+			steps:  []*unstructured.Unstructured{failedStep.DeepCopy(), succeededStep.DeepCopy(), succeededStep.DeepCopy()},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseFailed, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhasePending},
+		},
+		"cyclic dependencies": {
+			app: func() *oamcore.Application {
+				a := app.DeepCopy()
+				a.Spec.Workflow.Steps[0].DependsOn = []string{"web"}
+				return a
+			}(),
+			steps: []*unstructured.Unstructured{succeededStep.DeepCopy(), succeededStep.DeepCopy(), succeededStep.DeepCopy()},
+			err:   "workflow steps have cyclic dependencies: database -> web -> database",
+		},
+		"dependency not found": {
+			app: func() *oamcore.Application {
+				a := app.DeepCopy()
+				a.Spec.Workflow.Steps[2].DependsOn = []string{"queue"}
+				return a
+			}(),
+			steps: []*unstructured.Unstructured{succeededStep.DeepCopy(), succeededStep.DeepCopy(), succeededStep.DeepCopy()},
+			err:   `workflow step "web" depends on step "queue" which does not exist`,
+		},
+		"duplicated step names in sequential workflow": {
+			app: func() *oamcore.Application {
+				a := app.DeepCopy()
+				a.Spec.Workflow.Steps[1].Name = "database"
+				a.Spec.Workflow.Steps[2].DependsOn = nil
+				return a
+			}(),
+			steps: []*unstructured.Unstructured{succeededStep.DeepCopy(), succeededStep.DeepCopy(), succeededStep.DeepCopy()},
+			err:   `workflow step "database" is duplicated`,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			state, err := NewWorkflow(tc.app, tc.app.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v1", stepInstances(t, tc.steps))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.state, state)
+			var phases []common.WorkflowStepPhase
+			for _, status := range tc.app.Status.Workflow.Steps {
+				phases = append(phases, status.Phase)
+			}
+			assert.Equal(t, tc.phases, phases)
+		})
+	}
+}
+
+func TestSuspendAndTerminate(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "test",
+					Type: "test",
+				}},
+			},
+		},
+	}
+	runningStep := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	suspended := app.DeepCopy()
+	suspended.SetAnnotations(map[string]string{oam.AnnotationWorkflowSuspend: "true"})
+	suspended.Status.Workflow = &common.WorkflowStatus{
+		AppRevision: "app-v1",
+	}
+	state, err := NewWorkflow(suspended, suspended.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateSuspended, state)
+	assert.Equal(t, common.ApplicationWorkflowSuspending, suspended.Status.Phase)
+	assert.True(t, suspended.Status.Workflow.Suspend)
+
+	// the suspend is kept when a new app revision starts a new workflow
+	state, err = NewWorkflow(suspended, suspended.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v2", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateSuspended, state)
+	assert.Equal(t, "app-v2", suspended.Status.Workflow.AppRevision)
+	assert.True(t, suspended.Status.Workflow.Suspend)
+
+	// the workflow is resumed once the annotation is removed
+	suspended.SetAnnotations(nil)
+	state, err = NewWorkflow(suspended, suspended.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v2", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateExecuting, state)
+	assert.False(t, suspended.Status.Workflow.Suspend)
+
+	terminated := app.DeepCopy()
+	terminated.SetAnnotations(map[string]string{oam.AnnotationWorkflowTerminate: "app-v1"})
+	terminated.Status.Workflow = &common.WorkflowStatus{
+		AppRevision: "app-v1",
+		Steps: []common.WorkflowStepStatus{{
+			Name:  "test",
+			Type:  "test",
+			Phase: common.WorkflowStepPhaseRunning,
+		}},
+	}
+	state, err = NewWorkflow(terminated, terminated.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateTerminated, state)
+	assert.Equal(t, common.ApplicationWorkflowTerminated, terminated.Status.Phase)
+	assert.True(t, terminated.Status.Workflow.Terminated)
+	assert.Equal(t, common.WorkflowStepPhaseStopped, terminated.Status.Workflow.Steps[0].Phase)
+
+	// a new app revision starts a new workflow
+	state, err = NewWorkflow(terminated, terminated.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v2", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateExecuting, state)
+	assert.Equal(t, "app-v2", terminated.Status.Workflow.AppRevision)
+	assert.False(t, terminated.Status.Workflow.Terminated)
+	assert.Equal(t, common.WorkflowStepPhaseRunning, terminated.Status.Workflow.Steps[0].Phase)
+}
+
+func TestRestartWorkflow(t *testing.T) {
+	ctx := context.Background()
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "test",
+			Annotations: map[string]string{oam.AnnotationWorkflowRestart: "1"},
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "deploy",
+					Type: "test",
+				}},
+			},
+		},
+		Status: common.AppStatus{
+			Workflow: &common.WorkflowStatus{
+				AppRevision: "app-v1",
+				Terminated:  true,
+				Steps: []common.WorkflowStepStatus{{
+					Name:  "deploy",
+					Type:  "test",
+					Phase: common.WorkflowStepPhaseSucceeded,
+					ResourceRef: runtimev1alpha1.TypedReference{
+						APIVersion: "v1",
+						Kind:       "ConfigMap",
+						Name:       "deploy",
+					},
+				}},
+			},
+		},
+	}
+	stepObject := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: "test"}}
+	cli := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-v1", Namespace: "test"},
+			Data: map[string]string{ConfigMapKeyOutputs: `{"dbHost":"db-1"}`}},
+		stepObject.DeepCopy(),
+	)
+	runningStep := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	state, err := NewWorkflow(app, app.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateExecuting, state)
+	assert.Equal(t, int64(1), app.Status.Workflow.RestartGeneration)
+	assert.False(t, app.Status.Workflow.Terminated)
+	assert.Equal(t, common.WorkflowStepPhaseRunning, app.Status.Workflow.Steps[0].Phase)
+	// the step object and the outputs of the last run are removed
+	err = cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "deploy"}, &corev1.ConfigMap{})
+	assert.True(t, kerrors.IsNotFound(err))
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "app-v1"}, cm))
+	assert.NotContains(t, cm.Data, ConfigMapKeyOutputs)
+
+	// the workflow is not restarted again until the annotation is changed
+	assert.NoError(t, cli.Create(ctx, stepObject.DeepCopy()))
+	_, err = NewWorkflow(app, app.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.NoError(t, err)
+	assert.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "deploy"}, &corev1.ConfigMap{}))
+
+	app.SetAnnotations(map[string]string{oam.AnnotationWorkflowRestart: "next"})
+	_, err = NewWorkflow(app, app.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+	assert.EqualError(t, err, `invalid annotation app.oam.dev/workflow-restart: strconv.ParseInt: parsing "next": invalid syntax`)
+}
+
+func TestExecuteStepActions(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "deploy",
+					Type: "deploy",
+				}},
+			},
+		},
+	}
+	readAndWait := `
+parameter: replicas: 3
+actions: {
+	read: {
+		do: "read"
+		value: {
+			apiVersion: "v1"
+			kind:       "ConfigMap"
+			metadata: name: "db-conn"
+		}
+	}
+	wait: {
+		do:       "wait"
+		continue: read.result.data.ready == "true"
+		message:  "waiting for database"
+	}
+	apply: {
+		do: "apply"
+		value: {
+			apiVersion: "v1"
+			kind:       "ConfigMap"
+			metadata: name: "web"
+			data: {
+				replicas: "\(parameter.replicas)"
+				host:     read.result.data.host
+			}
+		}
+	}
+}
+`
+	dbConn := func(ready string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-conn", Namespace: "test"},
+			Data:       map[string]string{"ready": ready, "host": "db.test"},
+		}
+	}
+
+	testcases := map[string]struct {
+		template       string
+		objs           []runtime.Object
+		applyComponent ComponentApplier
+		phase          common.WorkflowStepPhase
+		message        string
+		applied        []string
+	}{
+		"read resource not found": {
+			template: readAndWait,
+			phase:    common.WorkflowStepPhaseRunning,
+			message:  "waiting for ConfigMap db-conn to be created",
+		},
+		"wait until condition holds": {
+			template: readAndWait,
+			objs:     []runtime.Object{dbConn("false")},
+			phase:    common.WorkflowStepPhaseRunning,
+			message:  "waiting for database",
+		},
+		"apply with results of previous actions": {
+			template: readAndWait,
+			objs:     []runtime.Object{dbConn("true")},
+			phase:    common.WorkflowStepPhaseSucceeded,
+			applied:  []string{`{"host":"db.test","replicas":"3"}`},
+		},
+		"read resource in other namespace": {
+			template: `
+actions: read: {
+	do: "read"
+	value: {
+		apiVersion: "v1"
+		kind:       "Secret"
+		metadata: {
+			name:      "admin-token"
+			namespace: "kube-system"
+		}
+	}
+}
+`,
+			objs:    []runtime.Object{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "admin-token", Namespace: "kube-system"}}},
+			phase:   common.WorkflowStepPhaseFailed,
+			message: "Secret admin-token is in namespace kube-system, workflow steps can only access the namespace test of the application",
+		},
+		"apply resource in other namespace": {
+			template: `
+actions: apply: {
+	do: "apply"
+	value: {
+		apiVersion: "v1"
+		kind:       "ConfigMap"
+		metadata: {
+			name:      "web"
+			namespace: "default"
+		}
+		data: replicas: "3"
+	}
+}
+`,
+			phase:   common.WorkflowStepPhaseFailed,
+			message: "ConfigMap web is in namespace default, workflow steps can only access the namespace test of the application",
+		},
+		"fail with message": {
+			template: `
+actions: fail: {
+	do:      "fail"
+	message: "not supported"
+}
+`,
+			phase:   common.WorkflowStepPhaseFailed,
+			message: "not supported",
+		},
+		"apply component": {
+			template: `
+actions: {
+	deploy: {
+		do:        "apply-component"
+		component: "web"
+	}
+	wait: {
+		do:       "wait"
+		continue: deploy.result.workload.status.readyReplicas == 1
+	}
+}
+`,
+			applyComponent: func(ctx context.Context, compName string) (*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+				return &unstructured.Unstructured{Object: map[string]interface{}{
+					"metadata": map[string]interface{}{"name": compName},
+					"status":   map[string]interface{}{"readyReplicas": int64(1)},
+				}}, nil, nil
+			},
+			phase: common.WorkflowStepPhaseSucceeded,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			var r cue.Runtime
+			inst, err := r.Compile("-", tc.template)
+			assert.NoError(t, err)
+			testApp := app.DeepCopy()
+			applicator := &testmockApplicator{}
+			cli := fake.NewFakeClientWithScheme(scheme.Scheme, tc.objs...)
+			state, err := NewWorkflow(testApp, testApp.Spec.Workflow, cli, applicator, tc.applyComponent).ExecuteSteps(context.Background(), "app-v1", []*cue.Instance{inst})
+			assert.NoError(t, err)
+			if tc.phase == common.WorkflowStepPhaseRunning {
+				assert.Equal(t, StateExecuting, state)
+			} else {
+				assert.Equal(t, StateFinished, state)
+			}
+			status := testApp.Status.Workflow.Steps[0]
+			assert.Equal(t, tc.phase, status.Phase)
+			assert.Equal(t, tc.message, status.Message)
+			var applied []string
+			for _, obj := range applicator.applied {
+				data, err := json.Marshal(obj.(*unstructured.Unstructured).Object["data"])
+				assert.NoError(t, err)
+				applied = append(applied, string(data))
+			}
+			assert.Equal(t, tc.applied, applied)
+		})
+	}
+}
+
+func TestStepInputsAndOutputs(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "read-db",
+					Type: "read-config",
+					Outputs: oamcore.StepOutputs{{
+						Name:      "dbHost",
+						ValueFrom: "actions.read.result.data.host",
+					}},
+				}, {
+					Name: "deploy-web",
+					Type: "apply-config",
+					Inputs: oamcore.StepInputs{{
+						From:         "dbHost",
+						ParameterKey: "data.host",
+					}},
+				}},
+			},
+		},
+	}
+	deployWeb := `
+parameter: data: [string]: string
+actions: apply: {
+	do: "apply"
+	value: {
+		apiVersion: "v1"
+		kind:       "ConfigMap"
+		metadata: name: "web"
+		data: parameter.data
+	}
+}
+`
+	readDB := `
+actions: read: {
+	do: "read"
+	value: {
+		apiVersion: "v1"
+		kind:       "ConfigMap"
+		metadata: name: "db-conn"
+	}
+}
+`
+	compile := func(templates ...string) []*cue.Instance {
+		var instances []*cue.Instance
+		for _, template := range templates {
+			var r cue.Runtime
+			inst, err := r.Compile("-", template)
+			assert.NoError(t, err)
+			instances = append(instances, inst)
+		}
+		return instances
+	}
+	ctx := context.Background()
+	cli := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-v1", Namespace: "test"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "db-conn", Namespace: "test"}, Data: map[string]string{"host": "db-1"}},
+	)
+
+	applicator := &testmockApplicator{}
+	state, err := NewWorkflow(app.DeepCopy(), app.Spec.Workflow, cli, applicator, nil).ExecuteSteps(ctx, "app-v1", compile(readDB, deployWeb))
+	assert.NoError(t, err)
+	assert.Equal(t, StateFinished, state)
+	assert.Equal(t, 1, len(applicator.applied))
+	assert.Equal(t, map[string]interface{}{"host": "db-1"}, applicator.applied[0].(*unstructured.Unstructured).Object["data"])
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "app-v1"}, cm))
+	assert.Equal(t, `{"dbHost":"db-1"}`, cm.Data[ConfigMapKeyOutputs])
+
+	// the recorded outputs are used when the workflow is executed again
+	dbConn := &corev1.ConfigMap{}
+	assert.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "db-conn"}, dbConn))
+	dbConn.Data["host"] = "db-2"
+	assert.NoError(t, cli.Update(ctx, dbConn))
+	applicator = &testmockApplicator{}
+	_, err = NewWorkflow(app.DeepCopy(), app.Spec.Workflow, cli, applicator, nil).ExecuteSteps(ctx, "app-v1", compile(readDB, deployWeb))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"host": "db-1"}, applicator.applied[0].(*unstructured.Unstructured).Object["data"])
+
+	unknownInput := app.DeepCopy()
+	unknownInput.Spec.Workflow.Steps[1].Inputs[0].From = "cacheHost"
+	_, err = NewWorkflow(unknownInput, unknownInput.Spec.Workflow, cli, applicator, nil).ExecuteSteps(ctx, "app-v1", compile(readDB, deployWeb))
+	assert.EqualError(t, err, `workflow step "deploy-web" takes input "cacheHost" which is not an output of any step`)
+
+	// the step taking inputs implicitly depends on the step producing them
+	reversed := app.DeepCopy()
+	reversed.Spec.Workflow.Steps = []oamcore.WorkflowStep{app.Spec.Workflow.Steps[1], app.Spec.Workflow.Steps[0]}
+	_, err = NewWorkflow(reversed, reversed.Spec.Workflow, cli, applicator, nil).ExecuteSteps(ctx, "app-v1", compile(deployWeb, readDB))
+	assert.EqualError(t, err, "workflow steps have cyclic dependencies: deploy-web -> read-db -> deploy-web")
+}
+
+func TestStepTimeoutAndRetry(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "test",
+					Type: "test",
+				}},
+			},
+		},
+	}
+	runningStep := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+	}}
+	failedStep := runningStep.DeepCopy()
+	failedStep.Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{map[string]interface{}{
+			"type":    CondTypeWorkflowFinish,
+			"reason":  CondReasonFailed,
+			"message": "image not found",
+			"status":  CondStatusTrue,
+		}},
+	}
+	ctx := context.Background()
+
+	t.Run("step is failed once timed out", func(t *testing.T) {
+		timeoutApp := app.DeepCopy()
+		timeoutApp.Spec.Workflow.Steps[0].Timeout = "1m"
+		timeoutApp.Status.Workflow = &common.WorkflowStatus{
+			AppRevision: "app-v1",
+			Steps: []common.WorkflowStepStatus{{
+				Name:      "test",
+				Type:      "test",
+				Phase:     common.WorkflowStepPhaseRunning,
+				StartTime: &metav1.Time{Time: time.Now().Add(-2 * time.Minute)},
+				Attempts:  1,
+			}},
+		}
+		state, err := NewWorkflow(timeoutApp, timeoutApp.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep.DeepCopy()}))
+		assert.NoError(t, err)
+		assert.Equal(t, StateFinished, state)
+		status := timeoutApp.Status.Workflow.Steps[0]
+		assert.Equal(t, common.WorkflowStepPhaseFailed, status.Phase)
+		assert.Equal(t, common.WorkflowStepReasonTimeout, status.Reason)
+		assert.Equal(t, "step is timed out after 1m0s", status.Message)
+	})
+
+	t.Run("failed step is retried after backoff", func(t *testing.T) {
+		retryApp := app.DeepCopy()
+		retryApp.Spec.Workflow.Steps[0].MaxRetries = 1
+		retryApp.Spec.Workflow.Steps[0].Backoff = "1m"
+		retryApp.Spec.Workflow.Steps[0].RecreateOnRetry = true
+		cli := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}})
+
+		state, err := NewWorkflow(retryApp, retryApp.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{failedStep.DeepCopy()}))
+		assert.NoError(t, err)
+		assert.Equal(t, StateExecuting, state)
+		status := retryApp.Status.Workflow.Steps[0]
+		assert.Equal(t, common.WorkflowStepPhaseRunning, status.Phase)
+		assert.Equal(t, common.WorkflowStepReasonBackoff, status.Reason)
+		assert.Equal(t, 1, status.Attempts)
+		assert.True(t, status.NextRetryTime.After(time.Now()))
+		// the start time is kept as the start of the failed attempt
+		assert.False(t, status.StartTime.After(time.Now()))
+		// the step object is deleted to be created again in the next attempt
+		err = cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "test"}, &corev1.ConfigMap{})
+		assert.True(t, kerrors.IsNotFound(err))
+
+		// the step is not executed until the backoff is passed
+		applicator := &testmockApplicator{}
+		state, err = NewWorkflow(retryApp, retryApp.Spec.Workflow, cli, applicator, nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{failedStep.DeepCopy()}))
+		assert.NoError(t, err)
+		assert.Equal(t, StateExecuting, state)
+		assert.Empty(t, applicator.applied)
+
+		retryApp.Status.Workflow.Steps[0].NextRetryTime = &metav1.Time{Time: time.Now().Add(-time.Second)}
+		state, err = NewWorkflow(retryApp, retryApp.Spec.Workflow, cli, applicator, nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{failedStep.DeepCopy()}))
+		assert.NoError(t, err)
+		assert.Equal(t, StateFinished, state)
+		assert.Equal(t, 1, len(applicator.applied))
+		status = retryApp.Status.Workflow.Steps[0]
+		assert.Equal(t, 2, status.Attempts)
+		assert.Nil(t, status.NextRetryTime)
+		assert.Equal(t, common.WorkflowStepPhaseFailed, status.Phase)
+		assert.Equal(t, common.WorkflowStepReasonRetryLimitExceeded, status.Reason)
+		assert.Equal(t, "failed after 2 attempts: image not found", status.Message)
+	})
+
+	t.Run("failed step is retried on the same step object", func(t *testing.T) {
+		retryApp := app.DeepCopy()
+		retryApp.Spec.Workflow.Steps[0].MaxRetries = 1
+		stepObject := failedStep.DeepCopy()
+		stepObject.SetName("test")
+		stepObject.SetNamespace("test")
+		cli := fake.NewFakeClientWithScheme(scheme.Scheme, stepObject)
+
+		state, err := NewWorkflow(retryApp, retryApp.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{failedStep.DeepCopy()}))
+		assert.NoError(t, err)
+		assert.Equal(t, StateExecuting, state)
+		status := retryApp.Status.Workflow.Steps[0]
+		assert.Equal(t, common.WorkflowStepReasonBackoff, status.Reason)
+		// the failure is removed from the step object kept for the next attempt
+		got := &unstructured.Unstructured{}
+		got.SetAPIVersion("v1")
+		got.SetKind("ConfigMap")
+		assert.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "test", Name: "test"}, got))
+		conditions, _, err := unstructured.NestedSlice(got.Object, "status", "conditions")
+		assert.NoError(t, err)
+		assert.Empty(t, conditions)
+
+		succeededStep := runningStep.DeepCopy()
+		succeededStep.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{
+				"type":    CondTypeWorkflowFinish,
+				"reason":  CondReasonSucceeded,
+				"message": `{"observedGeneration":0}`,
+				"status":  CondStatusTrue,
+			}},
+		}
+		state, err = NewWorkflow(retryApp, retryApp.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep}))
+		assert.NoError(t, err)
+		assert.Equal(t, StateFinished, state)
+		status = retryApp.Status.Workflow.Steps[0]
+		assert.Equal(t, common.WorkflowStepPhaseSucceeded, status.Phase)
+		assert.Equal(t, 2, status.Attempts)
+		assert.Empty(t, status.Reason)
+	})
+
+	t.Run("invalid backoff", func(t *testing.T) {
+		invalidApp := app.DeepCopy()
+		invalidApp.Spec.Workflow.Steps[0].Backoff = "1x"
+		_, err := NewWorkflow(invalidApp, invalidApp.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{failedStep.DeepCopy()}))
+		assert.Error(t, err)
+	})
+}
+
+func TestStepBackoff(t *testing.T) {
+	policy := stepPolicy{backoff: 10 * time.Second}
+	assert.Equal(t, 10*time.Second, policy.backoffOf(1))
+	assert.Equal(t, 40*time.Second, policy.backoffOf(3))
+	assert.Equal(t, maxBackoff, policy.backoffOf(20))
+	assert.Equal(t, time.Duration(0), stepPolicy{}.backoffOf(3))
+}
+
+func TestConditionalSteps(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+			Labels:    map[string]string{"env": "test"},
+		},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "deploy",
+					Type: "deploy",
+				}, {
+					Name:      "notify-on-failure",
+					Type:      "notify",
+					DependsOn: []string{"deploy"},
+					If:        `steps.deploy.phase == "failed"`,
+				}, {
+					Name:      "cleanup",
+					Type:      "cleanup",
+					DependsOn: []string{"deploy"},
+				}},
+			},
+		},
+	}
+	succeededMessage, err := json.Marshal(&SucceededMessage{})
+	assert.NoError(t, err)
+	stepWithReason := func(reason string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{
+					"type":    CondTypeWorkflowFinish,
+					"reason":  reason,
+					"message": string(succeededMessage),
+					"status":  CondStatusTrue,
+				}},
+			},
+		}}
+	}
+	succeededStep := stepWithReason(CondReasonSucceeded)
+	failedStep := stepWithReason(CondReasonFailed)
+	runningStep := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	testcases := map[string]struct {
+		modify func(app *oamcore.Application)
+		steps  []*unstructured.Unstructured
+		state  State
+		phases []common.WorkflowStepPhase
+		err    string
+	}{
+		"skip the failure path": {
+			steps:  []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSkipped, common.WorkflowStepPhaseSucceeded},
+		},
+		"run the failure path": {
+			steps:  []*unstructured.Unstructured{failedStep, succeededStep, succeededStep},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseFailed, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhasePending},
+		},
+		"wait for dependencies to finish": {
+			steps:  []*unstructured.Unstructured{runningStep, succeededStep, succeededStep},
+			state:  StateExecuting,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseRunning, common.WorkflowStepPhasePending, common.WorkflowStepPhasePending},
+		},
+		"condition on labels": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `app.metadata.labels.env == "prod"`
+			},
+			steps:  []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSkipped, common.WorkflowStepPhaseSucceeded},
+		},
+		"condition on parameter": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `parameter.channel != _|_ && context.appName == "test"`
+				app.Spec.Workflow.Steps[1].Properties = runtime.RawExtension{Raw: []byte(`{"channel":"#ops"}`)}
+			},
+			steps:  []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded},
+		},
+		"invalid condition": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `steps.deploy.phase ==`
+			},
+			steps: []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			err:   "invalid if expression of workflow step notify-on-failure",
+		},
+		"condition declaring other fields": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = "true\nsteps: deploy: phase: \"failed\""
+			},
+			steps: []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			err:   "invalid if expression of workflow step notify-on-failure",
+		},
+		"condition on a step not finished yet": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `steps["cleanup"].phase == "failed"`
+			},
+			steps:  []*unstructured.Unstructured{succeededStep, succeededStep, runningStep},
+			state:  StateExecuting,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhasePending, common.WorkflowStepPhaseRunning},
+		},
+		"condition on a step which will never run": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `steps["cleanup"].phase == "pending"`
+			},
+			steps:  []*unstructured.Unstructured{failedStep, succeededStep, succeededStep},
+			state:  StateFinished,
+			phases: []common.WorkflowStepPhase{common.WorkflowStepPhaseFailed, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhasePending},
+		},
+		"condition on a step depending on it": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `steps.cleanup.phase == "failed"`
+				app.Spec.Workflow.Steps[2].DependsOn = []string{"notify-on-failure"}
+			},
+			steps: []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			err:   "if expression of workflow step notify-on-failure refers to step cleanup which depends on it",
+		},
+		"condition on the step itself": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `steps["notify-on-failure"].phase == "failed"`
+			},
+			steps: []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			err:   "if expression of workflow step notify-on-failure refers to itself",
+		},
+		"condition on a step which does not exist": {
+			modify: func(app *oamcore.Application) {
+				app.Spec.Workflow.Steps[1].If = `steps.rollback.phase == "failed"`
+			},
+			steps: []*unstructured.Unstructured{succeededStep, succeededStep, succeededStep},
+			err:   "if expression of workflow step notify-on-failure refers to step rollback which does not exist",
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			testApp := app.DeepCopy()
+			if tc.modify != nil {
+				tc.modify(testApp)
+			}
+			state, err := NewWorkflow(testApp, testApp.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(context.Background(), "app-v1", stepInstances(t, tc.steps))
+			if tc.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.state, state)
+			var phases []common.WorkflowStepPhase
+			for _, status := range testApp.Status.Workflow.Steps {
+				phases = append(phases, status.Phase)
+			}
+			assert.Equal(t, tc.phases, phases)
+		})
+	}
+}
+
+func TestSuspendStep(t *testing.T) {
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "deploy-staging",
+					Type: "deploy",
+				}, {
+					Name:    "approve-production",
+					Type:    "suspend",
+					Timeout: "1h",
+				}, {
+					Name: "deploy-production",
+					Type: "deploy",
+				}},
+			},
+		},
+	}
+	succeededMessage, err := json.Marshal(&SucceededMessage{})
+	assert.NoError(t, err)
+	succeededStep := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{
+				"type":    CondTypeWorkflowFinish,
+				"reason":  CondReasonSucceeded,
+				"message": string(succeededMessage),
+				"status":  CondStatusTrue,
+			}},
+		},
+	}}
+	approvals := func(rev string, approval common.WorkflowStepApproval) string {
+		b, err := json.Marshal(types.WorkflowApprovals{"approve-production": {WorkflowStepApproval: approval, AppRevision: rev}})
+		assert.NoError(t, err)
+		return string(b)
+	}
+	waiting := func(startTime time.Time, approval *common.WorkflowStepApproval) *common.WorkflowStatus {
+		return &common.WorkflowStatus{
+			AppRevision: "app-v1",
+			Steps: []common.WorkflowStepStatus{{
+				Name:  "deploy-staging",
+				Phase: common.WorkflowStepPhaseSucceeded,
+			}, {
+				Name:      "approve-production",
+				Phase:     common.WorkflowStepPhaseRunning,
+				Reason:    common.WorkflowStepReasonWaitingForApproval,
+				StartTime: &metav1.Time{Time: startTime},
+				Attempts:  1,
+				Approval:  approval,
+			}},
+		}
+	}
+
+	testcases := map[string]struct {
+		status    *common.WorkflowStatus
+		approvals string
+		unstamped bool
+		state     State
+		phases    []common.WorkflowStepPhase
+		reason    string
+		message   string
+		approval  *common.WorkflowStepApproval
+	}{
+		"wait for approval": {
+			state:   StateExecuting,
+			phases:  []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseRunning, common.WorkflowStepPhasePending},
+			reason:  common.WorkflowStepReasonWaitingForApproval,
+			message: "waiting for approval",
+		},
+		"approved": {
+			status:    waiting(time.Now(), nil),
+			approvals: approvals("app-v1", common.WorkflowStepApproval{Approved: true, Approver: "alice"}),
+			state:     StateFinished,
+			phases:    []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded},
+			message:   "approved by alice",
+			approval:  &common.WorkflowStepApproval{Approved: true, Approver: "alice"},
+		},
+		"rejected": {
+			status:    waiting(time.Now(), nil),
+			approvals: approvals("app-v1", common.WorkflowStepApproval{Approved: false, Approver: "alice", Comment: "not now"}),
+			state:     StateFinished,
+			phases:    []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseFailed, common.WorkflowStepPhasePending},
+			reason:    common.WorkflowStepReasonRejected,
+			message:   "rejected by alice: not now",
+			approval:  &common.WorkflowStepApproval{Approved: false, Approver: "alice", Comment: "not now"},
+		},
+		"approval of another run is ignored": {
+			status:    waiting(time.Now(), nil),
+			approvals: approvals("app-v0", common.WorkflowStepApproval{Approved: true, Approver: "alice"}),
+			state:     StateExecuting,
+			phases:    []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseRunning, common.WorkflowStepPhasePending},
+			reason:    common.WorkflowStepReasonWaitingForApproval,
+			message:   "waiting for approval",
+		},
+		"approval not stamped by the webhook is refused": {
+			status:    waiting(time.Now(), nil),
+			approvals: approvals("app-v1", common.WorkflowStepApproval{Approved: true, Approver: "alice"}),
+			unstamped: true,
+			state:     StateExecuting,
+			phases:    []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseRunning, common.WorkflowStepPhasePending},
+			reason:    common.WorkflowStepReasonWaitingForApproval,
+			message:   "waiting for approval, the approvals are refused without the admission webhook of applications stamping the approvers",
+		},
+		"decision recorded in status": {
+			status:   waiting(time.Now(), &common.WorkflowStepApproval{Approved: true, Approver: "alice"}),
+			state:    StateFinished,
+			phases:   []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseSucceeded},
+			message:  "approved by alice",
+			approval: &common.WorkflowStepApproval{Approved: true, Approver: "alice"},
+		},
+		"approval timed out": {
+			status:  waiting(time.Now().Add(-2*time.Hour), nil),
+			state:   StateFinished,
+			phases:  []common.WorkflowStepPhase{common.WorkflowStepPhaseSucceeded, common.WorkflowStepPhaseFailed, common.WorkflowStepPhasePending},
+			reason:  common.WorkflowStepReasonTimeout,
+			message: "step is timed out after 1h0m0s",
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			testApp := app.DeepCopy()
+			testApp.Status.Workflow = tc.status
+			if tc.approvals != "" {
+				testApp.SetAnnotations(map[string]string{oam.AnnotationWorkflowApprovals: tc.approvals})
+			}
+			instances := stepInstances(t, []*unstructured.Unstructured{succeededStep, nil, succeededStep})
+			state, err := NewWorkflow(testApp, testApp.Spec.Workflow, nil, mockApplicator(), nil, WithApprovalsStamped(!tc.unstamped)).
+				ExecuteSteps(context.Background(), "app-v1", instances)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.state, state)
+			var phases []common.WorkflowStepPhase
+			for _, status := range testApp.Status.Workflow.Steps {
+				phases = append(phases, status.Phase)
+			}
+			assert.Equal(t, tc.phases, phases)
+			approval := testApp.Status.Workflow.Steps[1]
+			assert.Equal(t, tc.reason, approval.Reason)
+			assert.Equal(t, tc.message, approval.Message)
+			assert.Equal(t, tc.approval, approval.Approval)
+		})
+	}
+}
+
+func TestWorkflowRunTimes(t *testing.T) {
+	ctx := context.Background()
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "deploy",
+					Type: "deploy",
+				}},
+			},
+		},
+	}
+	succeededMessage, err := json.Marshal(&SucceededMessage{})
+	assert.NoError(t, err)
+	runningStep := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	succeededStep := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{
+				"type":    CondTypeWorkflowFinish,
+				"reason":  CondReasonSucceeded,
+				"message": string(succeededMessage),
+				"status":  CondStatusTrue,
+			}},
+		},
+	}}
+
+	state, err := NewWorkflow(app, app.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{runningStep}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateExecuting, state)
+	wfStatus := app.Status.Workflow
+	assert.NotNil(t, wfStatus.StartTime)
+	assert.Nil(t, wfStatus.EndTime)
+	assert.Nil(t, wfStatus.Steps[0].EndTime)
+	startTime := wfStatus.StartTime.DeepCopy()
+
+	state, err = NewWorkflow(app, app.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateFinished, state)
+	wfStatus = app.Status.Workflow
+	assert.Equal(t, startTime, wfStatus.StartTime)
+	assert.NotNil(t, wfStatus.EndTime)
+	assert.NotNil(t, wfStatus.Steps[0].EndTime)
+	endTime, stepEndTime := wfStatus.EndTime.DeepCopy(), wfStatus.Steps[0].EndTime.DeepCopy()
+
+	// the end time is kept when the finished workflow is executed again
+	state, err = NewWorkflow(app, app.Spec.Workflow, nil, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateFinished, state)
+	assert.Equal(t, endTime, app.Status.Workflow.EndTime)
+	assert.Equal(t, stepEndTime, app.Status.Workflow.Steps[0].EndTime)
+}
+
+// stepInstances renders the step objects as the output of workflow step templates
+func stepInstances(t *testing.T, objs []*unstructured.Unstructured) []*cue.Instance {
+	var instances []*cue.Instance
+	for _, obj := range objs {
+		if obj == nil {
+			// the built-in steps have no template
+			instances = append(instances, nil)
+			continue
+		}
+		b, err := json.Marshal(obj.Object)
+		assert.NoError(t, err)
+		var r cue.Runtime
+		inst, err := r.Compile("-", "output: "+string(b))
+		assert.NoError(t, err)
+		instances = append(instances, inst)
+	}
+	return instances
 }
 
 type testmockApplicator struct {
+	applied []runtime.Object
 }
 
 func (t *testmockApplicator) Apply(ctx context.Context, object runtime.Object, option ...apply.ApplyOption) error {
+	t.applied = append(t.applied, object)
 	return nil
 }
 
 func mockApplicator() apply.Applicator {
 	return &testmockApplicator{}
 }
+
+func TestNotificationStep(t *testing.T) {
+	var webhookBodies, slackBodies []map[string]interface{}
+	webhookCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if r.URL.Path == "/slack" {
+			slackBodies = append(slackBodies, body)
+			return
+		}
+		webhookBodies = append(webhookBodies, body)
+		w.WriteHeader(webhookCode)
+	}))
+	defer server.Close()
+
+	var mails []string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.NotNil(t, a)
+		mails = append(mails, string(msg))
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	props, err := json.Marshal(map[string]interface{}{
+		"message": "{{.context.appName}} is {{.steps.deploy.phase}}",
+		"webhook": map[string]interface{}{
+			"url": map[string]interface{}{"secretRef": map[string]interface{}{"name": "notification", "key": "webhook"}},
+		},
+		"slack": map[string]interface{}{
+			"url": map[string]interface{}{"value": server.URL + "/slack"},
+		},
+		"email": map[string]interface{}{
+			"host":     "smtp.example.com",
+			"username": map[string]interface{}{"value": "bot"},
+			"password": map[string]interface{}{"secretRef": map[string]interface{}{"name": "notification", "key": "password"}},
+			"from":     "bot@example.com",
+			"to":       []string{"ops@example.com"},
+		},
+	})
+	assert.NoError(t, err)
+	app := &oamcore.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: oamcore.ApplicationSpec{
+			Workflow: &oamcore.Workflow{
+				Steps: []oamcore.WorkflowStep{{
+					Name: "deploy",
+					Type: "deploy",
+				}, {
+					Name:       "notify",
+					Type:       "notification",
+					Properties: runtime.RawExtension{Raw: props},
+				}},
+			},
+		},
+	}
+	succeededMessage, err := json.Marshal(&SucceededMessage{})
+	assert.NoError(t, err)
+	succeededStep := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{
+				"type":    CondTypeWorkflowFinish,
+				"reason":  CondReasonSucceeded,
+				"message": string(succeededMessage),
+				"status":  CondStatusTrue,
+			}},
+		},
+	}}
+	cli := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "notification", Namespace: "test"},
+		Data: map[string][]byte{
+			"webhook":  []byte(server.URL + "/webhook"),
+			"password": []byte("secret"),
+		},
+	})
+	ctx := context.Background()
+
+	state, err := NewWorkflow(app, app.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep, nil}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateFinished, state)
+	notify := app.Status.Workflow.Steps[1]
+	assert.Equal(t, common.WorkflowStepPhaseSucceeded, notify.Phase)
+	assert.Equal(t, "notification is sent", notify.Message)
+	assert.Len(t, webhookBodies, 1)
+	assert.Equal(t, "test is succeeded", webhookBodies[0]["message"])
+	assert.Equal(t, "app-v1", webhookBodies[0]["context"].(map[string]interface{})["appRevision"])
+	assert.Equal(t, []map[string]interface{}{{"text": "test is succeeded"}}, slackBodies)
+	assert.Len(t, mails, 1)
+	assert.Contains(t, mails[0], "Subject: Workflow of application test\r\n")
+	assert.Contains(t, mails[0], "test is succeeded")
+
+	// the notification is sent only once
+	state, err = NewWorkflow(app, app.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep, nil}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateFinished, state)
+	assert.Len(t, webhookBodies, 1)
+	assert.Len(t, slackBodies, 1)
+	assert.Len(t, mails, 1)
+
+	// the step is failed if any receiver is not reached
+	webhookCode = http.StatusInternalServerError
+	failedApp := app.DeepCopy()
+	failedApp.Status.Workflow = nil
+	state, err = NewWorkflow(failedApp, failedApp.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep, nil}))
+	assert.NoError(t, err)
+	assert.Equal(t, StateFinished, state)
+	notify = failedApp.Status.Workflow.Steps[1]
+	assert.Equal(t, common.WorkflowStepPhaseFailed, notify.Phase)
+	assert.Contains(t, notify.Message, "failed to send notification: webhook: the webhook responded 500")
+	assert.NotContains(t, notify.Message, server.URL)
+	assert.Len(t, slackBodies, 2)
+	assert.Len(t, mails, 2)
+
+	// the url of the webhook which is not reached is not put in the status since it may contain credentials
+	unreachable := app.DeepCopy()
+	unreachable.Status.Workflow = nil
+	unreachable.Spec.Workflow.Steps[1].Properties = runtime.RawExtension{Raw: []byte(
+		`{"message":"hello","webhook":{"url":{"value":"http://127.0.0.1:0/hooks/secret-token"}}}`)}
+	_, err = NewWorkflow(unreachable, unreachable.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep, nil}))
+	assert.NoError(t, err)
+	notify = unreachable.Status.Workflow.Steps[1]
+	assert.Equal(t, common.WorkflowStepPhaseFailed, notify.Phase)
+	assert.Contains(t, notify.Message, "webhook: the webhook is not reached")
+	assert.NotContains(t, notify.Message, "secret-token")
+
+	// the email headers can't be injected by the subject
+	injected := app.DeepCopy()
+	injected.Status.Workflow = nil
+	injected.SetLabels(map[string]string{"team": "ops\r\nBcc: attacker@example.com"})
+	injected.Spec.Workflow.Steps[1].Properties = runtime.RawExtension{Raw: []byte(
+		`{"message":"hello","email":{"host":"smtp.example.com","username":{"value":"bot"},"from":"bot@example.com","to":["ops@example.com"],"subject":"{{.app.metadata.labels.team}}"}}`)}
+	_, err = NewWorkflow(injected, injected.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep, nil}))
+	assert.NoError(t, err)
+	notify = injected.Status.Workflow.Steps[1]
+	assert.Equal(t, common.WorkflowStepPhaseFailed, notify.Phase)
+	assert.Equal(t, "failed to send notification: email: subject must not contain line breaks", notify.Message)
+	assert.Len(t, mails, 2)
+
+	noReceiver := app.DeepCopy()
+	noReceiver.Status.Workflow = nil
+	noReceiver.Spec.Workflow.Steps[1].Properties = runtime.RawExtension{Raw: []byte(`{"message":"hello"}`)}
+	_, err = NewWorkflow(noReceiver, noReceiver.Spec.Workflow, cli, mockApplicator(), nil).ExecuteSteps(ctx, "app-v1", stepInstances(t, []*unstructured.Unstructured{succeededStep, nil}))
+	assert.Error(t, err)
+}
@@ -37,6 +37,11 @@ type EnvironmentBody struct {
 	Namespace string `json:"namespace" binding:"required,min=1,max=32"`
 }
 
+// WorkflowApprovalBody used to approve or reject a suspend step of application workflow
+type WorkflowApprovalBody struct {
+	Comment string `json:"comment,omitempty"`
+}
+
 // Response used for restful API response in dashboard server
 type Response struct {
 	Code int         `json:"code"`
@@ -113,6 +113,15 @@ func (s *APIServer) setupRoute(staticPath string) http.Handler {
 					traitWorkload.DELETE("/:traitName", s.DetachTrait)
 				}
 			}
+
+			// workflow related operation
+			workflow := apps.Group("/:appName/workflow")
+			{
+				workflow.GET("/", s.GetWorkflow)
+				workflow.GET("", s.GetWorkflow)
+				workflow.POST("/steps/:stepName/approve", s.ApproveWorkflowStep)
+				workflow.POST("/steps/:stepName/reject", s.RejectWorkflowStep)
+			}
 		}
 	}
 	// component related api
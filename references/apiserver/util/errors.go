@@ -36,6 +36,7 @@ const (
 	InvalidArgument
 	UnsupportedMediaType
 	StatusInternalServerError
+	Unauthenticated
 )
 
 type errorDetail struct {
@@ -48,7 +49,8 @@ var errorDetails = map[Code]errorDetail{
 	PathNotSupported:          {"PathNotSupported", http.StatusNotFound, "'%s' against '%s' is not supported"},
 	InvalidArgument:           {"InvalidArgument", http.StatusBadRequest, "%s"},
 	UnsupportedMediaType:      {"UnsupportedMediaType", http.StatusUnsupportedMediaType, "content type should be 'application/json' or 'application/octet-stream'"},
-	StatusInternalServerError: {"StatusInternalServerError", http.StatusInternalServerError, "%s"}}
+	StatusInternalServerError: {"StatusInternalServerError", http.StatusInternalServerError, "%s"},
+	Unauthenticated:           {"Unauthenticated", http.StatusUnauthorized, "%s"}}
 
 // ID returns the error ID.
 func (c Code) ID() string {
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/utils/env"
+	"github.com/oam-dev/kubevela/references/apiserver/apis"
+	"github.com/oam-dev/kubevela/references/apiserver/util"
+	"github.com/oam-dev/kubevela/references/common"
+)
+
+// GetWorkflow gets the workflow status of an application
+// @tags workflow
+// @ID GetWorkflow
+// @Summary gets the workflow status of an application
+// @Param envName path string true "environment name"
+// @Param appName path string true "application name"
+// @Success 200 {object} apis.Response{code=int,data=common.WorkflowStatus}
+// @Failure 500 {object} apis.Response{code=int,data=string}
+// @Router /envs/{envName}/apps/{appName}/workflow [get]
+func (s *APIServer) GetWorkflow(c *gin.Context) {
+	envMeta, err := env.GetEnvByName(c.Param("envName"))
+	if err != nil {
+		util.HandleError(c, util.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx := util.GetContext(c)
+	app := &v1beta1.Application{}
+	if err := s.KubeClient.Get(ctx, client.ObjectKey{Namespace: envMeta.Namespace, Name: c.Param("appName")}, app); err != nil {
+		util.HandleError(c, util.StatusInternalServerError, err.Error())
+		return
+	}
+	util.AssembleResponse(c, app.Status.Workflow, nil)
+}
+
+// ApproveWorkflowStep approves a suspend step of the application workflow
+// @tags workflow
+// @ID ApproveWorkflowStep
+// @Summary approves a suspend step of the application workflow
+// @Param envName path string true "environment name"
+// @Param appName path string true "application name"
+// @Param stepName path string true "workflow step name"
+// @Param body body apis.WorkflowApprovalBody true "comment of the decision"
+// @Param Authorization header string true "bearer token of the approver in the cluster"
+// @Success 200 {object} apis.Response{code=int,data=string}
+// @Failure 401 {object} apis.Response{code=int,data=string}
+// @Failure 500 {object} apis.Response{code=int,data=string}
+// @Router /envs/{envName}/apps/{appName}/workflow/steps/{stepName}/approve [post]
+func (s *APIServer) ApproveWorkflowStep(c *gin.Context) {
+	s.approveWorkflowStep(c, true)
+}
+
+// RejectWorkflowStep rejects a suspend step of the application workflow, the step will be failed
+// @tags workflow
+// @ID RejectWorkflowStep
+// @Summary rejects a suspend step of the application workflow
+// @Param envName path string true "environment name"
+// @Param appName path string true "application name"
+// @Param stepName path string true "workflow step name"
+// @Param body body apis.WorkflowApprovalBody true "comment of the decision"
+// @Param Authorization header string true "bearer token of the approver in the cluster"
+// @Success 200 {object} apis.Response{code=int,data=string}
+// @Failure 401 {object} apis.Response{code=int,data=string}
+// @Failure 500 {object} apis.Response{code=int,data=string}
+// @Router /envs/{envName}/apps/{appName}/workflow/steps/{stepName}/reject [post]
+func (s *APIServer) RejectWorkflowStep(c *gin.Context) {
+	s.approveWorkflowStep(c, false)
+}
+
+func (s *APIServer) approveWorkflowStep(c *gin.Context, approved bool) {
+	var body apis.WorkflowApprovalBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		util.HandleError(c, util.InvalidArgument, "the workflow approval request body is invalid")
+		return
+	}
+	envMeta, err := env.GetEnvByName(c.Param("envName"))
+	if err != nil {
+		util.HandleError(c, util.StatusInternalServerError, err.Error())
+		return
+	}
+	approver, err := s.approverClient(c)
+	if err != nil {
+		util.HandleError(c, util.Unauthenticated, err.Error())
+		return
+	}
+	appName, stepName := c.Param("appName"), c.Param("stepName")
+	ctrl.Log.Info("Get a workflow approval request", "app", appName, "step", stepName, "approved", approved)
+	ctx := util.GetContext(c)
+	// the approver is recorded by the admission webhook from the identity of the request
+	operate := common.ApproveWorkflowStep(stepName, approved, body.Comment)
+	if err := common.OperateWorkflow(ctx, approver, envMeta.Namespace, appName, operate); err != nil {
+		util.HandleError(c, util.StatusInternalServerError, err.Error())
+		return
+	}
+	result := "approved"
+	if !approved {
+		result = "rejected"
+	}
+	util.AssembleResponse(c, fmt.Sprintf("workflow step %s of application %s is %s", stepName, appName, result), nil)
+}
+
+// approverClient returns a client authenticated as the caller, so the approval is recorded as the caller
+// rather than as the apiserver itself
+func (s *APIServer) approverClient(c *gin.Context) (client.Client, error) {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, fmt.Errorf("the bearer token of the approver is required")
+	}
+	config := rest.AnonymousClientConfig(s.c.Config)
+	config.BearerToken = strings.TrimPrefix(auth, "Bearer ")
+	return client.New(config, client.Options{Scheme: s.c.Schema})
+}
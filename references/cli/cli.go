@@ -84,9 +84,11 @@ func NewCommand() *cobra.Command {
 		NewLogsCommand(commandArgs, ioStream),
 		NewEnvCommand(commandArgs, ioStream),
 		NewConfigCommand(ioStream),
+		NewWorkflowCommand(commandArgs, ioStream),
 
 		// Capabilities
 		CapabilityCommandGroup(commandArgs, ioStream),
+		DefinitionCommandGroup(ioStream),
 		NewTemplateCommand(ioStream),
 		NewTraitsCommand(commandArgs, ioStream),
 		NewComponentsCommand(commandArgs, ioStream),
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+	"github.com/oam-dev/kubevela/references/common"
+)
+
+// DefinitionCommandGroup creates `def` command and its nested children
+func DefinitionCommandGroup(ioStreams cmdutil.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "def",
+		Short: "Manage definitions",
+		Long:  "Manage ComponentDefinitions and TraitDefinitions, e.g., test their CUE templates offline.",
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeCap,
+		},
+	}
+	cmd.SetOut(ioStreams.Out)
+	cmd.AddCommand(NewDefinitionTestCommand(ioStreams))
+	return cmd
+}
+
+// NewDefinitionTestCommand creates `def test` command
+func NewDefinitionTestCommand(ioStreams cmdutil.IOStreams) *cobra.Command {
+	var schemaFile, kubeVersion string
+	cmd := &cobra.Command{
+		Use:                   "test PATH...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Test definitions offline",
+		Long: "Run the tests in the *_test.yaml files under the paths, with the ComponentDefinitions and TraitDefinitions " +
+			"in the other yaml files under the paths. Each test renders a component with its traits, and checks the " +
+			"rendered resources or the error of the rendering. It fails if any test fails. The kube packages imported by " +
+			"the templates are loaded from the OpenAPI document given by --openapi-schema, or from the embedded one of " +
+			"the Kubernetes version given by --kube-version.",
+		Example: "vela def test ./defs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("must specify the paths of definitions and their tests")
+			}
+			pd, err := packages.NewOfflinePackageDiscover(schemaFile, kubeVersion)
+			if err != nil {
+				return errors.WithMessage(err, "cannot load the kube packages")
+			}
+			results, err := common.RunDefinitionTests(pd, args)
+			if err != nil {
+				return err
+			}
+			if failed := common.PrintDefinitionTestResults(results, ioStreams.Out); failed > 0 {
+				return errors.Errorf("%d of %d definition tests failed", failed, len(results))
+			}
+			return nil
+		},
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeCap,
+		},
+	}
+	cmd.Flags().StringVar(&schemaFile, "openapi-schema", os.Getenv(packages.OpenAPISchemaFileEnv),
+		"the OpenAPI document of Kubernetes, e.g. the output of \"kubectl get --raw /openapi/v2\", to load the kube packages from")
+	cmd.Flags().StringVar(&kubeVersion, "kube-version", os.Getenv(packages.KubeVersionEnv),
+		"the Kubernetes version of the embedded OpenAPI document to load the kube packages from, "+packages.DefaultKubeVersion+" by default")
+	cmd.SetOut(ioStreams.Out)
+	return cmd
+}
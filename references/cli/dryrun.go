@@ -28,6 +28,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/yaml"
 
 	corev1beta1 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
@@ -56,7 +59,11 @@ func NewDryRunCommand(c common.Args, ioStreams cmdutil.IOStreams) *cobra.Command
 		Long:                  "Dry Run an application, and output the K8s resources as result to stdout, only CUE template supported for now",
 		Example:               "vela dry-run",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			return c.SetConfig()
+			if err := c.SetConfig(); err != nil {
+				// the application can still be rendered offline with the definitions in --definition
+				ioStreams.Infof("No cluster is configured, dry-run offline: %v\n", err)
+			}
+			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			velaEnv, err := GetEnv(cmd)
@@ -82,7 +89,7 @@ func NewDryRunCommand(c common.Args, ioStreams cmdutil.IOStreams) *cobra.Command
 func DryRunApplication(cmdOption *DryRunCmdOptions, c common.Args, namespace string) (bytes.Buffer, error) {
 	var buff = bytes.Buffer{}
 
-	newClient, err := c.GetClient()
+	newClient, dm, err := getDryRunClients(&c)
 	if err != nil {
 		return buff, err
 	}
@@ -98,11 +105,6 @@ func DryRunApplication(cmdOption *DryRunCmdOptions, c common.Args, namespace str
 		return buff, err
 	}
 
-	dm, err := discoverymapper.New(c.Config)
-	if err != nil {
-		return buff, err
-	}
-
 	app, err := readApplicationFromFile(cmdOption.ApplicationFile)
 	if err != nil {
 		return buff, errors.WithMessagef(err, "read application file: %s", cmdOption.ApplicationFile)
@@ -140,6 +142,22 @@ func DryRunApplication(cmdOption *DryRunCmdOptions, c common.Args, namespace str
 	return buff, nil
 }
 
+// getDryRunClients returns the clients of the cluster, or a client without any object and an offline discovery mapper
+// if no cluster is reachable, so that the application can be rendered offline with the definitions given by the
+// definition file.
+func getDryRunClients(c *common.Args) (client.Client, discoverymapper.DiscoveryMapper, error) {
+	if c.Config != nil {
+		newClient, err := c.GetClient()
+		if err == nil {
+			dm, err := c.GetDiscoveryMapper()
+			return newClient, dm, err
+		}
+		klog.InfoS("The cluster is unreachable, dry-run offline", "err", err)
+	}
+	//lint:ignore SA1019 the client only serves the reads of the definitions which are not given
+	return fake.NewFakeClientWithScheme(c.Schema), discoverymapper.NewOffline(), nil
+}
+
 // ReadObjectsFromFile will read objects from file or dir in the format of yaml
 func ReadObjectsFromFile(path string) ([]oam.Object, error) {
 	fi, err := os.Stat(path)
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+	"github.com/oam-dev/kubevela/pkg/utils/util"
+)
+
+func TestDryRunApplicationOffline(t *testing.T) {
+	os.Setenv(packages.KubeVersionEnv, packages.DefaultKubeVersion)
+	defer os.Unsetenv(packages.KubeVersionEnv)
+	c := common.Args{Schema: common.Scheme}
+
+	_, dm, err := getDryRunClients(&c)
+	assert.NoError(t, err)
+	_, err = dm.KindsFor(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"})
+	assert.True(t, errors.Is(err, discoverymapper.ErrOffline))
+
+	// the definitions are given by the file, and their kube packages are loaded from the embedded snapshot
+	o := &DryRunCmdOptions{
+		IOStreams:       util.IOStreams{Out: os.Stdout, ErrOut: os.Stderr},
+		ApplicationFile: "../appfile/dryrun/testdata/dryrun-app.yaml",
+		DefinitionFile:  "../appfile/dryrun/testdata",
+	}
+	buff, err := DryRunApplication(o, c, "default")
+	assert.NoError(t, err)
+	assert.Contains(t, buff.String(), "kind: Deployment")
+	assert.Contains(t, buff.String(), "image: busybox")
+
+	// the definitions not given by the file can't be loaded without a cluster
+	o.DefinitionFile = ""
+	_, err = DryRunApplication(o, c, "default")
+	assert.Error(t, err)
+}
@@ -20,18 +20,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	commontypes "github.com/oam-dev/kubevela/apis/core.oam.dev/common"
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
 	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
 	"github.com/oam-dev/kubevela/pkg/utils/common"
 	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
@@ -122,6 +125,7 @@ func NewAppStatusCommand(c common.Args, ioStreams cmdutil.IOStreams) *cobra.Comm
 		},
 	}
 	cmd.Flags().StringP("svc", "s", "", "service name")
+	cmd.Flags().Int("workflow-runs", 3, "the number of the latest workflow runs to show, a negative number shows all of them")
 	cmd.SetOut(ioStreams.Out)
 	return cmd
 }
@@ -141,7 +145,82 @@ func printAppStatus(ctx context.Context, c client.Client, ioStreams cmdutil.IOSt
 	cmd.Printf("%s\n\n", table.String())
 
 	cmd.Printf("Services:\n\n")
-	return loopCheckStatus(ctx, c, ioStreams, appName, env)
+	if err := loopCheckStatus(ctx, c, ioStreams, appName, env); err != nil {
+		return err
+	}
+	limit, err := cmd.Flags().GetInt("workflow-runs")
+	if err != nil {
+		return err
+	}
+	if !app.Spec.Workflow.HasSteps() || limit == 0 {
+		return nil
+	}
+	runs, err := latestWorkflowRuns(ctx, c, namespace, appName, limit)
+	if err != nil {
+		return err
+	}
+	cmd.Printf("\nWorkflow Runs:\n\n")
+	printWorkflowRuns(ioStreams, runs)
+	return nil
+}
+
+// revisionWorkflowRun is a workflow run with the app revision it's executed for
+type revisionWorkflowRun struct {
+	revision string
+	commontypes.WorkflowRun
+}
+
+// latestWorkflowRuns returns the latest workflow runs recorded in the app revisions, the latest run comes last.
+func latestWorkflowRuns(ctx context.Context, c client.Client, namespace, appName string, limit int) ([]revisionWorkflowRun, error) {
+	revisions := &v1beta1.ApplicationRevisionList{}
+	if err := c.List(ctx, revisions, client.InNamespace(namespace), client.MatchingLabels{oam.LabelAppName: appName}); err != nil {
+		return nil, err
+	}
+	var runs []revisionWorkflowRun
+	for _, rev := range revisions.Items {
+		for _, run := range rev.Status.WorkflowRuns {
+			runs = append(runs, revisionWorkflowRun{revision: rev.Name, WorkflowRun: run})
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartTime.Before(&runs[j].StartTime)
+	})
+	if limit >= 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return runs, nil
+}
+
+func printWorkflowRuns(ioStreams cmdutil.IOStreams, runs []revisionWorkflowRun) {
+	if len(runs) == 0 {
+		ioStreams.Info("  No finished workflow run yet")
+		return
+	}
+	for _, run := range runs {
+		result := "Finished"
+		if run.Terminated {
+			result = "Terminated"
+		}
+		ioStreams.Infof(white.Sprintf("  - Revision: %s\n", run.revision))
+		ioStreams.Infof("    %s: %s ~ %s\n", result, run.StartTime.Format(time.RFC3339), run.EndTime.Format(time.RFC3339))
+		table := newUITable()
+		table.AddRow("    NAME", "TYPE", "PHASE", "STARTED", "ENDED", "RESOURCE", "MESSAGE")
+		for _, step := range run.Steps {
+			resource := ""
+			if step.ResourceRef.Name != "" {
+				resource = fmt.Sprintf("%s/%s", step.ResourceRef.Kind, step.ResourceRef.Name)
+			}
+			table.AddRow("    "+step.Name, step.Type, step.Phase, formatStepTime(step.StartTime), formatStepTime(step.EndTime), resource, step.Message)
+		}
+		ioStreams.Info(table.String())
+	}
+}
+
+func formatStepTime(t *metav1.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
 }
 
 func loadRemoteApplication(c client.Client, ns string, name string) (*v1beta1.Application, error) {
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	commontypes "github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestLatestWorkflowRuns(t *testing.T) {
+	now := time.Now()
+	runAt := func(minutes int) commontypes.WorkflowRun {
+		return commontypes.WorkflowRun{
+			StartTime: metav1.NewTime(now.Add(time.Duration(minutes) * time.Minute)),
+			EndTime:   metav1.NewTime(now.Add(time.Duration(minutes+1) * time.Minute)),
+		}
+	}
+	revision := func(name, app string, runs ...commontypes.WorkflowRun) *v1beta1.ApplicationRevision {
+		return &v1beta1.ApplicationRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{oam.LabelAppName: app},
+			},
+			Status: v1beta1.ApplicationRevisionStatus{WorkflowRuns: runs},
+		}
+	}
+	fakeClient := fake.NewFakeClientWithScheme(common.Scheme,
+		revision("app-v1", "app", runAt(0), runAt(10)),
+		revision("app-v2", "app", runAt(20)),
+		revision("other-v1", "other", runAt(30)),
+	)
+
+	runs, err := latestWorkflowRuns(context.Background(), fakeClient, "default", "app", 2)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "app-v1", runs[0].revision)
+	assert.Equal(t, runAt(10).StartTime.Unix(), runs[0].StartTime.Unix())
+	assert.Equal(t, "app-v2", runs[1].revision)
+
+	runs, err = latestWorkflowRuns(context.Background(), fakeClient, "default", "app", -1)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	common2 "github.com/oam-dev/kubevela/pkg/utils/common"
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+	"github.com/oam-dev/kubevela/references/common"
+)
+
+// NewWorkflowCommand creates `workflow` command and its nested children
+func NewWorkflowCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "workflow",
+		DisableFlagsInUseLine: true,
+		Short:                 "Operate application workflow",
+		Long:                  "Operate the workflow of an application, e.g., suspend, resume, terminate, restart it or approve its suspend steps.",
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeApp,
+		},
+	}
+	cmd.SetOut(ioStreams.Out)
+	cmd.AddCommand(
+		NewWorkflowSuspendCommand(c, ioStreams),
+		NewWorkflowResumeCommand(c, ioStreams),
+		NewWorkflowTerminateCommand(c, ioStreams),
+		NewWorkflowRestartCommand(c, ioStreams),
+		NewWorkflowApproveCommand(c, ioStreams),
+		NewWorkflowRejectCommand(c, ioStreams),
+	)
+	return cmd
+}
+
+// NewWorkflowSuspendCommand creates `workflow suspend` command
+func NewWorkflowSuspendCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	return newWorkflowOperationCommand(c, ioStreams, "suspend", "Suspend an application workflow, it's kept suspended across app revisions until it's resumed", "suspended",
+		common.SuspendWorkflow)
+}
+
+// NewWorkflowResumeCommand creates `workflow resume` command
+func NewWorkflowResumeCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	return newWorkflowOperationCommand(c, ioStreams, "resume", "Resume a suspended application workflow", "resumed",
+		common.ResumeWorkflow)
+}
+
+// NewWorkflowTerminateCommand creates `workflow terminate` command
+func NewWorkflowTerminateCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	return newWorkflowOperationCommand(c, ioStreams, "terminate", "Terminate an application workflow", "terminated",
+		common.TerminateWorkflow)
+}
+
+// NewWorkflowRestartCommand creates `workflow restart` command
+func NewWorkflowRestartCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	return newWorkflowOperationCommand(c, ioStreams, "restart", "Restart an application workflow from scratch", "restarted",
+		common.RestartWorkflow)
+}
+
+func newWorkflowOperationCommand(c common2.Args, ioStreams cmdutil.IOStreams, operation, short, result string,
+	operate func(app *v1beta1.Application) error) *cobra.Command {
+	ctx := context.Background()
+	cmd := &cobra.Command{
+		Use:                   fmt.Sprintf("%s APP_NAME", operation),
+		DisableFlagsInUseLine: true,
+		Short:                 short,
+		Long:                  short,
+		Example:               fmt.Sprintf("vela workflow %s frontend", operation),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("must specify name for the app")
+			}
+			env, err := GetEnv(cmd)
+			if err != nil {
+				return err
+			}
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			if err := common.OperateWorkflow(ctx, newClient, env.Namespace, args[0], operate); err != nil {
+				return err
+			}
+			ioStreams.Infof("Workflow of application %s is %s\n", args[0], result)
+			return nil
+		},
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeApp,
+		},
+	}
+	cmd.SetOut(ioStreams.Out)
+	return cmd
+}
+
+// NewWorkflowApproveCommand creates `workflow approve` command
+func NewWorkflowApproveCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	return newWorkflowApprovalCommand(c, ioStreams, true)
+}
+
+// NewWorkflowRejectCommand creates `workflow reject` command
+func NewWorkflowRejectCommand(c common2.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	return newWorkflowApprovalCommand(c, ioStreams, false)
+}
+
+func newWorkflowApprovalCommand(c common2.Args, ioStreams cmdutil.IOStreams, approved bool) *cobra.Command {
+	ctx := context.Background()
+	operation, short, result := "approve", "Approve a suspend step of an application workflow", "approved"
+	if !approved {
+		operation, short, result = "reject", "Reject a suspend step of an application workflow, the step will be failed", "rejected"
+	}
+	cmd := &cobra.Command{
+		Use:                   fmt.Sprintf("%s APP_NAME STEP_NAME", operation),
+		DisableFlagsInUseLine: true,
+		Short:                 short,
+		Long:                  short,
+		Example:               fmt.Sprintf("vela workflow %s frontend approve-production", operation),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 2 {
+				return errors.New("must specify name for the app and the step")
+			}
+			comment, err := cmd.Flags().GetString("comment")
+			if err != nil {
+				return err
+			}
+			env, err := GetEnv(cmd)
+			if err != nil {
+				return err
+			}
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			// the approver is recorded by the admission webhook from the identity of the request
+			operate := common.ApproveWorkflowStep(args[1], approved, comment)
+			if err := common.OperateWorkflow(ctx, newClient, env.Namespace, args[0], operate); err != nil {
+				return err
+			}
+			ioStreams.Infof("Workflow step %s of application %s is %s\n", args[1], args[0], result)
+			return nil
+		},
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeApp,
+		},
+	}
+	cmd.Flags().String("comment", "", "the reason of the decision")
+	cmd.SetOut(ioStreams.Out)
+	return cmd
+}
@@ -0,0 +1,372 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aryann/difflib"
+	"github.com/pkg/errors"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/pkg/cue/definition"
+	"github.com/oam-dev/kubevela/pkg/cue/packages"
+	"github.com/oam-dev/kubevela/pkg/cue/process"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+const (
+	defaultTestAppName   = "test-app"
+	defaultTestNamespace = "default"
+)
+
+// DefinitionTestFile is a file of definition tests, it's named with the suffix `_test.yaml`
+type DefinitionTestFile struct {
+	Tests []DefinitionTest `json:"tests"`
+}
+
+// DefinitionTest renders a component with its traits by the definitions, and checks the rendered resources
+// or the error of the rendering.
+type DefinitionTest struct {
+	Name      string                       `json:"name"`
+	Component v1beta1.ApplicationComponent `json:"component"`
+	Context   DefinitionTestContext        `json:"context,omitempty"`
+	Expected  *DefinitionTestExpected      `json:"expected,omitempty"`
+	// ExpectedError is a substring of the expected error of the rendering
+	ExpectedError string `json:"expectedError,omitempty"`
+}
+
+// DefinitionTestContext is the context of the rendering, the name of the component is `context.name`
+type DefinitionTestContext struct {
+	AppName     string `json:"appName,omitempty"`
+	AppRevision string `json:"appRevision,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+}
+
+// DefinitionTestExpected is the expected resources rendered from `output` and `outputs` of the templates
+type DefinitionTestExpected struct {
+	Output  map[string]interface{}            `json:"output,omitempty"`
+	Outputs map[string]map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// DefinitionTestResult is the result of a definition test
+type DefinitionTestResult struct {
+	File string
+	Name string
+	// Failure describes why the test failed, it's empty if the test passed
+	Failure string
+}
+
+// definitionTemplates are the CUE templates of the definitions keyed by their names
+type definitionTemplates struct {
+	components map[string]string
+	traits     map[string]string
+}
+
+// RunDefinitionTests runs the tests in the `_test.yaml` files under the paths with the definitions in the other
+// yaml files under the paths. The templates are rendered offline with the kube packages of pd, they can't import
+// the kube packages if pd is nil.
+func RunDefinitionTests(pd *packages.PackageDiscover, paths []string) ([]DefinitionTestResult, error) {
+	var defFiles, testFiles []string
+	for _, path := range paths {
+		if err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(p)
+			if info.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				return nil
+			}
+			if strings.HasSuffix(strings.TrimSuffix(p, ext), "_test") {
+				testFiles = append(testFiles, p)
+			} else {
+				defFiles = append(defFiles, p)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if len(testFiles) == 0 {
+		return nil, errors.Errorf("no definition test file (*_test.yaml) is found in %s", strings.Join(paths, ", "))
+	}
+	templates, err := loadDefinitionTemplates(defFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if pd == nil {
+		pd = &packages.PackageDiscover{}
+	}
+	var results []DefinitionTestResult
+	for _, file := range testFiles {
+		testFile := DefinitionTestFile{}
+		if err := readYAML(file, &testFile); err != nil {
+			return nil, errors.WithMessagef(err, "read test file %s", file)
+		}
+		for _, test := range testFile.Tests {
+			results = append(results, DefinitionTestResult{
+				File:    file,
+				Name:    test.Name,
+				Failure: runDefinitionTest(pd, templates, test),
+			})
+		}
+	}
+	return results, nil
+}
+
+// PrintDefinitionTestResults prints the results and the summary, it returns the number of failed tests.
+func PrintDefinitionTestResults(results []DefinitionTestResult, out io.Writer) int {
+	failed := 0
+	for _, r := range results {
+		if r.Failure == "" {
+			fmt.Fprintf(out, "PASS %s: %s\n", r.File, r.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL %s: %s\n", r.File, r.Name)
+		for _, line := range strings.Split(strings.TrimRight(r.Failure, "\n"), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+	}
+	fmt.Fprintf(out, "\n%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
+
+func runDefinitionTest(pd *packages.PackageDiscover, templates *definitionTemplates, test DefinitionTest) string {
+	got, err := renderDefinitionTest(pd, templates, test)
+	if test.ExpectedError != "" {
+		if err == nil {
+			return fmt.Sprintf("expected error containing %q, but the rendering succeeded", test.ExpectedError)
+		}
+		if !strings.Contains(err.Error(), test.ExpectedError) {
+			return fmt.Sprintf("expected error containing %q, got: %v", test.ExpectedError, err)
+		}
+		return ""
+	}
+	if err != nil {
+		return fmt.Sprintf("render error: %v", err)
+	}
+	if test.Expected == nil {
+		return ""
+	}
+	expected := map[string]interface{}{}
+	if test.Expected.Output != nil {
+		expected["output"] = test.Expected.Output
+	}
+	for name, o := range test.Expected.Outputs {
+		expected["outputs."+name] = o
+	}
+	return diffRendered(expected, got)
+}
+
+// renderDefinitionTest renders the component and its traits, the resources are keyed by `output` and `outputs.<name>`.
+func renderDefinitionTest(pd *packages.PackageDiscover, templates *definitionTemplates, test DefinitionTest) (map[string]interface{}, error) {
+	comp := test.Component
+	tmpl, ok := templates.components[comp.Type]
+	if !ok {
+		return nil, errors.Errorf("component definition %s is not found", comp.Type)
+	}
+	appName := test.Context.AppName
+	if appName == "" {
+		appName = defaultTestAppName
+	}
+	appRevision := test.Context.AppRevision
+	if appRevision == "" {
+		appRevision = appName + "-v1"
+	}
+	namespace := test.Context.Namespace
+	if namespace == "" {
+		namespace = defaultTestNamespace
+	}
+	ctx := process.NewContext(namespace, comp.Name, appName, appRevision)
+
+	params, err := util.RawExtension2Map(&comp.Properties)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid properties of component %s", comp.Name)
+	}
+	if err := definition.NewWorkloadAbstractEngine(comp.Name, pd).Complete(ctx, tmpl, params); err != nil {
+		return nil, err
+	}
+	for _, trait := range comp.Traits {
+		tmpl, ok := templates.traits[trait.Type]
+		if !ok {
+			return nil, errors.Errorf("trait definition %s is not found", trait.Type)
+		}
+		params, err := util.RawExtension2Map(&trait.Properties)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid properties of trait %s", trait.Type)
+		}
+		if err := definition.NewTraitAbstractEngine(trait.Type, pd).Complete(ctx, tmpl, params); err != nil {
+			return nil, err
+		}
+	}
+
+	base, auxiliaries := ctx.Output()
+	got := map[string]interface{}{}
+	if base != nil {
+		obj, err := base.Unstructured()
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid output")
+		}
+		got["output"] = obj.Object
+	}
+	for _, aux := range auxiliaries {
+		obj, err := aux.Ins.Unstructured()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid outputs.%s", aux.Name)
+		}
+		got["outputs."+aux.Name] = obj.Object
+	}
+	return got, nil
+}
+
+// diffRendered compares the rendered resources with the expected ones, and returns the differences in yaml.
+func diffRendered(expected, got map[string]interface{}) string {
+	keys := map[string]bool{}
+	for k := range expected {
+		keys[k] = true
+	}
+	for k := range got {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	buf := &bytes.Buffer{}
+	for _, k := range sorted {
+		exp, expOK := expected[k]
+		act, actOK := got[k]
+		switch {
+		case !expOK:
+			fmt.Fprintf(buf, "%s is rendered but not expected\n", k)
+		case !actOK:
+			fmt.Fprintf(buf, "%s is expected but not rendered\n", k)
+		default:
+			if reflect.DeepEqual(normalize(exp), normalize(act)) {
+				continue
+			}
+			fmt.Fprintf(buf, "%s differs (- expected, + rendered):\n", k)
+			for _, d := range difflib.Diff(strings.Split(toYAML(exp), "\n"), strings.Split(toYAML(act), "\n")) {
+				switch d.Delta {
+				case difflib.LeftOnly:
+					fmt.Fprintf(buf, "- %s\n", d.Payload)
+				case difflib.RightOnly:
+					fmt.Fprintf(buf, "+ %s\n", d.Payload)
+				default:
+					fmt.Fprintf(buf, "  %s\n", d.Payload)
+				}
+			}
+		}
+	}
+	return buf.String()
+}
+
+// normalize converts the numbers of a decoded yaml and a rendered object into the same type
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func toYAML(v interface{}) string {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimRight(string(b), "\n")
+}
+
+// loadDefinitionTemplates reads the CUE templates of the ComponentDefinitions and TraitDefinitions in the files,
+// a file may contain multiple yaml documents, other kinds of objects are ignored.
+func loadDefinitionTemplates(files []string) (*definitionTemplates, error) {
+	templates := &definitionTemplates{components: map[string]string{}, traits: map[string]string{}}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			raw := json.RawMessage{}
+			if err := decoder.Decode(&raw); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, errors.WithMessagef(err, "read definitions in %s", file)
+			}
+			if len(raw) == 0 || string(raw) == "null" {
+				continue
+			}
+			meta := struct {
+				Kind string `json:"kind"`
+			}{}
+			if err := json.Unmarshal(raw, &meta); err != nil {
+				return nil, errors.WithMessagef(err, "read definitions in %s", file)
+			}
+			switch meta.Kind {
+			case v1beta1.ComponentDefinitionKind:
+				def := &v1beta1.ComponentDefinition{}
+				if err := json.Unmarshal(raw, def); err != nil {
+					return nil, errors.WithMessagef(err, "read component definition in %s", file)
+				}
+				if def.Spec.Schematic == nil || def.Spec.Schematic.CUE == nil {
+					continue
+				}
+				templates.components[def.Name] = def.Spec.Schematic.CUE.Template
+			case v1beta1.TraitDefinitionKind:
+				def := &v1beta1.TraitDefinition{}
+				if err := json.Unmarshal(raw, def); err != nil {
+					return nil, errors.WithMessagef(err, "read trait definition in %s", file)
+				}
+				if def.Spec.Schematic == nil || def.Spec.Schematic.CUE == nil {
+					continue
+				}
+				templates.traits[def.Name] = def.Spec.Schematic.CUE.Template
+			}
+		}
+	}
+	return templates, nil
+}
+
+func readYAML(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDefinitionTests(t *testing.T) {
+	results, err := RunDefinitionTests(nil, []string{"testdata/deftest"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(results))
+
+	assert.Equal(t, "render the deployment and the service", results[0].Name)
+	assert.Equal(t, "", results[0].Failure)
+	assert.Equal(t, "image must be a string", results[1].Name)
+	assert.Equal(t, "", results[1].Failure)
+	assert.Equal(t, "wrong image", results[2].Name)
+	assert.Contains(t, results[2].Failure, "output differs (- expected, + rendered):")
+	assert.Contains(t, results[2].Failure, "-       - image: busybox")
+	assert.Contains(t, results[2].Failure, "+       - image: nginx")
+
+	out := &bytes.Buffer{}
+	assert.Equal(t, 1, PrintDefinitionTestResults(results, out))
+	assert.Contains(t, out.String(), "FAIL testdata/deftest/worker_test.yaml: wrong image")
+	assert.Contains(t, out.String(), "2 passed, 1 failed")
+
+	_, err = RunDefinitionTests(nil, []string{"testdata/deftest/definitions.yaml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commontypes "github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	corev1beta1 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// OperateWorkflow changes the workflow operations of an application and updates it to the cluster,
+// the operation is done again on the latest application if it's changed by others meanwhile.
+func OperateWorkflow(ctx context.Context, c client.Client, namespace, appName string, operate func(app *corev1beta1.Application) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		app := &corev1beta1.Application{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, app); err != nil {
+			return err
+		}
+		if !app.Spec.Workflow.HasSteps() {
+			return errors.Errorf("application %s has no workflow", appName)
+		}
+		if err := operate(app); err != nil {
+			return err
+		}
+		return c.Update(ctx, app)
+	})
+}
+
+// SuspendWorkflow suspends the workflow of an application, the suspend is kept across app revisions until it's resumed.
+func SuspendWorkflow(app *corev1beta1.Application) error {
+	if app.Status.Workflow != nil && app.Status.Workflow.Terminated {
+		return errors.Errorf("the workflow of application %s is terminated", app.Name)
+	}
+	if app.GetAnnotations()[oam.AnnotationWorkflowSuspend] == "true" {
+		return errors.Errorf("the workflow of application %s is already suspended", app.Name)
+	}
+	setAnnotation(app, oam.AnnotationWorkflowSuspend, "true")
+	return nil
+}
+
+// ResumeWorkflow resumes the suspended workflow of an application.
+func ResumeWorkflow(app *corev1beta1.Application) error {
+	if app.GetAnnotations()[oam.AnnotationWorkflowSuspend] != "true" {
+		return errors.Errorf("the workflow of application %s is not suspended", app.Name)
+	}
+	if app.Status.Workflow != nil && app.Status.Workflow.Terminated {
+		return errors.Errorf("the workflow of application %s is terminated, please restart it instead", app.Name)
+	}
+	annotations := app.GetAnnotations()
+	delete(annotations, oam.AnnotationWorkflowSuspend)
+	app.SetAnnotations(annotations)
+	return nil
+}
+
+// TerminateWorkflow terminates the workflow of an application executed for the current app revision,
+// the workflow of a new app revision is not affected.
+func TerminateWorkflow(app *corev1beta1.Application) error {
+	if app.Status.Workflow == nil {
+		return errors.Errorf("the workflow of application %s is not started yet", app.Name)
+	}
+	setAnnotation(app, oam.AnnotationWorkflowTerminate, app.Status.Workflow.AppRevision)
+	return nil
+}
+
+// RestartWorkflow bumps the restart generation of the workflow of an application,
+// the workflow is executed from scratch with the step objects and the outputs of the last run removed.
+func RestartWorkflow(app *corev1beta1.Application) error {
+	var restart int64
+	if value := app.GetAnnotations()[oam.AnnotationWorkflowRestart]; value != "" {
+		var err error
+		if restart, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.Wrapf(err, "invalid annotation %s", oam.AnnotationWorkflowRestart)
+		}
+	}
+	setAnnotation(app, oam.AnnotationWorkflowRestart, strconv.FormatInt(restart+1, 10))
+	annotations := app.GetAnnotations()
+	delete(annotations, oam.AnnotationWorkflowTerminate)
+	app.SetAnnotations(annotations)
+	return nil
+}
+
+func setAnnotation(app *corev1beta1.Application, key, value string) {
+	annotations := app.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+	app.SetAnnotations(annotations)
+}
+
+// ApproveWorkflowStep returns the operation recording the decision on a suspend step in the workflow approvals annotation,
+// the step is succeeded if it's approved, or failed if it's rejected. The approver and the time are recorded by
+// the admission webhook of applications from the identity of the request.
+func ApproveWorkflowStep(stepName string, approved bool, comment string) func(app *corev1beta1.Application) error {
+	return func(app *corev1beta1.Application) error {
+		// the steps are looked up in the status since the steps of workflow templates and step groups
+		// are only known after the workflow is resolved
+		wfStatus := app.Status.Workflow
+		if wfStatus == nil {
+			return errors.Errorf("the workflow of application %s is not started yet", app.Name)
+		}
+		approvals, err := types.ParseWorkflowApprovals(app.GetAnnotations()[oam.AnnotationWorkflowApprovals])
+		if err != nil {
+			return errors.Wrapf(err, "invalid annotation %s", oam.AnnotationWorkflowApprovals)
+		}
+		for _, status := range wfStatus.Steps {
+			if status.Name != stepName {
+				continue
+			}
+			if !types.IsSuspendStep(status.Type) {
+				return errors.Errorf("workflow step %s is not a %s step", stepName, types.WorkflowStepTypeSuspend)
+			}
+			if status.Phase != commontypes.WorkflowStepPhaseRunning || status.Approval != nil || approvals.Of(stepName, wfStatus) != nil {
+				return errors.Errorf("workflow step %s is not waiting for approval", stepName)
+			}
+			approvals[stepName] = types.WorkflowApproval{
+				WorkflowStepApproval: commontypes.WorkflowStepApproval{
+					Approved: approved,
+					Comment:  comment,
+				},
+				AppRevision:       wfStatus.AppRevision,
+				RestartGeneration: wfStatus.RestartGeneration,
+			}
+			b, err := json.Marshal(approvals)
+			if err != nil {
+				return err
+			}
+			setAnnotation(app, oam.AnnotationWorkflowApprovals, string(b))
+			return nil
+		}
+		return errors.Errorf("application %s has no workflow step %s", app.Name, stepName)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	//lint:ignore SA1019 We will use pkg/envtest before upgrading controller-runtime to v1.0.0
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	commontypes "github.com/oam-dev/kubevela/apis/core.oam.dev/common"
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1beta1"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestOperateWorkflow(t *testing.T) {
+	ctx := context.Background()
+	app := &v1beta1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-workflow",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ApplicationSpec{
+			Workflow: &v1beta1.Workflow{
+				Steps: []v1beta1.WorkflowStep{{
+					Name: "deploy",
+					Type: "deploy",
+				}},
+			},
+		},
+		Status: commontypes.AppStatus{
+			Workflow: &commontypes.WorkflowStatus{
+				AppRevision: "app-workflow-v1",
+			},
+		},
+	}
+	fakeClient := fake.NewFakeClientWithScheme(common.Scheme, app.DeepCopy())
+	getApp := func() *v1beta1.Application {
+		got := &v1beta1.Application{}
+		assert.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: app.Name}, got))
+		return got
+	}
+
+	assert.NoError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, SuspendWorkflow))
+	assert.Equal(t, "true", getApp().GetAnnotations()[oam.AnnotationWorkflowSuspend])
+	assert.EqualError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, SuspendWorkflow),
+		"the workflow of application app-workflow is already suspended")
+
+	assert.NoError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, ResumeWorkflow))
+	assert.NotContains(t, getApp().GetAnnotations(), oam.AnnotationWorkflowSuspend)
+	assert.EqualError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, ResumeWorkflow),
+		"the workflow of application app-workflow is not suspended")
+
+	assert.NoError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, TerminateWorkflow))
+	assert.Equal(t, "app-workflow-v1", getApp().GetAnnotations()[oam.AnnotationWorkflowTerminate])
+
+	assert.NoError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, RestartWorkflow))
+	assert.Equal(t, "1", getApp().GetAnnotations()[oam.AnnotationWorkflowRestart])
+	assert.NotContains(t, getApp().GetAnnotations(), oam.AnnotationWorkflowTerminate)
+	assert.NoError(t, OperateWorkflow(ctx, fakeClient, app.Namespace, app.Name, RestartWorkflow))
+	assert.Equal(t, "2", getApp().GetAnnotations()[oam.AnnotationWorkflowRestart])
+	// the workflow status is left to the controller
+	assert.Equal(t, app.Status.Workflow, getApp().Status.Workflow)
+
+	noWorkflowApp := app.DeepCopy()
+	noWorkflowApp.Name = "app-without-workflow"
+	noWorkflowApp.Spec.Workflow = nil
+	assert.NoError(t, fakeClient.Create(ctx, noWorkflowApp))
+	err := OperateWorkflow(ctx, fakeClient, app.Namespace, noWorkflowApp.Name, func(app *v1beta1.Application) error {
+		return nil
+	})
+	assert.EqualError(t, err, "application app-without-workflow has no workflow")
+}
+
+func TestApproveWorkflowStep(t *testing.T) {
+	app := &v1beta1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-workflow",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ApplicationSpec{
+			Workflow: &v1beta1.Workflow{
+				Steps: []v1beta1.WorkflowStep{{
+					Name: "deploy-staging",
+					Type: "deploy",
+				}, {
+					Name: "approve-production",
+					Type: "suspend",
+				}, {
+					Name: "deploy-production",
+					Type: "deploy",
+				}},
+			},
+		},
+		Status: commontypes.AppStatus{
+			Workflow: &commontypes.WorkflowStatus{
+				AppRevision: "app-workflow-v1",
+				Steps: []commontypes.WorkflowStepStatus{{
+					Name:  "deploy-staging",
+					Type:  "deploy",
+					Phase: commontypes.WorkflowStepPhaseSucceeded,
+				}, {
+					Name:   "approve-production",
+					Type:   "suspend",
+					Phase:  commontypes.WorkflowStepPhaseRunning,
+					Reason: commontypes.WorkflowStepReasonWaitingForApproval,
+				}, {
+					Name:  "deploy-production",
+					Type:  "deploy",
+					Phase: commontypes.WorkflowStepPhasePending,
+				}},
+			},
+		},
+	}
+
+	testcases := map[string]struct {
+		step     string
+		approved bool
+		err      string
+	}{
+		"approve": {
+			step:     "approve-production",
+			approved: true,
+		},
+		"reject": {
+			step:     "approve-production",
+			approved: false,
+		},
+		"not a suspend step": {
+			step: "deploy-production",
+			err:  "workflow step deploy-production is not a suspend step",
+		},
+		"no such step": {
+			step: "approve-staging",
+			err:  "application app-workflow has no workflow step approve-staging",
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			testApp := app.DeepCopy()
+			err := ApproveWorkflowStep(tc.step, tc.approved, "LGTM")(testApp)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			// the decision is recorded in the annotation for the controller to read, the status is left untouched
+			assert.Nil(t, testApp.Status.Workflow.Steps[1].Approval)
+			approvals, err := types.ParseWorkflowApprovals(testApp.GetAnnotations()[oam.AnnotationWorkflowApprovals])
+			assert.NoError(t, err)
+			assert.Equal(t, "app-workflow-v1", approvals[tc.step].AppRevision)
+			approval := approvals.Of(tc.step, testApp.Status.Workflow)
+			assert.NotNil(t, approval)
+			assert.Equal(t, tc.approved, approval.Approved)
+			// the approver is left to the admission webhook
+			assert.Empty(t, approval.Approver)
+			assert.Equal(t, "LGTM", approval.Comment)
+
+			err = ApproveWorkflowStep(tc.step, tc.approved, "")(testApp)
+			assert.EqualError(t, err, "workflow step approve-production is not waiting for approval")
+		})
+	}
+}